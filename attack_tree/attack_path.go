@@ -0,0 +1,111 @@
+package attacktree
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Joao-Felisberto/devprivops/util"
+)
+
+// A single step of an attack path, i.e. a possible node and the bindings that made it possible
+type AttackPathStep struct {
+	Description string                   `json:"description"` // The description of the node's condition
+	Query       string                   `json:"query"`       // The query that encodes the condition
+	Witness     []map[string]interface{} `json:"witness"`     // The query results that justify the step
+}
+
+// A concrete way of realizing an attack/harm, from a leaf pre-condition up to the root
+type AttackPath struct {
+	Attack string           `json:"attack"` // The description of the tree's root
+	Steps  []AttackPathStep `json:"steps"`  // The steps from the leaf to the root
+}
+
+// Finds all chains of possible nodes that go from a possible leaf to the given node.
+// The returned chains are ordered from the leaf to the given node.
+//
+// `node`: The node where the chains end
+//
+// returns: the list of chains, empty if the node is not possible
+func possibleChains(node *AttackNode) [][]*AttackNode {
+	if node.ExecutionStatus != POSSIBLE {
+		return [][]*AttackNode{}
+	}
+	if len(node.Children) == 0 {
+		return [][]*AttackNode{{node}}
+	}
+
+	chains := [][]*AttackNode{}
+	for _, child := range node.Children {
+		for _, chain := range possibleChains(child) {
+			chains = append(chains, append(append([]*AttackNode{}, chain...), node))
+		}
+	}
+
+	return chains
+}
+
+// Finds the results of each node of a chain that join along it.
+// A node with bindings only keeps the results that join with a kept result of the node before it and,
+// going back from the end, every node only keeps the results that join with a kept result of the node after it.
+//
+// `chain`: The nodes, from the leaf to the last one
+//
+// returns: the results of each node that join with the rest of the chain
+func chainWitnesses(chain []*AttackNode) [][]map[string]interface{} {
+	witnesses := make([][]map[string]interface{}, len(chain))
+	for i, node := range chain {
+		witnesses[i] = []map[string]interface{}{}
+		if node.ExecutionResult != nil {
+			witnesses[i] = *node.ExecutionResult
+		}
+	}
+	for i := 1; i < len(chain); i++ {
+		if len(chain[i].Bindings) == 0 {
+			continue
+		}
+		witnesses[i] = util.Filter(witnesses[i], func(parent map[string]interface{}) bool {
+			return slices.ContainsFunc(witnesses[i-1], func(child map[string]interface{}) bool { return joins(child, parent, chain[i].Bindings) })
+		})
+	}
+	for i := len(chain) - 2; i >= 0; i-- {
+		if len(chain[i+1].Bindings) == 0 {
+			continue
+		}
+		witnesses[i] = util.Filter(witnesses[i], func(child map[string]interface{}) bool {
+			return slices.ContainsFunc(witnesses[i+1], func(parent map[string]interface{}) bool { return joins(child, parent, chain[i+1].Bindings) })
+		})
+	}
+	return witnesses
+}
+
+// Computes every concrete leaf-to-root path of possible nodes in an executed tree.
+// Each step carries as its witness the query results that justified it and join with the results of the other steps.
+//
+// returns: the list of paths, empty if the root is not possible
+func (tree *AttackTree) PossiblePaths() []AttackPath {
+	return util.Map(possibleChains(&tree.Root), func(chain []*AttackNode) AttackPath {
+		witnesses := chainWitnesses(chain)
+		steps := []AttackPathStep{}
+		for i, node := range chain {
+			steps = append(steps, AttackPathStep{
+				Description: node.Description,
+				Query:       node.Query,
+				Witness:     witnesses[i],
+			})
+		}
+		return AttackPath{
+			Attack: tree.Root.Description,
+			Steps:  steps,
+		}
+	})
+}
+
+// Formats the path as a single line, from the leaf to the root, with the witness of each step
+func (path AttackPath) String() string {
+	steps := []string{}
+	for _, step := range path.Steps {
+		steps = append(steps, fmt.Sprintf("%s %v", step.Description, step.Witness))
+	}
+	return strings.Join(steps, " -> ")
+}
@@ -31,6 +31,17 @@ type AttackNode struct {
 	ExecutionResult *[]map[string]interface{} `json:"execution result"` // The result of running the query, if it was run, else nil
 	ClearenceLvl    int                       `json:"clearence level"`  // The minimum hierarchical level required to see this in the visualizer
 	Groups          []string                  `json:"groups"`           // The groups allowed to see this in the visualizer
+	Bindings        []string                  `json:"bindings"`         // The variables whose values are taken from the children's results to restrict the query
+	Severity        util.Severity             `json:"severity"`         // How serious it is for the node to be possible, only the root's is used to judge the tree
+	ResultTerms     []map[string]ResultTerm   `json:"-" yaml:"-"`       // The execution results with the kind of term of each value, if the node ran in this process
+}
+
+// A value of a query result with the kind of term it is, as written in the SPARQL JSON results
+type ResultTerm struct {
+	Type     string `json:"type"`     // `uri`, `literal` or `bnode`
+	Value    string `json:"value"`    // The IRI, the lexical form of the literal or the label of the blank node
+	Datatype string `json:"datatype"` // The datatype of a typed literal, or ""
+	Lang     string `json:"xml:lang"` // The language tag of a literal, or ""
 }
 
 // Represents the whole attack/harm tree.
@@ -62,7 +73,9 @@ func (node *AttackNode) SetExecutionResults(status ExecutionStatus, results *[]m
 //	{
 //		"description": "some text",
 //		"query": "path to the query file",
-//		"children": [] // more nodes like this one in the array
+//		"children": [], // more nodes like this one in the array
+//		"bindings": [], // optional, variables the query shares with the children
+//		"severity": "high" // optional, how serious it is for the node to be possible
 //	}
 //
 // Calls to this method should pass a root node, the children are processed recursivelly.
@@ -81,6 +94,8 @@ func parseNode(data interface{}) (*AttackNode, error) {
 		clearenceLvl, clearenceOk := node["clearence level"].(int)
 		groupsRaw, groupsOk := node["groups"].([]interface{})
 		childrenData, childrenOk := node["children"].([]interface{})
+		bindingsRaw, _ := node["bindings"].([]interface{})
+		severityName, _ := node["severity"].(string)
 
 		// Can never occur, schema is validated prior
 		if !descOk || !queryOk || !childrenOk || !clearenceOk || !groupsOk {
@@ -88,6 +103,11 @@ func parseNode(data interface{}) (*AttackNode, error) {
 		}
 
 		groups := util.Map(groupsRaw, func(raw interface{}) string { return raw.(string) })
+		bindings := util.Map(bindingsRaw, func(raw interface{}) string { return raw.(string) })
+		severity, err := util.ParseSeverity(severityName)
+		if err != nil {
+			return nil, err
+		}
 
 		children := make([]*AttackNode, len(childrenData))
 		for i, childData := range childrenData {
@@ -106,6 +126,8 @@ func parseNode(data interface{}) (*AttackNode, error) {
 			ExecutionResult: nil,
 			ClearenceLvl:    clearenceLvl,
 			Groups:          groups,
+			Bindings:        bindings,
+			Severity:        severity,
 		}, nil
 	default:
 		return nil, fmt.Errorf("invalid node data type: %s", reflect.TypeOf(data))
@@ -2,10 +2,14 @@
 package attacktree_test
 
 import (
+	"encoding/json"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
 
 	attacktree "github.com/Joao-Felisberto/devprivops/attack_tree"
+	"github.com/Joao-Felisberto/devprivops/util"
 )
 
 // Tests for the (*attacktree.AttackNode)SetExecutionStatus method
@@ -39,6 +43,7 @@ description: R
 query: master.rq
 clearence level: 0
 groups: []
+severity: critical
 children:
   - description: C1 
     query: file1.rq
@@ -72,6 +77,9 @@ children:
 	if len(root.Children) != 2 {
 		t.Errorf("Node 'R' should have 2 children")
 	}
+	if root.Severity != util.CRITICAL {
+		t.Errorf("Severity mismatch, expected 'critical', got '%s'", root.Severity)
+	}
 
 	c0 := root.Children[0]
 	if c0.Description != "C1" {
@@ -83,6 +91,9 @@ children:
 	if len(c0.Children) > 0 {
 		t.Errorf("Node 'C1' should not have children")
 	}
+	if c0.Severity != util.DEFAULT_SEVERITY {
+		t.Errorf("Nodes without a severity should have the default one, got '%s'", c0.Severity)
+	}
 	c1 := root.Children[1]
 	if c1.Description != "C2" {
 		t.Errorf("Description mismatch, expected 'C2', got '%s'", c1.Description)
@@ -198,3 +209,326 @@ children:
 	}
 }
 */
+
+// Test whether attack trees are rendered in every supported format, with and without execution status
+func TestRender(t *testing.T) {
+	child := &attacktree.AttackNode{
+		Description:     "C1",
+		Query:           "file1.rq",
+		Children:        []*attacktree.AttackNode{},
+		ExecutionStatus: attacktree.POSSIBLE,
+	}
+	tree := attacktree.AttackTree{Root: attacktree.AttackNode{
+		Description:     "R",
+		Query:           "master.rq",
+		Children:        []*attacktree.AttackNode{child},
+		ExecutionStatus: attacktree.NOT_POSSIBLE,
+	}}
+
+	expected := map[attacktree.RenderFormat][]string{
+		attacktree.DOT:      {"digraph attack_tree {", `n0 [label="R"];`, `n0_0 [label="C1"];`, "n0_0 -> n0;"},
+		attacktree.MERMAID:  {"flowchart BT", `n0["R"]`, `n0_0["C1"]`, "n0_0 --> n0"},
+		attacktree.PLANTUML: {"@startwbs", "* R", "** C1", "@endwbs"},
+	}
+	for format, lines := range expected {
+		diagram, err := attacktree.Render(&tree, format, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range lines {
+			if !strings.Contains(diagram, line) {
+				t.Errorf("%s diagram does not contain '%s':\n%s", format, line, diagram)
+			}
+		}
+	}
+
+	diagram, err := attacktree.Render(&tree, attacktree.DOT, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(diagram, `n0_0 [label="C1\n[POSSIBLE]", fillcolor="#f08080"];`) {
+		t.Errorf("DOT diagram does not colour the possible node:\n%s", diagram)
+	}
+
+	if _, err := attacktree.Render(&tree, "svg", false); err == nil {
+		t.Error("Rendering to an unsupported format should fail")
+	}
+}
+
+// Test whether the execution status of a report is copied onto the tree's nodes
+func TestOverlayReport(t *testing.T) {
+	fileData := `
+description: R
+query: master.rq
+clearence level: 0
+groups: []
+children:
+  - description: C1
+    query: file1.rq
+    clearence level: 0
+    groups: []
+    children: []
+`
+	reportData := `{"attack trees": [{"root": {
+		"description": "R", "execution status": 1, "children": [
+			{"description": "C1", "execution status": 2, "children": [], "execution result": [{"s": "x"}]}
+		]
+	}}]}`
+
+	if err := os.WriteFile("tmp.yml", []byte(fileData), 0666); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("tmp.yml")
+	if err := os.WriteFile("tmp.json", []byte(reportData), 0666); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("tmp.json")
+
+	atkTree, err := attacktree.NewAttackTreeFromYaml("tmp.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := atkTree.OverlayReport("tmp.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	if atkTree.Root.ExecutionStatus != attacktree.NOT_POSSIBLE {
+		t.Errorf("Root status should be NOT POSSIBLE, got %s", atkTree.Root.ExecutionStatus)
+	}
+	if atkTree.Root.Children[0].ExecutionStatus != attacktree.POSSIBLE {
+		t.Errorf("Child status should be POSSIBLE, got %s", atkTree.Root.Children[0].ExecutionStatus)
+	}
+	if len(*atkTree.Root.Children[0].ExecutionResult) != 1 {
+		t.Errorf("Child results were not copied: %v", atkTree.Root.Children[0].ExecutionResult)
+	}
+}
+
+// Test whether a report written by the analysis, with its severities, can be overlaid on the tree
+func TestOverlayWrittenReport(t *testing.T) {
+	fileData := `
+description: R
+query: master.rq
+clearence level: 0
+groups: []
+severity: critical
+children:
+  - description: C1
+    query: file1.rq
+    clearence level: 0
+    groups: []
+    children: []
+`
+	if err := os.WriteFile("tmp.yml", []byte(fileData), 0666); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("tmp.yml")
+
+	executed, err := attacktree.NewAttackTreeFromYaml("tmp.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	executed.Root.Children[0].SetExecutionResults(attacktree.POSSIBLE, &[]map[string]interface{}{{"s": "x"}})
+	executed.Root.SetExecutionResults(attacktree.POSSIBLE, &[]map[string]interface{}{{"s": "y"}})
+	reportData, err := json.Marshal(map[string]interface{}{"attack trees": []*attacktree.AttackTree{executed}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(reportData), `"severity":"critical"`) {
+		t.Fatalf("The report should have the severity by name, got %s", reportData)
+	}
+	if err := os.WriteFile("tmp.json", reportData, 0666); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("tmp.json")
+
+	atkTree, err := attacktree.NewAttackTreeFromYaml("tmp.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := atkTree.OverlayReport("tmp.json"); err != nil {
+		t.Fatal(err)
+	}
+	if atkTree.Root.ExecutionStatus != attacktree.POSSIBLE || atkTree.Root.Children[0].ExecutionStatus != attacktree.POSSIBLE {
+		t.Errorf("The statuses of the report were not copied: %s, %s", atkTree.Root.ExecutionStatus, atkTree.Root.Children[0].ExecutionStatus)
+	}
+}
+
+// Test whether every leaf-to-root path of possible nodes is extracted, with the node results as witness
+func TestPossiblePaths(t *testing.T) {
+	leafRes := []map[string]interface{}{{"flow": "F"}}
+	midRes := []map[string]interface{}{{"store": "S"}}
+	rootRes := []map[string]interface{}{{"network": "N"}}
+
+	tree := attacktree.AttackTree{Root: attacktree.AttackNode{
+		Description:     "R",
+		ExecutionStatus: attacktree.POSSIBLE,
+		ExecutionResult: &rootRes,
+		Children: []*attacktree.AttackNode{
+			{Description: "C1", ExecutionStatus: attacktree.POSSIBLE, ExecutionResult: &leafRes, Children: []*attacktree.AttackNode{}},
+			{Description: "C2", ExecutionStatus: attacktree.NOT_POSSIBLE, Children: []*attacktree.AttackNode{}},
+			{Description: "C3", ExecutionStatus: attacktree.POSSIBLE, ExecutionResult: &midRes, Children: []*attacktree.AttackNode{
+				{Description: "C31", ExecutionStatus: attacktree.POSSIBLE, ExecutionResult: &leafRes, Children: []*attacktree.AttackNode{}},
+				{Description: "C32", ExecutionStatus: attacktree.NOT_EXECUTED, Children: []*attacktree.AttackNode{}},
+			}},
+		},
+	}}
+
+	paths := tree.PossiblePaths()
+	if len(paths) != 2 {
+		t.Fatalf("Expected 2 paths, got %d: %v", len(paths), paths)
+	}
+
+	expected := [][]string{{"C1", "R"}, {"C31", "C3", "R"}}
+	for i, path := range paths {
+		if path.Attack != "R" {
+			t.Errorf("Path %d should belong to attack 'R', got '%s'", i, path.Attack)
+		}
+		descriptions := []string{}
+		for _, step := range path.Steps {
+			descriptions = append(descriptions, step.Description)
+		}
+		if !reflect.DeepEqual(descriptions, expected[i]) {
+			t.Errorf("Path %d mismatch, expected %v, got %v", i, expected[i], descriptions)
+		}
+	}
+	if !reflect.DeepEqual(paths[1].Steps[1].Witness, midRes) {
+		t.Errorf("Witness mismatch, expected %v, got %v", midRes, paths[1].Steps[1].Witness)
+	}
+
+	tree.Root.ExecutionStatus = attacktree.NOT_POSSIBLE
+	if paths := tree.PossiblePaths(); len(paths) != 0 {
+		t.Errorf("A tree whose root is not possible should have no paths, got %v", paths)
+	}
+}
+
+// Test whether the witness of each step only has the results that join along its path
+func TestPossiblePathsJoin(t *testing.T) {
+	c1Res := []map[string]interface{}{{"flow": "F1"}, {"flow": "F9"}}
+	c2Res := []map[string]interface{}{{"flow": "F2"}}
+	rootRes := []map[string]interface{}{{"flow": "F1", "store": "S1"}, {"flow": "F2", "store": "S2"}}
+
+	tree := attacktree.AttackTree{Root: attacktree.AttackNode{
+		Description:     "R",
+		ExecutionStatus: attacktree.POSSIBLE,
+		ExecutionResult: &rootRes,
+		Bindings:        []string{"flow"},
+		Children: []*attacktree.AttackNode{
+			{Description: "C1", ExecutionStatus: attacktree.POSSIBLE, ExecutionResult: &c1Res, Children: []*attacktree.AttackNode{}},
+			{Description: "C2", ExecutionStatus: attacktree.POSSIBLE, ExecutionResult: &c2Res, Children: []*attacktree.AttackNode{}},
+		},
+	}}
+
+	paths := tree.PossiblePaths()
+	if len(paths) != 2 {
+		t.Fatalf("Expected 2 paths, got %d: %v", len(paths), paths)
+	}
+	expected := [][][]map[string]interface{}{
+		{{{"flow": "F1"}}, {{"flow": "F1", "store": "S1"}}},
+		{{{"flow": "F2"}}, {{"flow": "F2", "store": "S2"}}},
+	}
+	for i, path := range paths {
+		for j, step := range path.Steps {
+			if !reflect.DeepEqual(step.Witness, expected[i][j]) {
+				t.Errorf("Witness of step %d of path %d mismatch, expected %v, got %v", j, i, expected[i][j], step.Witness)
+			}
+		}
+	}
+}
+
+// Test whether a node collects the values its possible children bound to its bindings
+func TestChildBindings(t *testing.T) {
+	c1Res := []map[string]interface{}{
+		{"flow": "https://devprivops.com/dfd/F1", "other": "x"},
+		{"flow": "https://devprivops.com/dfd/F1", "other": "y"},
+		{"other": "z"},
+	}
+	c2Res := []map[string]interface{}{{"flow": "https://devprivops.com/dfd/F2"}}
+	node := attacktree.AttackNode{
+		Description: "R",
+		Bindings:    []string{"flow"},
+		Children: []*attacktree.AttackNode{
+			{Description: "C1", ExecutionStatus: attacktree.POSSIBLE, ExecutionResult: &c1Res},
+			{Description: "C2", ExecutionStatus: attacktree.NOT_POSSIBLE, ExecutionResult: &c2Res},
+		},
+	}
+
+	expected := []map[string]interface{}{{"flow": "https://devprivops.com/dfd/F1"}}
+	if rows := node.ChildBindings(); !reflect.DeepEqual(rows, expected) {
+		t.Errorf("Bindings mismatch, expected %v, got %v", expected, rows)
+	}
+}
+
+// Test whether bindings are injected as a VALUES block in the WHERE clause
+func TestInjectValues(t *testing.T) {
+	query := "PREFIX dfd: <https://devprivops.com/dfd/>\nSELECT * WHERE {\n    ?flow dfd:to ?n .\n}"
+	rows := []map[string]interface{}{
+		{"flow": "https://devprivops.com/dfd/F1", "n": "net \"1\""},
+		{"flow": "https://devprivops.com/dfd/F2", "n": 2},
+	}
+
+	res, err := attacktree.InjectValues(query, []string{"flow", "n"}, rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "PREFIX dfd: <https://devprivops.com/dfd/>\nSELECT * WHERE {\n" +
+		"    VALUES ( ?flow ?n ) {\n" +
+		"        ( <https://devprivops.com/dfd/F1> \"net \\\"1\\\"\" )\n" +
+		"        ( <https://devprivops.com/dfd/F2> 2 )\n" +
+		"    }\n\n    ?flow dfd:to ?n .\n}"
+	if res != expected {
+		t.Errorf("Injected query mismatch, expected:\n%s\ngot:\n%s", expected, res)
+	}
+
+	res, err = attacktree.InjectValues("# no WHERE keyword {\nASK { ?flow ?p ?n }", []string{"flow"}, rows[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = "# no WHERE keyword {\nASK {\n" +
+		"    VALUES ( ?flow ) {\n" +
+		"        ( <https://devprivops.com/dfd/F1> )\n" +
+		"    }\n ?flow ?p ?n }"
+	if res != expected {
+		t.Errorf("Injected query without WHERE mismatch, expected:\n%s\ngot:\n%s", expected, res)
+	}
+
+	res, err = attacktree.InjectValues("SELECT ?flow { { SELECT ?flow WHERE { ?flow ?p ?o } } }", []string{"flow"}, rows[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(res, "SELECT ?flow {\n    VALUES ( ?flow )") {
+		t.Errorf("Bindings should be injected into the outermost pattern, got:\n%s", res)
+	}
+
+	if _, err := attacktree.InjectValues("DESCRIBE <urn:x>", []string{"flow"}, rows); err == nil {
+		t.Error("Injecting into a query without a WHERE clause should fail")
+	}
+}
+
+// Test whether the children's result terms keep their datatype and language when injected
+func TestInjectTypedValues(t *testing.T) {
+	res := []map[string]interface{}{{"n": "2", "l": "sync"}, {"n": "urn:x", "l": "y"}}
+	terms := []map[string]attacktree.ResultTerm{
+		{"n": {Type: "literal", Value: "2", Datatype: "http://www.w3.org/2001/XMLSchema#integer"}, "l": {Type: "literal", Value: "sync", Lang: "en"}},
+		{"n": {Type: "literal", Value: "urn:x"}, "l": {Type: "literal", Value: "y"}},
+	}
+	node := attacktree.AttackNode{
+		Description: "R",
+		Bindings:    []string{"n", "l"},
+		Children: []*attacktree.AttackNode{
+			{Description: "C", ExecutionStatus: attacktree.POSSIBLE, ExecutionResult: &res, ResultTerms: terms},
+		},
+	}
+
+	query, err := attacktree.InjectValues("SELECT * WHERE { ?s ?n ?l }", node.Bindings, node.ChildBindings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "SELECT * WHERE {\n" +
+		"    VALUES ( ?n ?l ) {\n" +
+		"        ( \"2\"^^<http://www.w3.org/2001/XMLSchema#integer> \"sync\"@en )\n" +
+		"        ( \"urn:x\" \"y\" )\n" +
+		"    }\n ?s ?n ?l }"
+	if query != expected {
+		t.Errorf("Injected query mismatch, expected:\n%s\ngot:\n%s", expected, query)
+	}
+}
@@ -0,0 +1,167 @@
+package attacktree
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/Joao-Felisberto/devprivops/sparql"
+)
+
+// Regex to identify values that should be written as IRIs rather than literals
+var iriRe = regexp.MustCompile(`^(https?|urn|file):[^\s<>"{}|\\^` + "`" + `]+$`)
+
+// Writes a binding value as a SPARQL term.
+// Terms of query results are written as the kind of term they are, with the datatype or language of literals.
+// Other values that look like IRIs are written as IRIs, everything else as a string literal.
+//
+// `value`: The value bound to a variable
+//
+// returns: the SPARQL term
+func sparqlTerm(value interface{}) string {
+	if term, ok := value.(ResultTerm); ok {
+		switch {
+		case term.Type == "uri":
+			return fmt.Sprintf("<%s>", term.Value)
+		case term.Lang != "":
+			return fmt.Sprintf("%s@%s", quote(term.Value), term.Lang)
+		case term.Datatype != "":
+			return fmt.Sprintf("%s^^<%s>", quote(term.Value), term.Datatype)
+		}
+		return quote(term.Value)
+	}
+	str := fmt.Sprintf("%v", value)
+	if iriRe.MatchString(str) {
+		return fmt.Sprintf("<%s>", str)
+	}
+	switch value.(type) {
+	case bool, int, float64:
+		return str
+	}
+	return quote(str)
+}
+
+// Writes a string as a SPARQL string literal
+//
+// `str`: The string
+//
+// returns: the quoted and escaped string
+func quote(str string) string {
+	str = strings.ReplaceAll(str, `\`, `\\`)
+	str = strings.ReplaceAll(str, `"`, `\"`)
+	str = strings.ReplaceAll(str, "\n", `\n`)
+	return fmt.Sprintf(`"%s"`, str)
+}
+
+// Collects the results of the node's possible children that bind every variable in `Bindings`.
+// The values are `ResultTerm`s for the children that ran in this process, so that they keep their kind of term.
+// Duplicated rows are only kept once.
+//
+// returns: the projection of the children's results on the node's bindings
+func (node *AttackNode) ChildBindings() []map[string]interface{} {
+	rows := []map[string]interface{}{}
+	for _, child := range node.Children {
+		if child.ExecutionStatus != POSSIBLE || child.ExecutionResult == nil {
+			continue
+		}
+		results := *child.ExecutionResult
+		if len(child.ResultTerms) == len(results) {
+			results = make([]map[string]interface{}, len(child.ResultTerms))
+			for i, terms := range child.ResultTerms {
+				results[i] = map[string]interface{}{}
+				for v, term := range terms {
+					results[i][v] = term
+				}
+			}
+		}
+		for _, res := range results {
+			row, ok := project(res, node.Bindings)
+			if !ok {
+				continue
+			}
+			if !slices.ContainsFunc(rows, func(r map[string]interface{}) bool { return reflect.DeepEqual(r, row) }) {
+				rows = append(rows, row)
+			}
+		}
+	}
+	return rows
+}
+
+// Projects a result on some variables
+//
+// `res`: The result
+//
+// `vars`: The variables
+//
+// returns: the values of the variables and whether the result binds every one of them
+func project(res map[string]interface{}, vars []string) (map[string]interface{}, bool) {
+	row := map[string]interface{}{}
+	for _, v := range vars {
+		value, ok := res[v]
+		if !ok {
+			return nil, false
+		}
+		row[v] = value
+	}
+	return row, true
+}
+
+// Whether a result of a child joins with a result of its parent, i.e. the child binds the parent's bindings to the values the parent has
+//
+// `child`: The result of the child
+//
+// `parent`: The result of the parent
+//
+// `vars`: The parent's bindings
+//
+// returns: whether the results join
+func joins(child map[string]interface{}, parent map[string]interface{}, vars []string) bool {
+	row, ok := project(child, vars)
+	if !ok {
+		return false
+	}
+	for v, value := range row {
+		if bound, ok := parent[v]; ok && !reflect.DeepEqual(bound, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// Restricts a query to the given bindings by injecting a VALUES block at the start of its WHERE clause.
+//
+// `query`: The query to restrict
+//
+// `vars`: The variables to restrict, without the leading `?`
+//
+// `rows`: The allowed values for each variable
+//
+// returns: the restricted query or an error if the query could not be parsed or has no WHERE clause
+func InjectValues(query string, vars []string, rows []map[string]interface{}) (string, error) {
+	parsed, err := sparql.Parse(query)
+	if err != nil {
+		return "", fmt.Errorf("could not parse query: %s", err)
+	}
+	if parsed.Where < 0 {
+		return "", fmt.Errorf("query has no WHERE clause to inject bindings into")
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\n    VALUES (")
+	for _, v := range vars {
+		sb.WriteString(fmt.Sprintf(" ?%s", v))
+	}
+	sb.WriteString(" ) {\n")
+	for _, row := range rows {
+		sb.WriteString("        (")
+		for _, v := range vars {
+			sb.WriteString(" " + sparqlTerm(row[v]))
+		}
+		sb.WriteString(" )\n")
+	}
+	sb.WriteString("    }\n")
+
+	return query[:parsed.Where] + sb.String() + query[parsed.Where:], nil
+}
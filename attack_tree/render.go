@@ -0,0 +1,210 @@
+package attacktree
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// The diagram formats an attack/harm tree can be rendered to
+type RenderFormat string
+
+const (
+	DOT      RenderFormat = "dot"      // Graphviz DOT
+	MERMAID  RenderFormat = "mermaid"  // Mermaid flowchart
+	PLANTUML RenderFormat = "plantuml" // PlantUML WBS diagram
+)
+
+// The fill colour used for each execution status when rendering a tree
+var statusColors = map[ExecutionStatus]string{
+	NOT_EXECUTED: "#d3d3d3",
+	NOT_POSSIBLE: "#90ee90",
+	POSSIBLE:     "#f08080",
+	ERROR:        "#ffd700",
+}
+
+// Returns a human readable name for the execution status
+func (status ExecutionStatus) String() string {
+	switch status {
+	case NOT_EXECUTED:
+		return "NOT EXECUTED"
+	case NOT_POSSIBLE:
+		return "NOT POSSIBLE"
+	case POSSIBLE:
+		return "POSSIBLE"
+	case ERROR:
+		return "ERROR"
+	default:
+		return fmt.Sprintf("UNKNOWN(%d)", int(status))
+	}
+}
+
+// Renders the tree as a diagram in the given format.
+// Nodes are coloured by their execution status when `withStatus` is true.
+//
+// `tree`: The tree to render
+//
+// `format`: The diagram format
+//
+// `withStatus`: Whether to colour nodes by their execution status
+//
+// returns: the diagram source or an error if the format is not supported
+func Render(tree *AttackTree, format RenderFormat, withStatus bool) (string, error) {
+	var sb strings.Builder
+	switch format {
+	case DOT:
+		sb.WriteString("digraph attack_tree {\n")
+		sb.WriteString("\trankdir=BT;\n")
+		sb.WriteString("\tnode [shape=box, style=\"rounded,filled\", fillcolor=\"#ffffff\"];\n")
+		renderDOTNode(&sb, &tree.Root, "n0", withStatus)
+		sb.WriteString("}\n")
+	case MERMAID:
+		sb.WriteString("flowchart BT\n")
+		renderMermaidNode(&sb, &tree.Root, "n0", withStatus)
+		if withStatus {
+			for _, status := range []ExecutionStatus{NOT_EXECUTED, NOT_POSSIBLE, POSSIBLE, ERROR} {
+				sb.WriteString(fmt.Sprintf("\tclassDef %s fill:%s\n", mermaidClass(status), statusColors[status]))
+			}
+		}
+	case PLANTUML:
+		sb.WriteString("@startwbs\n")
+		renderPlantUMLNode(&sb, &tree.Root, 1, withStatus)
+		sb.WriteString("@endwbs\n")
+	default:
+		return "", fmt.Errorf("unsupported format '%s', valid possibilities: [%s, %s, %s]", format, DOT, MERMAID, PLANTUML)
+	}
+
+	return sb.String(), nil
+}
+
+// Builds the label of a node, optionally with its execution status
+//
+// `node`: The node to label
+//
+// `withStatus`: Whether to add the execution status to the label
+//
+// returns: the label
+func nodeLabel(node *AttackNode, withStatus bool) string {
+	if withStatus {
+		return fmt.Sprintf("%s\n[%s]", node.Description, node.ExecutionStatus)
+	}
+	return node.Description
+}
+
+// Writes a node and its children in the DOT format. Edges go from each pre-condition to the node it enables.
+//
+// `sb`: The builder to write to
+//
+// `node`: The node to render
+//
+// `id`: The unique identifier of the node in the diagram
+//
+// `withStatus`: Whether to colour nodes by their execution status
+func renderDOTNode(sb *strings.Builder, node *AttackNode, id string, withStatus bool) {
+	label := strings.ReplaceAll(nodeLabel(node, withStatus), `"`, `\"`)
+	label = strings.ReplaceAll(label, "\n", `\n`)
+	if withStatus {
+		sb.WriteString(fmt.Sprintf("\t%s [label=\"%s\", fillcolor=\"%s\"];\n", id, label, statusColors[node.ExecutionStatus]))
+	} else {
+		sb.WriteString(fmt.Sprintf("\t%s [label=\"%s\"];\n", id, label))
+	}
+	for i, child := range node.Children {
+		childId := fmt.Sprintf("%s_%d", id, i)
+		renderDOTNode(sb, child, childId, withStatus)
+		sb.WriteString(fmt.Sprintf("\t%s -> %s;\n", childId, id))
+	}
+}
+
+// The mermaid class name for each execution status
+func mermaidClass(status ExecutionStatus) string {
+	return strings.ToLower(strings.ReplaceAll(status.String(), " ", "_"))
+}
+
+// Writes a node and its children in the Mermaid flowchart format. Edges go from each pre-condition to the node it enables.
+//
+// `sb`: The builder to write to
+//
+// `node`: The node to render
+//
+// `id`: The unique identifier of the node in the diagram
+//
+// `withStatus`: Whether to colour nodes by their execution status
+func renderMermaidNode(sb *strings.Builder, node *AttackNode, id string, withStatus bool) {
+	label := strings.ReplaceAll(nodeLabel(node, withStatus), `"`, "#quot;")
+	label = strings.ReplaceAll(label, "\n", "<br/>")
+	sb.WriteString(fmt.Sprintf("\t%s[\"%s\"]\n", id, label))
+	if withStatus {
+		sb.WriteString(fmt.Sprintf("\tclass %s %s\n", id, mermaidClass(node.ExecutionStatus)))
+	}
+	for i, child := range node.Children {
+		childId := fmt.Sprintf("%s_%d", id, i)
+		renderMermaidNode(sb, child, childId, withStatus)
+		sb.WriteString(fmt.Sprintf("\t%s --> %s\n", childId, id))
+	}
+}
+
+// Writes a node and its children in the PlantUML WBS format, where depth is given by the number of `*`
+//
+// `sb`: The builder to write to
+//
+// `node`: The node to render
+//
+// `depth`: The depth of the node in the tree, starting at 1
+//
+// `withStatus`: Whether to colour nodes by their execution status
+func renderPlantUMLNode(sb *strings.Builder, node *AttackNode, depth int, withStatus bool) {
+	label := strings.ReplaceAll(nodeLabel(node, withStatus), "\n", " ")
+	color := ""
+	if withStatus {
+		color = fmt.Sprintf("[%s]", statusColors[node.ExecutionStatus])
+	}
+	sb.WriteString(fmt.Sprintf("%s%s %s\n", strings.Repeat("*", depth), color, label))
+	for _, child := range node.Children {
+		renderPlantUMLNode(sb, child, depth+1, withStatus)
+	}
+}
+
+// Copies the execution status and results from an executed node onto a node with the same structure.
+// Children are matched by position and description.
+//
+// `node`: The node to update
+//
+// `executed`: The node holding the execution results
+func overlayNode(node *AttackNode, executed *AttackNode) {
+	node.SetExecutionResults(executed.ExecutionStatus, executed.ExecutionResult)
+	for i, child := range node.Children {
+		if i < len(executed.Children) && executed.Children[i].Description == child.Description {
+			overlayNode(child, executed.Children[i])
+		}
+	}
+}
+
+// Sets the execution status of the tree's nodes from a report produced by the `analyse` command.
+// The tree in the report is found by the description of its root.
+//
+// `reportFile`: The JSON report file
+//
+// returns: an error if the report could not be read or parsed or does not contain the tree
+func (tree *AttackTree) OverlayReport(reportFile string) error {
+	data, err := os.ReadFile(reportFile)
+	if err != nil {
+		return fmt.Errorf("error reading report '%s': %s", reportFile, err)
+	}
+
+	var report struct {
+		AttackTrees []*AttackTree `json:"attack trees"`
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		return fmt.Errorf("error parsing report '%s': %s", reportFile, err)
+	}
+
+	for _, executed := range report.AttackTrees {
+		if executed.Root.Description == tree.Root.Description {
+			overlayNode(&tree.Root, &executed.Root)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("tree '%s' not found in report '%s'", tree.Root.Description, reportFile)
+}
@@ -16,77 +16,140 @@ import (
 	attacktree "github.com/Joao-Felisberto/devprivops/attack_tree"
 	"github.com/Joao-Felisberto/devprivops/database"
 	"github.com/Joao-Felisberto/devprivops/fs"
+	"github.com/Joao-Felisberto/devprivops/reasoner"
 	"github.com/Joao-Felisberto/devprivops/schema"
 	"github.com/Joao-Felisberto/devprivops/util"
+	"github.com/Joao-Felisberto/devprivops/variant"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v2"
 )
 
-var tooManyViolations = false
-
-// Run all reasoner rules
+// Lists the reasoner rules in execution order, as given by the rule files and the manifest
 //
-// # The reasoner rules live under the `reasoner` subdirectory under each configuration directory
+// returns: the rules or an error if the directory or manifest cannot be read or the manifest is invalid
+func reasonerRules() ([]reasoner.Rule, error) {
+	files, err := listDir("reasoner")
+	if err != nil {
+		return nil, err
+	}
+	files = util.Filter(files, func(f string) bool { return f != reasoner.MANIFEST_FILE })
+
+	manifest := []reasoner.Rule{}
+	if manifestFile, err := fs.GetFile(reasoner.MANIFEST_FILE); err == nil {
+		if manifest, err = reasoner.ReadManifest(manifestFile); err != nil {
+			return nil, err
+		}
+	}
+	return reasoner.Order(files, manifest)
+}
+
+// Runs passes until one adds no triples or the iteration cap is reached, which only warns
 //
 // `dbManager`: The DBManager connecting to the database
 //
-// returns: an error when the rule could not be read from the file or run
-func reasoner(dbManager *database.DBManager) error {
-	slog.Info("===Reasoner Rules===")
-	reasonDir, err := fs.GetFile("reasoner")
-	if err != nil {
-		return err
-	}
-	files, err := os.ReadDir(reasonDir)
+// `name`: What the passes run, for the logs
+//
+// `maxIterations`: How many passes to run at most
+//
+// `pass`: Runs a single pass
+//
+// returns: an error if a pass fails or the triples cannot be counted
+func runToFixpoint(dbManager *database.DBManager, name string, maxIterations int, pass func() error) error {
+	count, err := dbManager.CountTriples()
 	if err != nil {
 		return err
 	}
-	for _, file := range files {
-		fPath, err := fs.GetFile(fmt.Sprintf("reasoner/%s", file.Name()))
+	for i := 1; ; i++ {
+		if err := pass(); err != nil {
+			return err
+		}
+		newCount, err := dbManager.CountTriples()
 		if err != nil {
 			return err
 		}
-		if err := dbManager.ExecuteReasonerRule(fPath); err != nil {
-			return fmt.Errorf("could not execute reasoner rule: %s", err)
+		if newCount == count {
+			slog.Debug("Reached a fixpoint", "rules", name, "iterations", i)
+			return nil
 		}
+		if i == maxIterations {
+			slog.Warn("Stopped before reaching a fixpoint", "rules", name, "iterations", i, "new triples", newCount-count)
+			return nil
+		}
+		count = newCount
 	}
-
-	return nil
 }
 
-// Runs all policies of a regulation
+// Run all reasoner rules
 //
-// # The returned report will contain the violations to each policy, or empty lists if they have none
+// # The reasoner rules live under the `reasoner` subdirectory under each configuration directory
+// Rules run after their dependencies, and rules marked to repeat run until a pass adds no triples or their iteration cap is reached.
+// The built-in entailment rules run last, so they also cover what the other rules infer.
 //
 // `dbManager`: The DBManager connecting to the database
 //
-// `regulation`: The path to the regulation (relative to the regulations path)
+// `entailment`: The built-in entailment rules to materialise
 //
-// returns: the execution report if everything succeeds, or an error when the policy could not be read from the file, does not abide by the schema, or has execution errors
-func policies(dbManager *database.DBManager, regulation string) ([]map[string]interface{}, error) {
-	slog.Info("===Policy Compliance===")
-	polFile, err := fs.GetFile(fmt.Sprintf("regulations/%s/policies.yml", regulation))
+// returns: an error when the rule could not be read from the file or run
+func runReasoner(dbManager *database.DBManager, entailment reasoner.Entailment) error {
+	slog.Info("===Reasoner Rules===")
+	rules, err := reasonerRules()
 	if err != nil {
-		return nil, err
+		return err
 	}
-	/*
-		polSchema, err := fs.GetFile("schemas/query-schema.json")
+	for _, rule := range rules {
+		fPath, err := fs.GetFile(rule.File)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		execute := func() error {
+			if err := dbManager.ExecuteReasonerRule(fPath); err != nil {
+				return fmt.Errorf("could not execute reasoner rule: %s", err)
+			}
+			return nil
+		}
+		if !rule.Repeat {
+			err = execute()
+		} else {
+			err = runToFixpoint(dbManager, rule.File, rule.MaxIterations, execute)
 		}
-		yamlQueries, err := schema.ReadYAML(polFile, polSchema)
 		if err != nil {
-			return nil, err
+			return err
 		}
-	*/
-	yamlQueries, err := schema.ReadYAMLWithStringSchema(polFile, &schema.QUERY_SCHEMA)
+	}
+
+	if entailment == reasoner.NO_ENTAILMENT {
+		return nil
+	}
+	slog.Info("Materialising entailment", "entailment", entailment)
+	return runToFixpoint(dbManager, string(entailment), reasoner.ENTAILMENT_MAX_ITERATIONS, func() error {
+		for _, rules := range entailment.Rules() {
+			if err := dbManager.ExecuteUpdate(rules, fmt.Sprintf("%s entailment", entailment)); err != nil {
+				return fmt.Errorf("could not materialise entailment: %s", err)
+			}
+		}
+		return nil
+	})
+}
+
+// Reads the enabled policies of a regulation, layered as in `loadPolicies`
+//
+// `regulation`: The path to the regulation (relative to the regulations path)
+//
+// returns: the policies, or an error when the policies could not be read or do not abide by the schema
+func policyQueries(regulation string) ([]database.Query, error) {
+	layered, err := loadPolicies(regulation)
 	if err != nil {
 		return nil, err
 	}
+	enabled := util.Filter(layered, func(p *layeredPolicy) bool {
+		if p.DisabledBy != "" {
+			slog.Info("Policy disabled", "regulation", regulation, "policy", p.Title, "layer", p.DisabledBy)
+		}
+		return p.DisabledBy == ""
+	})
 
-	yamlQueriesList := yamlQueries.([]interface{})
-	queries := util.Map(yamlQueriesList, func(q1 interface{}) database.Query {
-		q := q1.(map[interface{}]interface{})
+	queries := util.Map(enabled, func(p *layeredPolicy) database.Query {
+		q := p.Entry
 		// format := q["format"].(map[interface{}]interface{})
 
 		qFile, err := fs.GetFile(q["file"].(string))
@@ -102,6 +165,9 @@ func policies(dbManager *database.DBManager, regulation string) ([]map[string]in
 		}
 		groupsRaw := q["groups"].([]interface{})
 		groups := util.Map(groupsRaw, func(raw interface{}) string { return raw.(string) })
+		severityName, _ := q["severity"].(string)
+		// the schema only allows known severities
+		severity, _ := util.ParseSeverity(severityName)
 		return database.NewQuery(
 			// fmt.Sprintf("./.%s/%s", appName, q["file"].(string)),
 			qFile,
@@ -112,13 +178,60 @@ func policies(dbManager *database.DBManager, regulation string) ([]map[string]in
 			q["mapping message"].(string),
 			q["clearence level"].(int),
 			groups,
+			p.tags(),
+			severity,
 		)
 	})
+	return queries, nil
+}
+
+// Runs a policy, its SPARQL query or, for Turtle files, its SHACL shapes
+//
+// `dbManager`: The DBManager connecting to the database
+//
+// `pol`: The policy
+//
+// returns: the violations or an error if the policy could not be run
+func runPolicy(dbManager *database.DBManager, pol database.Query) ([]map[string]interface{}, error) {
+	var res []map[string]interface{}
+	var err error
+	if strings.HasSuffix(pol.File, ".ttl") {
+		res, err = dbManager.ValidateShapes(pol.File)
+	} else {
+		res, err = dbManager.ExecuteQueryFile(pol.File)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error executing query from '%s': %s", pol.File, err)
+	}
+	return res, nil
+}
+
+// Runs all policies of a regulation
+//
+// # The returned report will contain the violations to each policy, or empty lists if they have none
+//
+// `dbManager`: The DBManager connecting to the database
+//
+// `regulation`: The path to the regulation (relative to the regulations path), whose policies are layered as in `loadPolicies`
+//
+// `sel`: The policies to run
+//
+// returns: the execution report if everything succeeds, or an error when the policy could not be read from the file, does not abide by the schema, or has execution errors
+func policies(dbManager *database.DBManager, regulation string, sel *selection) ([]map[string]interface{}, error) {
+	slog.Info("===Policy Compliance===")
+	queries, err := policyQueries(regulation)
+	if err != nil {
+		return nil, err
+	}
 	report := []map[string]interface{}{}
 	for _, pol := range queries {
-		res, err := dbManager.ExecuteQueryFile(pol.File)
+		if !sel.includesPolicy(pol) {
+			slog.Info("Skipping policy", "regulation", regulation, "policy", pol.Title)
+			continue
+		}
+		res, err := runPolicy(dbManager, pol)
 		if err != nil {
-			return nil, fmt.Errorf("error executing query from '%s': %s", pol.File, err)
+			return nil, err
 		}
 		// TODO: operate on the results
 		b, err := json.MarshalIndent(res, "", "  ")
@@ -135,6 +248,7 @@ func policies(dbManager *database.DBManager, regulation string) ([]map[string]in
 			"mapping message":    pol.MappingMessage,
 			"clearence level":    pol.ClearenceLvl,
 			"groups":             pol.Group,
+			"severity":           pol.Severity,
 		})
 	}
 
@@ -187,37 +301,50 @@ func attackTrees(dbManager *database.DBManager) ([]*attacktree.AttackTree, error
 	return report, nil
 }
 
+// Extracts the concrete attack paths of every executed tree
+//
+// `trees`: The executed attack/harm trees
+//
+// returns: the leaf-to-root paths of possible nodes of all trees
+func attackPaths(trees []*attacktree.AttackTree) []attacktree.AttackPath {
+	paths := []attacktree.AttackPath{}
+	for _, tree := range trees {
+		paths = append(paths, tree.PossiblePaths()...)
+	}
+	return paths
+}
+
+// A policy violation, unmet requirement or possible attack/harm
+type finding struct {
+	Name     string        // The regulation and title of the policy, the title of the requirement, or the description of the tree's root
+	Severity util.Severity // How serious it is
+}
+
 // Takes the report and validates whether the system has only acceptable flaws and can pass to the next steps of the pipeline
 //
 // `report`: the final report
 //
-// returns: the list of unacceptable violations, unmet requirements and possible attacks/harms
-func validateReport(report *map[string]interface{}) ([]string, []string, []string) {
+// returns: the unacceptable violations, unmet requirements and possible attacks/harms, with their severities
+func validateReport(report *map[string]interface{}) ([]finding, []finding, []finding) {
 	regulations := (*report)["policies"].([]interface{})
-	violatedPolicies := []string{}
-
-	/*
-		m, err := json.MarshalIndent(regulations, "", "  ")
-		if err != nil {
-			return []string{}
-		}
-		fmt.Println(string(m))
-	*/
+	violatedPolicies := []finding{}
 
 	for _, regulation := range regulations {
+		regulationName := (regulation.(map[string]interface{}))["name"].(string)
 		policies := (regulation.(map[string]interface{}))["results"]
 		for _, policy := range policies.([]map[string]interface{}) {
 			maxViolations := policy["maximum violations"].(int)
 			violations := len(policy["violations"].([]map[string]interface{}))
 
 			if violations > maxViolations {
-				violatedPolicies = append(violatedPolicies, policy["name"].(string))
+				name := fmt.Sprintf("%s/%s", regulationName, policy["name"].(string))
+				violatedPolicies = append(violatedPolicies, finding{name, policy["severity"].(util.Severity)})
 			}
 		}
 	}
 
 	userStories := (*report)["user stories"].(*[]map[string]interface{})
-	violatedRequirements := []string{}
+	violatedRequirements := []finding{}
 
 	if userStories != nil {
 		for _, us := range *userStories {
@@ -228,35 +355,63 @@ func validateReport(report *map[string]interface{}) ([]string, []string, []strin
 				res := req["results"].([]map[string]interface{})
 
 				if (len(res) == 0) != isMisuseCase {
-					violatedRequirements = append(violatedRequirements, req["title"].(string))
+					violatedRequirements = append(violatedRequirements, finding{req["title"].(string), req["severity"].(util.Severity)})
 				}
 			}
 		}
 	}
 
 	attackTrees := (*report)["attack trees"].([]*attacktree.AttackTree)
-	possibleAttacks := []string{}
+	possibleAttacks := []finding{}
 
 	for _, tree := range attackTrees {
 		root := tree.Root
 		isPossible := root.ExecutionStatus == attacktree.POSSIBLE
 
 		if isPossible {
-			possibleAttacks = append(possibleAttacks, root.Description)
+			possibleAttacks = append(possibleAttacks, finding{root.Description, root.Severity})
 		}
 	}
 
 	return violatedPolicies, violatedRequirements, possibleAttacks
 }
 
-// Runs the requirements queries to check whether or not the system supports the implementation of the requirements
+// Logs findings of a kind, as errors if they fail the analysis or as warnings otherwise
 //
-// Logs unmet requirements and met misuse cases.
+// `message`: The message introducing the findings
 //
-// `dbManager`: The DBManager connecting to the database
+// `findings`: The findings
 //
-// returns: the execution report if everything succeeds, or an error when the requirements could not be read from the file or does not abide by the schema, or the execution of a requirement was not cmopleted successfully
-func verifyRequirements(dbManager *database.DBManager) (*[]map[string]interface{}, error) {
+// `failOn`: The lowest severity that fails the analysis
+//
+// returns: whether any of the findings fails the analysis
+func logFindings(message string, findings []finding, failOn util.Severity) bool {
+	if len(findings) == 0 {
+		return false
+	}
+	fails := func(f finding) bool { return f.Severity >= failOn }
+	failing := util.Any(findings, fails)
+
+	if failing {
+		slog.Error(message)
+	} else {
+		slog.Warn(message)
+	}
+	for _, f := range findings {
+		line := fmt.Sprintf("\t- [%s] %s", f.Severity, f.Name)
+		if fails(f) {
+			slog.Error(line)
+		} else {
+			slog.Warn(line)
+		}
+	}
+	return failing
+}
+
+// Reads the user stories and their requirements from `requirements/requirements.yml`
+//
+// returns: the user stories, or an error when the file could not be read or does not abide by the schema
+func readUserStories() ([]*database.UserStory, error) {
 	requirementsFile, err := fs.GetFile("requirements/requirements.yml")
 	if err != nil {
 		return nil, err
@@ -280,6 +435,21 @@ func verifyRequirements(dbManager *database.DBManager) (*[]map[string]interface{
 	if err != nil {
 		return nil, err
 	}
+	return userStories, nil
+}
+
+// Runs the requirements queries to check whether or not the system supports the implementation of the requirements
+//
+// Logs unmet requirements and met misuse cases.
+//
+// `dbManager`: The DBManager connecting to the database
+//
+// returns: the execution report if everything succeeds, or an error when the requirements could not be read from the file or does not abide by the schema, or the execution of a requirement was not cmopleted successfully
+func verifyRequirements(dbManager *database.DBManager) (*[]map[string]interface{}, error) {
+	userStories, err := readUserStories()
+	if err != nil {
+		return nil, err
+	}
 
 	report := []map[string]interface{}{}
 	for _, us := range userStories {
@@ -317,6 +487,7 @@ func verifyRequirements(dbManager *database.DBManager) (*[]map[string]interface{
 					"title":       r.Title,
 					"description": r.Description,
 					"results":     res,
+					"severity":    r.Severity,
 				},
 			)
 		}
@@ -444,40 +615,49 @@ func sendReport(url string, report *map[string]interface{}, writeYaml bool) erro
 // `config`: The path from the local or global directory root to the configuration file to use
 //
 // `report`: The reference to the report structure that will be updated in this execution
-func analysisCycle(dbManager *database.DBManager, reportEndpoint string, config string, report *map[string]interface{}, writeYaml bool) error {
-	// 1. Load DFD into DB
-	if err := loadRepresentations(dbManager, "descriptions"); err != nil {
-		return err
-	}
-
-	// 2. Load and apply config
+//
+// `sel`: The regulations and policies to run
+//
+// `failOn`: The lowest severity of a finding that fails the analysis
+//
+// `entailment`: The built-in entailment rules to materialise before the policies run
+//
+// `loaded`: The files already in the database from previous cycles, updated with the ones this cycle loads
+//
+// returns: whether any finding fails the analysis, or an error if any of the phases fails
+func analysisCycle(dbManager *database.DBManager, reportEndpoint string, config string, report *map[string]interface{}, writeYaml bool, sel *selection, failOn util.Severity, entailment reasoner.Entailment, loaded graphCache) (bool, error) {
+	// 1. Read the config, load DFD into DB with the config applied and apply the config's substitutions
+	var plan *variant.Plan
 	if config != "" {
-		err := loadRep(dbManager, config, "")
-		if err != nil {
-			return err
-		}
-		_, err = dbManager.ApplyConfig()
-		if err != nil {
-			return err
+		var err error
+		if plan, err = variant.Load(config); err != nil {
+			return false, err
 		}
 	}
+	if err := loadGraphs(dbManager, config, plan, loaded); err != nil {
+		return false, err
+	}
 
 	// 2. Run all the reasoner rules
-	if err := reasoner(dbManager); err != nil {
-		return err
+	if err := runReasoner(dbManager, entailment); err != nil {
+		return false, err
 	}
 
 	// 3. Verify policy compliance
 	(*report)["policies"] = []interface{}{}
 	regulations, err := fs.GetRegulations()
 	if err != nil {
-		return err
+		return false, err
 	}
 	for _, regulation := range regulations {
+		if !sel.includesRegulation(regulation) {
+			slog.Info("Skipping regulation", "regulation", regulation)
+			continue
+		}
 		// reg := report["policies"].([]interface{})
-		polReport, err := policies(dbManager, regulation)
+		polReport, err := policies(dbManager, regulation, sel)
 		if err != nil {
-			return err
+			return false, err
 		}
 		(*report)["policies"] = append((*report)["policies"].([]interface{}), map[string]interface{}{
 			"name":    regulation,
@@ -489,9 +669,10 @@ func analysisCycle(dbManager *database.DBManager, reportEndpoint string, config
 	// 4. Run all attack trees
 	atkReport, err := attackTrees(dbManager)
 	if err != nil {
-		return err
+		return false, err
 	}
 	(*report)["attack trees"] = atkReport
+	(*report)["attack paths"] = attackPaths(atkReport)
 
 	// 5. Clean database
 	// dbManager.CleanDB()
@@ -513,14 +694,12 @@ func analysisCycle(dbManager *database.DBManager, reportEndpoint string, config
 
 	projDir, err := os.Getwd()
 	if err != nil {
-		return err
+		return false, err
 	}
 	projPath := strings.Split(projDir, "/")
 	projDir = projPath[len(projPath)-1]
 
-	cfgPath := strings.Split(config, "/")
-	cfgFile := cfgPath[len(cfgPath)-1]
-	cfgName := strings.Split(cfgFile, ".")[0]
+	cfgName := configName(config)
 
 	(*report)["branch"] = strings.Trim(branchOut.String(), "\n")
 	// report["time"] = commitOut.String()
@@ -538,29 +717,28 @@ func analysisCycle(dbManager *database.DBManager, reportEndpoint string, config
 	(*report)["user stories"] = usReport
 
 	// 8. Check whether the violatedPolicies are acceptable
+	failed := false
 	violatedPolicies, violatedRequirements, possibleAttacks := validateReport(report)
-	if len(violatedPolicies) != 0 {
-		slog.Error("There are policies with too many violations")
-		for _, v := range violatedPolicies {
-			slog.Error(fmt.Sprintf("\t- %s", v))
-		}
-		tooManyViolations = true
+	if logFindings("There are policies with too many violations", violatedPolicies, failOn) {
+		failed = true
 	}
 
-	if len(violatedRequirements) != 0 {
-		slog.Error("There are requirements with too many violations")
-		for _, v := range violatedRequirements {
-			slog.Error(fmt.Sprintf("\t- %s", v))
-		}
-		tooManyViolations = true
+	if logFindings("There are requirements with too many violations", violatedRequirements, failOn) {
+		failed = true
 	}
 
+	attacksFail := logFindings("There are possible attacks", possibleAttacks, failOn)
 	if len(possibleAttacks) != 0 {
-		slog.Error("There are possible attacks")
-		for _, v := range possibleAttacks {
-			slog.Error(fmt.Sprintf("\t- %s", v))
+		for _, path := range (*report)["attack paths"].([]attacktree.AttackPath) {
+			if attacksFail {
+				slog.Error(fmt.Sprintf("\t  %s", path))
+			} else {
+				slog.Warn(fmt.Sprintf("\t  %s", path))
+			}
 		}
-		tooManyViolations = true
+	}
+	if attacksFail {
+		failed = true
 	}
 	// 9. Get extra data
 	extraData, err := getExtraData(dbManager)
@@ -581,9 +759,7 @@ func analysisCycle(dbManager *database.DBManager, reportEndpoint string, config
 	}
 	reportFile := fmt.Sprintf("report.%s", reportExtension)
 	if config != "" {
-		cfgPath := strings.Split(config, "/")
-		cfgNameParts := strings.Split(cfgPath[len(cfgPath)-1], ".")
-		reportFile = fmt.Sprintf("report_%s.%s", cfgNameParts[0], reportExtension)
+		reportFile = fmt.Sprintf("report_%s.%s", cfgName, reportExtension)
 	}
 	slog.Info("Writing report", "to", reportFile)
 
@@ -593,20 +769,20 @@ func analysisCycle(dbManager *database.DBManager, reportEndpoint string, config
 			slog.Error("error parsing report:", "error", err)
 		}
 		if err := os.WriteFile(reportFile, []byte(yamlReport), 0666); err != nil {
-			return err
+			return false, err
 		}
 	} else {
 		if err := os.WriteFile(reportFile, []byte(jsonReport), 0666); err != nil {
-			return err
+			return false, err
 		}
 	}
 	if reportEndpoint != "" {
 		if err := sendReport(reportEndpoint, report, writeYaml); err != nil {
-			return err
+			return false, err
 		}
 	}
 
-	return nil
+	return failed, nil
 }
 
 // Main entry point for the `analyse` command
@@ -638,6 +814,22 @@ func Analyse(cmd *cobra.Command, args []string, write_yaml bool) error {
 	dataset := args[4]
 	reportEndpoint := cmd.Flag("report-endpoint").Value.String()
 
+	if err := verifyLock(); err != nil {
+		return err
+	}
+	sel, err := getSelection(cmd)
+	if err != nil {
+		return err
+	}
+	failOn, err := util.ParseSeverity(cmd.Flag("fail-on").Value.String())
+	if err != nil {
+		return fmt.Errorf("invalid '--fail-on': %s", err)
+	}
+	entailment, err := reasoner.ParseEntailment(cmd.Flag("entailment").Value.String())
+	if err != nil {
+		return fmt.Errorf("invalid '--entailment': %s", err)
+	}
+
 	dbManager := database.NewDBManager(
 		username,
 		password,
@@ -647,15 +839,29 @@ func Analyse(cmd *cobra.Command, args []string, write_yaml bool) error {
 	)
 	dbManager.CleanDB()
 
-	report := map[string]interface{}{}
-
 	configs, err := fs.GetConfigs()
 	if err != nil {
 		return err
 	}
+	configs, err = selectConfigs(configs, cmd)
+	if err != nil {
+		return err
+	}
+
+	if watching, _ := cmd.Flags().GetBool("watch"); watching {
+		if len(configs) > 1 {
+			return fmt.Errorf("'--watch' analyses a single configuration, choose it with '--config'")
+		}
+		config := ""
+		if len(configs) == 1 {
+			config = configs[0]
+		}
+		return watchAnalysis(&dbManager, config, sel, failOn, entailment)
+	}
 
 	if len(configs) == 0 {
-		err := analysisCycle(&dbManager, reportEndpoint, "", &report, write_yaml)
+		report := map[string]interface{}{}
+		failed, err := analysisCycle(&dbManager, reportEndpoint, "", &report, write_yaml, sel, failOn, entailment, graphCache{})
 		if err != nil {
 			return err
 		}
@@ -663,21 +869,70 @@ func Analyse(cmd *cobra.Command, args []string, write_yaml bool) error {
 		if err != nil {
 			return err
 		}
-	} else {
-		for _, config := range configs {
-			err := analysisCycle(&dbManager, reportEndpoint, config, &report, write_yaml)
-			if err != nil {
-				return err
-			}
-			_, err = dbManager.CleanDB()
-			if err != nil {
-				return err
-			}
+		if failed {
+			return fmt.Errorf("too many policy or requirement violations")
 		}
+		return nil
 	}
 
-	if tooManyViolations {
-		return fmt.Errorf("too many policy or requirement violations")
+	// the descriptions stay loaded across configurations, each cycle only reloads what its configuration changes
+	matrix := newConfigMatrix()
+	loaded := graphCache{}
+	for _, config := range configs {
+		report := map[string]interface{}{}
+		failed, err := analysisCycle(&dbManager, reportEndpoint, config, &report, write_yaml, sel, failOn, entailment, loaded)
+		if err != nil {
+			return err
+		}
+		matrix.add(configName(config), &report, failOn, !failed)
+	}
+	if _, err := dbManager.CleanDB(); err != nil {
+		return err
+	}
+
+	if err := matrix.print(os.Stdout); err != nil {
+		return err
+	}
+	slog.Info("Writing configuration matrix", "to", MATRIX_FILE)
+	if err := matrix.write(MATRIX_FILE); err != nil {
+		return err
+	}
+
+	failedConfigs := util.Filter(matrix.Configs, func(config string) bool { return !matrix.Passed[config] })
+	if len(failedConfigs) != 0 {
+		return fmt.Errorf("too many policy or requirement violations in configurations %s", strings.Join(failedConfigs, ", "))
 	}
 	return nil
 }
+
+// The name of a configuration, from its file name without extensions
+//
+// `config`: The path to the configuration file, or "" for none
+//
+// returns: the name, e.g. `prod` for `config/prod.yml`
+func configName(config string) string {
+	cfgPath := strings.Split(config, "/")
+	return strings.Split(cfgPath[len(cfgPath)-1], ".")[0]
+}
+
+// Keeps the configurations given with `--config`, or all of them if it was not given
+//
+// `configs`: The paths to the configuration files
+//
+// `cmd`: The cobra command, with the `config` flag
+//
+// returns: the selected configurations, or an error if any given name matches no configuration
+func selectConfigs(configs []string, cmd *cobra.Command) ([]string, error) {
+	names, err := cmd.Flags().GetStringSlice("config")
+	if err != nil || len(names) == 0 {
+		return configs, nil
+	}
+	for _, name := range names {
+		if !util.Any(configs, func(config string) bool { return configName(config) == name }) {
+			return nil, fmt.Errorf("unknown configuration '%s', the available ones are: %s", name, strings.Join(util.Map(configs, configName), ", "))
+		}
+	}
+	return util.Filter(configs, func(config string) bool {
+		return util.Any(names, func(name string) bool { return configName(config) == name })
+	}), nil
+}
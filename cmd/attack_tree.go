@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"fmt"
+
+	attacktree "github.com/Joao-Felisberto/devprivops/attack_tree"
+	"github.com/spf13/cobra"
+)
+
+// Main entry point for the `attack-tree render` command.
+// Prints the diagram of an attack/harm tree, optionally coloured by the execution status from a report.
+//
+// `cmd`: The cobra command
+//
+// `args`: The args of said command
+//
+// `format`: The diagram format, one of `dot`, `mermaid` or `plantuml`
+//
+// `reportFile`: The JSON report whose execution status to overlay, or "" for none
+//
+// returns: an error if the tree or report could not be read or the format is not supported
+func RenderAttackTree(cmd *cobra.Command, args []string, format string, reportFile string) error {
+	tree, err := attacktree.NewAttackTreeFromYaml(args[0])
+	if err != nil {
+		return err
+	}
+
+	withStatus := reportFile != ""
+	if withStatus {
+		if err := tree.OverlayReport(reportFile); err != nil {
+			return err
+		}
+	}
+
+	diagram, err := attacktree.Render(tree, attacktree.RenderFormat(format), withStatus)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(diagram)
+	return nil
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/Joao-Felisberto/devprivops/dfd"
+	"github.com/spf13/cobra"
+)
+
+// Main entry point for the `dfd render` command.
+// Prints the diagram of a data flow diagram description, optionally highlighting the entities of a report's violations.
+//
+// `cmd`: The cobra command
+//
+// `args`: The args of said command
+//
+// `format`: The diagram format, one of `dot`, `mermaid` or `svg`
+//
+// `reportFile`: The JSON report whose violations to highlight, or "" for none
+//
+// returns: an error if the description or report could not be read or the diagram could not be rendered
+func RenderDFD(cmd *cobra.Command, args []string, format string, reportFile string) error {
+	diagram, err := dfd.NewDataFlowDiagramFromYaml(args[0])
+	if err != nil {
+		return err
+	}
+
+	var highlight map[string]bool
+	if reportFile != "" {
+		highlight, err = dfd.ViolatingEntities(reportFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	out, err := diagram.Render(dfd.RenderFormat(format), highlight)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(out)
+	return nil
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Joao-Felisberto/devprivops/database"
+	"github.com/Joao-Felisberto/devprivops/fs"
+	"github.com/Joao-Felisberto/devprivops/util"
+	"github.com/spf13/cobra"
+)
+
+// Writes the layer a file is read from and the layers it hides
+//
+// `w`: Where to write the line
+//
+// `item`: What to show in the item column
+//
+// `relativePath`: The file, relative to the root of each layer
+func explainFile(w io.Writer, item string, relativePath string) {
+	layers := fs.GetFileLayers(relativePath)
+	if len(layers) == 0 {
+		return
+	}
+	note := ""
+	if len(layers) > 1 {
+		note = fmt.Sprintf("overrides %s", strings.Join(util.Map(layers[1:], func(l fs.Layer) string { return l.Name }), ", "))
+	}
+	fmt.Fprintf(w, "%s\t%s\t%s\n", item, layers[0].Name, note)
+}
+
+// Main entry point for the `explain-config` command.
+// Shows which layer every file and policy an analysis would use comes from.
+//
+// `cmd`: The cobra command
+//
+// `args`: The args of said command
+//
+// returns: an error if the regulations or their policies cannot be read
+func ExplainConfig(cmd *cobra.Command, args []string) error {
+	sel, err := getSelection(cmd)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ITEM\tLAYER\tNOTE")
+
+	for _, file := range []string{"uris.yml", "requirements/requirements.yml", "report_data/report_data.yml"} {
+		explainFile(w, file, file)
+	}
+
+	files := []string{}
+	if descriptions, err := fs.GetDescriptions("descriptions"); err == nil {
+		files = append(files, descriptions...)
+	}
+	if configs, err := fs.GetConfigs(); err == nil {
+		files = append(files, configs...)
+	}
+	if ontologies, err := fs.GetOntologies(); err == nil {
+		files = append(files, ontologies...)
+	}
+	for _, dir := range []string{"reasoner", "attack_trees/descriptions"} {
+		if dirFiles, err := listDir(dir); err == nil {
+			files = append(files, dirFiles...)
+		}
+	}
+	explained := map[string]bool{}
+	for _, file := range files {
+		if !explained[file] {
+			explained[file] = true
+			explainFile(w, file, file)
+		}
+	}
+
+	regulations, err := fs.GetRegulations()
+	if err != nil {
+		return err
+	}
+	for _, r := range regulations {
+		relativePath := fmt.Sprintf("regulations/%s", r)
+		layers := util.Map(fs.GetFileLayers(relativePath), func(l fs.Layer) string { return l.Name })
+		if !sel.includesRegulation(r) {
+			fmt.Fprintf(w, "%s\t%s\tskipped by selection\n", relativePath, layers[0])
+			continue
+		}
+		note := ""
+		if len(layers) > 1 {
+			note = fmt.Sprintf("layered over %s", strings.Join(layers[1:], ", "))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", relativePath, layers[0], note)
+
+		policies, err := loadPolicies(r)
+		if err != nil {
+			return err
+		}
+		for _, p := range policies {
+			notes := []string{}
+			if len(p.Overrides) != 0 {
+				notes = append(notes, fmt.Sprintf("overrides %s", strings.Join(p.Overrides, ", ")))
+			}
+			switch {
+			case p.Entry == nil:
+				notes = append(notes, fmt.Sprintf("disabled by %s, but not defined by any lower layer", p.DisabledBy))
+			case p.DisabledBy != "":
+				notes = append(notes, fmt.Sprintf("disabled by %s", p.DisabledBy))
+			case !sel.includesPolicy(database.Query{Title: p.Title, Tags: p.tags()}):
+				notes = append(notes, "skipped by selection")
+			}
+			fmt.Fprintf(w, "  %s\t%s\t%s\n", p.Title, p.Layer, strings.Join(notes, "; "))
+		}
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,240 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Joao-Felisberto/devprivops/database"
+	"github.com/Joao-Felisberto/devprivops/fs"
+	"github.com/Joao-Felisberto/devprivops/reasoner"
+	"github.com/Joao-Felisberto/devprivops/util"
+	"github.com/Joao-Felisberto/devprivops/variant"
+	"github.com/spf13/cobra"
+)
+
+// The formats the graph can be exported in
+const (
+	TURTLE    = "ttl"
+	N_TRIPLES = "nt"
+	JSON_LD   = "jsonld"
+)
+
+// Main entry point for the `graph export` command.
+// Builds the knowledge graph the analysis runs on and writes it out.
+// Given the database arguments, the descriptions are loaded into the triple store, the selected configuration is applied and the reasoner runs;
+// without them, the graph is built in memory from the YAML and N-Triples descriptions, without reasoning.
+//
+// `cmd`: The cobra command
+//
+// `args`: The args of said command, none or the database's username, password, ip, port and dataset
+//
+// returns: an error if the format or configuration is not known, building the graph fails or it cannot be written
+func GraphExport(cmd *cobra.Command, args []string) error {
+	format := cmd.Flag("format").Value.String()
+	if format != TURTLE && format != N_TRIPLES && format != JSON_LD {
+		return fmt.Errorf("unknown format '%s', expected one of %s, %s or %s", format, TURTLE, N_TRIPLES, JSON_LD)
+	}
+	entailment, err := reasoner.ParseEntailment(cmd.Flag("entailment").Value.String())
+	if err != nil {
+		return fmt.Errorf("invalid '--entailment': %s", err)
+	}
+	diff, err := cmd.Flags().GetBool("diff-before-reasoner")
+	if err != nil {
+		return err
+	}
+	config, err := exportedConfig(cmd.Flag("config").Value.String())
+	if err != nil {
+		return err
+	}
+	var plan *variant.Plan
+	if config != "" {
+		if plan, err = variant.Load(config); err != nil {
+			return err
+		}
+	}
+
+	var triples []database.RDFTriple
+	if len(args) == 0 {
+		if diff {
+			return fmt.Errorf("'--diff-before-reasoner' needs a triple store to run the reasoner on")
+		}
+		if entailment != reasoner.NO_ENTAILMENT {
+			slog.Warn("The reasoner only runs in a triple store, the entailment is ignored", "entailment", entailment)
+		}
+		triples, err = offlineGraph(config, plan)
+	} else {
+		var port int
+		if port, err = strconv.Atoi(args[3]); err != nil {
+			return err
+		}
+		dbManager := database.NewDBManager(args[0], args[1], args[2], port, args[4])
+		triples, err = storeGraph(&dbManager, config, plan, entailment, diff)
+	}
+	if err != nil {
+		return err
+	}
+
+	uriMetadata, err := getURIMetadata()
+	if err != nil {
+		return err
+	}
+	namespaces := database.Namespaces(*uriMetadata)
+
+	var out io.Writer = os.Stdout
+	if output := cmd.Flag("output").Value.String(); output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("could not create '%s': %s", output, err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	switch format {
+	case N_TRIPLES:
+		return database.WriteNTriples(out, triples)
+	case JSON_LD:
+		return database.WriteJSONLD(out, triples, namespaces)
+	default:
+		return database.WriteTurtle(out, triples, namespaces)
+	}
+}
+
+// Finds the configuration to export the graph with
+//
+// `name`: The name of the configuration, e.g. 'prod' for 'config/prod.yml', or "" for none
+//
+// returns: the configuration file, "" if no name was given, or an error if no configuration has that name
+func exportedConfig(name string) (string, error) {
+	if name == "" {
+		return "", nil
+	}
+	configs, err := fs.GetConfigs()
+	if err != nil {
+		return "", err
+	}
+	for _, config := range configs {
+		if configName(config) == name {
+			return config, nil
+		}
+	}
+	return "", fmt.Errorf("unknown configuration '%s', the available ones are: %s", name, strings.Join(util.Map(configs, configName), ", "))
+}
+
+// Builds the graph in the triple store, as the analysis does, and reads it back
+//
+// `dbManager`: The DBManager connecting to the database
+//
+// `config`: The configuration file, or "" for none
+//
+// `plan`: The configuration, or nil for none
+//
+// `entailment`: The built-in entailment to run after the reasoner rules
+//
+// `diff`: Whether to return only the triples the reasoner added
+//
+// returns: the triples or an error if loading the descriptions, reasoning or reading the graph fails
+func storeGraph(dbManager *database.DBManager, config string, plan *variant.Plan, entailment reasoner.Entailment, diff bool) ([]database.RDFTriple, error) {
+	if _, err := dbManager.CleanDB(); err != nil {
+		return nil, err
+	}
+	defer dbManager.CleanDB()
+
+	if err := loadGraphs(dbManager, config, plan, graphCache{}); err != nil {
+		return nil, err
+	}
+
+	var before []database.RDFTriple
+	if diff {
+		var err error
+		if before, err = dbManager.Graph(); err != nil {
+			return nil, err
+		}
+	}
+	if err := runReasoner(dbManager, entailment); err != nil {
+		return nil, err
+	}
+	after, err := dbManager.Graph()
+	if err != nil {
+		return nil, err
+	}
+	if diff {
+		return database.DiffTriples(after, before), nil
+	}
+	return after, nil
+}
+
+// Builds the graph in memory from the YAML and N-Triples descriptions, with the configuration applied.
+// Other RDF descriptions and the ontologies would need a parser for their format, so they are left out with a warning.
+//
+// `config`: The configuration file, or "" for none
+//
+// `plan`: The configuration, or nil for none
+//
+// returns: the triples or an error if reading any description or applying the configuration fails
+func offlineGraph(config string, plan *variant.Plan) ([]database.RDFTriple, error) {
+	entries, err := fs.GetDescriptions("descriptions")
+	if err != nil {
+		return nil, fmt.Errorf("error fetching description: %s", err)
+	}
+
+	triples := []database.RDFTriple{}
+	for _, e := range entries {
+		if contentType, isRDF := database.RDFContentType(e); isRDF {
+			if contentType != "application/n-triples" {
+				slog.Warn("Only N-Triples descriptions can be exported without a triple store, skipping", "file", e)
+				continue
+			}
+			file, err := fs.GetFile(e)
+			if err != nil {
+				return nil, err
+			}
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("could not read '%s': %s", e, err)
+			}
+			fileTriples, err := database.ParseNTriples(string(data))
+			if err != nil {
+				return nil, fmt.Errorf("'%s': %s", e, err)
+			}
+			triples = append(triples, fileTriples...)
+			continue
+		}
+
+		rep, err := readRep(e, descriptionSchema(e), plan)
+		if err != nil {
+			return nil, err
+		}
+		yamlTriples, _, err := repTriples(e, rep)
+		if err != nil {
+			return nil, err
+		}
+		triples = append(triples, database.TriplesFromSchema(yamlTriples)...)
+	}
+
+	if ontologies, err := fs.GetOntologies(); err == nil && len(ontologies) > 0 {
+		slog.Warn("Ontologies can only be exported with a triple store, skipping them", "ontologies", len(ontologies))
+	}
+	if rules, err := reasonerRules(); err == nil && len(rules) > 0 {
+		slog.Warn("The reasoner only runs in a triple store, its inferences are not exported", "rules", len(rules))
+	}
+
+	if plan == nil {
+		return triples, nil
+	}
+	for _, op := range plan.Unmatched() {
+		slog.Warn("Configuration operation changed no description", "config", config, "operation", op)
+	}
+	if len(plan.Config) == 0 {
+		return triples, nil
+	}
+	configTriples, _, err := repTriples(config, map[interface{}]interface{}{"config": plan.Config})
+	if err != nil {
+		return nil, err
+	}
+	return database.ApplyConfigTriples(append(triples, database.TriplesFromSchema(configTriples)...)), nil
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Joao-Felisberto/devprivops/dfd"
+	"github.com/Joao-Felisberto/devprivops/fs"
+	"github.com/Joao-Felisberto/devprivops/schema"
+	"github.com/Joao-Felisberto/devprivops/util"
+	"github.com/spf13/cobra"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// Main entry point for the `import` command.
+// Converts a threat model from another tool into a `.dfd.yml` description, validated against `schemas/dfd-schema.json`
+// or the built-in schema if no configuration directory has one, and only written if it is valid.
+//
+// `cmd`: The cobra command
+//
+// `args`: The args of said command, the tool the model comes from and the model file
+//
+// `output`: The file to write the description to, or "" to write it to the local `descriptions` directory
+//
+// `force`: Whether to overwrite an existing description
+//
+// returns: an error if the model could not be read, the description already exists, does not abide by the schema or could not be written
+func Import(cmd *cobra.Command, args []string, output string, force bool) error {
+	tool := args[0]
+	modelFile := args[1]
+
+	if output == "" {
+		name := strings.TrimSuffix(filepath.Base(modelFile), filepath.Ext(modelFile))
+		output = fmt.Sprintf("%s/descriptions/%s.dfd.yml", fs.LocalDir, name)
+	}
+	if _, err := os.Stat(output); err == nil && !force {
+		return fmt.Errorf("'%s' already exists, use '--force' to overwrite it", output)
+	}
+
+	var desc *dfd.Description
+	var err error
+	switch tool {
+	case "threat-dragon":
+		desc, err = dfd.ImportThreatDragon(modelFile)
+	case "tmt":
+		desc, err = dfd.ImportTMT(modelFile)
+	default:
+		return fmt.Errorf("tool '%s' not supported, valid possibilities: [threat-dragon, tmt]", tool)
+	}
+	if err != nil {
+		return err
+	}
+
+	data, err := desc.ToYAML()
+	if err != nil {
+		return err
+	}
+	if err := validateImport(data); err != nil {
+		return err
+	}
+
+	if err := util.CreateFileWithData(output, string(data)); err != nil {
+		return err
+	}
+	slog.Info("Description written", "file", output, "annotations", len(desc.Annotations))
+	return nil
+}
+
+// Checks an imported description against `schemas/dfd-schema.json`, or the built-in schema if no configuration directory has one
+//
+// `data`: The description
+//
+// returns: an error if the description could not be checked or does not abide by the schema
+func validateImport(data []byte) error {
+	tmp, err := os.CreateTemp("", "import-*.dfd.yml")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	var res *gojsonschema.Result
+	if schemaFile, err := fs.GetFile("schemas/dfd-schema.json"); err == nil {
+		res, err = schema.ValidateYAMLAgainstSchemaFile(tmp.Name(), schemaFile)
+		if err != nil {
+			return err
+		}
+	} else if res, err = schema.ValidateYAMLAgainstSchemaString(tmp.Name(), &schema.DFD_SCHEMA); err != nil {
+		return err
+	}
+	if !res.Valid() {
+		return fmt.Errorf("the imported description does not abide by the schema: %s", res.Errors())
+	}
+	return nil
+}
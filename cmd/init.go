@@ -0,0 +1,249 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Joao-Felisberto/devprivops/dfd"
+	"github.com/Joao-Felisberto/devprivops/fs"
+	"github.com/Joao-Felisberto/devprivops/schema"
+	"github.com/Joao-Felisberto/devprivops/util"
+	"github.com/spf13/cobra"
+)
+
+// The default `uris.yml`, mapping each description type to its base URI
+const defaultURIs = `- abreviation: dfd
+  uri: https://devprivops.com/dfd
+  files:
+    - .*\.dfd\.yml
+- abreviation: cfg
+  uri: https://devprivops.com/config
+  files:
+    - .*config/.*.yml
+    - tests/.*/config.yml
+`
+
+// The directories every project needs, even if empty
+var skeletonDirs = []string{
+	"config",
+	"descriptions",
+	"reasoner",
+	"regulations",
+	"attack_trees/descriptions",
+	"attack_trees/queries",
+}
+
+// Builds the `yaml-language-server` header that points editors to a schema
+//
+// `file`: The file the header is for, relative to the local directory
+//
+// `schemaFile`: The schema, relative to the local directory
+//
+// returns: the header line
+func schemaHeader(file string, schemaFile string) string {
+	rel, err := filepath.Rel(filepath.Dir(filepath.Join(fs.LocalDir, file)), filepath.Join(fs.LocalDir, schemaFile))
+	if err != nil {
+		rel = schemaFile
+	}
+	return fmt.Sprintf("# yaml-language-server: $schema=%s\n", rel)
+}
+
+// Writes a file of the skeleton unless it already exists
+//
+// `file`: The path relative to the local directory
+//
+// `data`: The contents of the file
+//
+// `force`: Whether to overwrite existing files
+//
+// returns: an error if the file could not be written
+func writeSkeletonFile(file string, data string, force bool) error {
+	path := fmt.Sprintf("%s/%s", fs.LocalDir, file)
+	if _, err := os.Stat(path); err == nil && !force {
+		slog.Warn("File already exists, skipping", "file", path)
+		return nil
+	}
+	slog.Info("Creating", "file", path)
+	return util.CreateFileWithData(path, data)
+}
+
+// Copies every file under the `templates` directory of the global directory into the local directory
+//
+// `force`: Whether to overwrite existing files
+//
+// returns: the files the templates have, relative to the local directory, or an error if the templates could not be read or written
+func copyTemplates(force bool) (map[string]bool, error) {
+	copied := map[string]bool{}
+	templateDir := fmt.Sprintf("%s/templates", fs.GlobalDir)
+	if _, err := os.Stat(templateDir); errors.Is(err, os.ErrNotExist) {
+		slog.Warn("No templates in the global directory", "dir", templateDir)
+		return copied, nil
+	}
+
+	return copied, filepath.WalkDir(templateDir, func(path string, d iofs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(templateDir, path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("error reading template '%s': %s", path, err)
+		}
+		copied[filepath.ToSlash(rel)] = true
+		return writeSkeletonFile(rel, string(data), force)
+	})
+}
+
+// Asks the user a question and reads a comma separated list as the answer
+//
+// `reader`: Where to read the answer from
+//
+// `question`: The question to ask
+//
+// returns: the non empty items of the answer or an error if reading fails
+func askList(reader *bufio.Reader, question string) ([]string, error) {
+	fmt.Printf("%s (comma separated): ", question)
+	line, err := reader.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	items := []string{}
+	for _, item := range strings.Split(line, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// Asks the user about the system's entities and flows and builds a data flow diagram description from the answers
+//
+// `in`: Where to read the answers from
+//
+// returns: the description or an error if reading the answers fails
+func askDescription(in io.Reader) (*dfd.Description, error) {
+	reader := bufio.NewReader(in)
+
+	externalEntities, err := askList(reader, "External entities, e.g. users or third party services")
+	if err != nil {
+		return nil, err
+	}
+	processes, err := askList(reader, "Processes")
+	if err != nil {
+		return nil, err
+	}
+	dataStores, err := askList(reader, "Data stores")
+	if err != nil {
+		return nil, err
+	}
+	flowsRaw, err := askList(reader, "Data flows, as 'source -> destination'")
+	if err != nil {
+		return nil, err
+	}
+
+	flows := [][2]string{}
+	for _, f := range flowsRaw {
+		ends := strings.Split(f, "->")
+		if len(ends) != 2 {
+			slog.Warn("Ignoring data flow without a single '->'", "flow", f)
+			continue
+		}
+		flows = append(flows, [2]string{strings.TrimSpace(ends[0]), strings.TrimSpace(ends[1])})
+	}
+
+	return dfd.NewDescriptionFromNames(externalEntities, processes, dataStores, flows), nil
+}
+
+// Main entry point for the `init` command.
+// Creates the minimal local directory needed by the other commands.
+//
+// `cmd`: The cobra command
+//
+// `args`: The args of said command, optionally the project name
+//
+// `interactive`: Whether to ask the user about the system to write its first description
+//
+// `templates`: Whether to copy the templates from the global directory
+//
+// `force`: Whether to overwrite existing files
+//
+// returns: an error if any file could not be written
+func Init(cmd *cobra.Command, args []string, interactive bool, templates bool, force bool) error {
+	name := ""
+	if len(args) > 0 {
+		name = args[0]
+	} else {
+		wd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		name = filepath.Base(wd)
+	}
+
+	for _, dir := range skeletonDirs {
+		if err := os.MkdirAll(fmt.Sprintf("%s/%s", fs.LocalDir, dir), os.ModePerm); err != nil {
+			return err
+		}
+	}
+
+	// Templates go first, the minimal files below do not replace them even with `force`
+	copied := map[string]bool{}
+	if templates {
+		var err error
+		if copied, err = copyTemplates(force); err != nil {
+			return err
+		}
+	}
+
+	descFile := fmt.Sprintf("descriptions/%s.dfd.yml", name)
+	files := [][2]string{
+		{"schemas/atk-tree-schema.json", schema.ATK_TREE_SCHEMA},
+		{"schemas/dfd-schema.json", schema.DFD_SCHEMA},
+		{"schemas/query-schema.json", schema.QUERY_SCHEMA},
+		{"schemas/report_data-schema.json", schema.REPORT_DATA_SCHEMA},
+		{"schemas/requirement-schema.json", schema.REQUIREMENT_SCHEMA},
+		{"uris.yml", defaultURIs},
+		{"requirements/requirements.yml", schemaHeader("requirements/requirements.yml", "schemas/requirement-schema.json") + "[]\n"},
+		{"report_data/report_data.yml", schemaHeader("report_data/report_data.yml", "schemas/report_data-schema.json") + "[]\n"},
+		{"tests/spec.json", "[]\n"},
+	}
+	for _, f := range files {
+		if copied[f[0]] {
+			continue
+		}
+		if err := writeSkeletonFile(f[0], f[1], force); err != nil {
+			return err
+		}
+	}
+	if !copied[descFile] {
+		desc := dfd.NewDescriptionFromNames([]string{}, []string{}, []string{}, [][2]string{})
+		if interactive {
+			var err error
+			desc, err = askDescription(os.Stdin)
+			if err != nil {
+				return err
+			}
+		}
+		descData, err := desc.ToYAML()
+		if err != nil {
+			return err
+		}
+		header := schemaHeader(descFile, "schemas/dfd-schema.json") + "\n"
+		if err := writeSkeletonFile(descFile, header+string(descData), force); err != nil {
+			return err
+		}
+	}
+
+	slog.Info("Project initialized", "dir", fs.LocalDir)
+	return nil
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+
+	"github.com/Joao-Felisberto/devprivops/fs"
+	"github.com/Joao-Felisberto/devprivops/schema"
+	"github.com/Joao-Felisberto/devprivops/util"
+)
+
+// A policy of a regulation after layering the `policies.yml` of every layer
+type layeredPolicy struct {
+	Title      string                      // The policy title, which identifies it across layers
+	Entry      map[interface{}]interface{} // The policy as defined by the layer in effect, nil if only ever disabled
+	Layer      string                      // The layer whose definition is in effect
+	Overrides  []string                    // The lower layers whose definitions were replaced
+	DisabledBy string                      // The layer that disabled the policy, or "" if it is enabled
+}
+
+// Layers the `policies.yml` of a regulation, from the lowest to the highest precedence layer.
+// A policy with the title of a lower layer's policy replaces it, one with `disabled: true` disables it,
+// and any other policy is added to the regulation.
+// Every SHACL shapes file under `shapes/` is a policy below all layers, titled after the file, unless a policy runs that file.
+//
+// `regulation`: The regulation name
+//
+// returns: the policies in the order they were first defined, or an error if any `policies.yml` cannot be read or does not abide by the schema
+func loadPolicies(regulation string) ([]*layeredPolicy, error) {
+	relativePath := fmt.Sprintf("regulations/%s/policies.yml", regulation)
+	layers := fs.GetFileLayers(relativePath)
+	if len(layers) == 0 {
+		return nil, fmt.Errorf("regulation '%s' has no 'policies.yml'", regulation)
+	}
+
+	policies := []*layeredPolicy{}
+	byTitle := map[string]*layeredPolicy{}
+
+	shapes, err := fs.GetShapes(regulation)
+	if err != nil {
+		return nil, err
+	}
+	shapePolicies := map[*layeredPolicy]bool{}
+	for _, file := range shapes {
+		policy := &layeredPolicy{
+			Title:     strings.TrimSuffix(filepath.Base(file), ".ttl"),
+			Entry:     shapesEntry(file),
+			Layer:     fs.GetFileLayers(file)[0].Name,
+			Overrides: []string{},
+		}
+		shapePolicies[policy] = true
+		byTitle[policy.Title] = policy
+		policies = append(policies, policy)
+	}
+
+	for i := len(layers) - 1; i >= 0; i-- {
+		layer := layers[i]
+		raw, err := schema.ReadYAMLWithStringSchema(fmt.Sprintf("%s/%s", layer.Dir, relativePath), &schema.QUERY_SCHEMA)
+		if err != nil {
+			return nil, err
+		}
+		entries, _ := raw.([]interface{})
+		for _, e := range entries {
+			entry := e.(map[interface{}]interface{})
+			title := entry["title"].(string)
+			disabled, _ := entry["disabled"].(bool)
+
+			policy, exists := byTitle[title]
+			if !exists {
+				policy = &layeredPolicy{Title: title, Overrides: []string{}}
+				byTitle[title] = policy
+				policies = append(policies, policy)
+			}
+
+			if disabled {
+				if policy.Entry == nil {
+					slog.Warn("Disabling a policy no lower layer defines", "regulation", regulation, "policy", title, "layer", layer.Name)
+					policy.Layer = layer.Name
+				}
+				policy.DisabledBy = layer.Name
+				continue
+			}
+			if policy.Entry != nil {
+				policy.Overrides = append(policy.Overrides, policy.Layer)
+			}
+			policy.Entry = entry
+			policy.Layer = layer.Name
+			policy.DisabledBy = ""
+			delete(shapePolicies, policy)
+		}
+	}
+
+	runFiles := map[string]bool{}
+	for _, p := range policies {
+		if !shapePolicies[p] && p.Entry != nil {
+			runFiles[p.Entry["file"].(string)] = true
+		}
+	}
+	return util.Filter(policies, func(p *layeredPolicy) bool {
+		return !shapePolicies[p] || !runFiles[p.Entry["file"].(string)]
+	}), nil
+}
+
+// The policy entry of a SHACL shapes file no `policies.yml` describes
+//
+// `file`: The shapes file, relative to the local or global directory
+//
+// returns: the entry, a consistency policy that allows no violations
+func shapesEntry(file string) map[interface{}]interface{} {
+	return map[interface{}]interface{}{
+		"file":               file,
+		"title":              strings.TrimSuffix(filepath.Base(file), ".ttl"),
+		"description":        fmt.Sprintf("The SHACL shapes in '%s'", file),
+		"is consistency":     true,
+		"maximum violations": 0,
+		"mapping message":    "",
+		"clearence level":    0,
+		"groups":             []interface{}{},
+	}
+}
+
+// The tags of the policy in effect
+//
+// returns: the tags, empty if the policy has none or was never defined
+func (p *layeredPolicy) tags() []string {
+	tagsRaw, ok := p.Entry["tags"].([]interface{})
+	if !ok {
+		return []string{}
+	}
+	return util.Map(tagsRaw, func(raw interface{}) string { return raw.(string) })
+}
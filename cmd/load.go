@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/Joao-Felisberto/devprivops/database"
+	"github.com/Joao-Felisberto/devprivops/fs"
+	"github.com/Joao-Felisberto/devprivops/schema"
+	"github.com/Joao-Felisberto/devprivops/variant"
+)
+
+// The content hash of what was loaded into the named graph of each file, by the IRI of the graph.
+// It is kept across the configurations of an analysis and the reloads of a watched one, so that files that load the same triples are only loaded once.
+type graphCache map[string]string
+
+// Loads the descriptions and ontologies into the triple store and applies a configuration, as every command does before reasoning.
+// Each description and ontology has its own named graph and only the graphs of files whose content changed since they were last loaded are replaced, then the default graph,
+// which the reasoner and policies run on, is rebuilt from them and the configuration's substitutions.
+//
+// `dbManager`: The DBManager connecting to the database
+//
+// `config`: The configuration file, or "" for none
+//
+// `plan`: The configuration, or nil for none
+//
+// `loaded`: The graphs already in the database, updated with the ones loaded
+//
+// returns: an error if reading any file or updating the database fails
+func loadGraphs(dbManager *database.DBManager, config string, plan *variant.Plan, loaded graphCache) error {
+	// anonymous entities get the same ids in every configuration, so that their descriptions load the same triples
+	schema.SetIDCounter(0)
+
+	descriptions, err := fs.GetDescriptions("descriptions")
+	if err != nil {
+		return fmt.Errorf("error fetching description: %s", err)
+	}
+	ontologies, err := fs.GetOntologies()
+	if err != nil {
+		return fmt.Errorf("error fetching ontologies: %s", err)
+	}
+
+	graphs := []string{}
+	current := map[string]bool{}
+	for _, file := range append(descriptions, ontologies...) {
+		if err := loadGraph(dbManager, file, plan, loaded); err != nil {
+			return err
+		}
+		graphs = append(graphs, database.GraphIRI(file))
+		current[database.GraphIRI(file)] = true
+	}
+	for graph := range loaded {
+		if !current[graph] {
+			if err := dbManager.DropGraph(graph); err != nil {
+				return err
+			}
+			delete(loaded, graph)
+		}
+	}
+
+	substitutions := false
+	if plan != nil {
+		for _, op := range plan.Unmatched() {
+			slog.Warn("Configuration operation changed no description", "config", config, "operation", op)
+		}
+		configTriples := []database.RDFTriple{}
+		if len(plan.Config) > 0 {
+			triples, _, err := repTriples(config, map[interface{}]interface{}{"config": plan.Config})
+			if err != nil {
+				return err
+			}
+			configTriples = database.TriplesFromSchema(triples)
+		}
+		if err := dbManager.ReplaceGraph(database.CONFIG_GRAPH, configTriples); err != nil {
+			return err
+		}
+		if substitutions = len(configTriples) > 0; substitutions {
+			graphs = append(graphs, database.CONFIG_GRAPH)
+		}
+	}
+
+	if err := dbManager.CopyToDefault(graphs); err != nil {
+		return err
+	}
+	if substitutions {
+		if _, err := dbManager.ApplyConfig(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Loads a description or ontology into its named graph, unless it loads the same content as when it was last loaded
+//
+// `dbManager`: The DBManager connecting to the database
+//
+// `file`: The description or ontology
+//
+// `plan`: The configuration to apply to YAML descriptions, or nil for none
+//
+// `loaded`: The graphs already in the database, updated with this one
+//
+// returns: an error if reading the file or updating the database fails
+func loadGraph(dbManager *database.DBManager, file string, plan *variant.Plan, loaded graphCache) error {
+	graph := database.GraphIRI(file)
+
+	if contentType, isRDF := database.RDFContentType(file); isRDF {
+		data, err := readRDF(file, contentType)
+		if err != nil {
+			return err
+		}
+		hash := contentHash(data)
+		if loaded[graph] == hash {
+			slog.Debug("Keeping unchanged file", "file", file)
+			return nil
+		}
+		delete(loaded, graph)
+		slog.Debug("Loading RDF", "file", file, "format", contentType)
+		if err := dbManager.UploadGraph(graph, data, contentType, file); err != nil {
+			return err
+		}
+		loaded[graph] = hash
+		return nil
+	}
+
+	rep, err := readRep(file, descriptionSchema(file), plan)
+	if err != nil {
+		return err
+	}
+	schemaTriples, _, err := repTriples(file, rep)
+	if err != nil {
+		return err
+	}
+	return replaceFileGraph(dbManager, file, database.TriplesFromSchema(schemaTriples), loaded)
+}
+
+// Replaces the named graph of a file with triples read from it, unless it already has them
+//
+// `dbManager`: The DBManager connecting to the database
+//
+// `file`: The description
+//
+// `triples`: The triples of the description, before any configuration substitutes them
+//
+// `loaded`: The graphs already in the database, updated with this one
+//
+// returns: an error if updating the database fails
+func replaceFileGraph(dbManager *database.DBManager, file string, triples []database.RDFTriple, loaded graphCache) error {
+	graph := database.GraphIRI(file)
+	triples = database.SortTriples(triples)
+	var nTriples strings.Builder
+	if err := database.WriteNTriples(&nTriples, triples); err != nil {
+		return err
+	}
+	hash := contentHash([]byte(nTriples.String()))
+	if loaded[graph] == hash {
+		slog.Debug("Keeping unchanged file", "file", file)
+		return nil
+	}
+	delete(loaded, graph)
+	slog.Debug("Loading description", "file", file, "triples", len(triples))
+	if err := dbManager.ReplaceGraph(graph, triples); err != nil {
+		return err
+	}
+	loaded[graph] = hash
+	return nil
+}
+
+// The content hash of what is loaded from a file
+//
+// `data`: What is loaded
+//
+// returns: the hash as `sha256-<hex>`
+func contentHash(data []byte) string {
+	hash := sha256.Sum256(data)
+	return "sha256-" + hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	attacktree "github.com/Joao-Felisberto/devprivops/attack_tree"
+	"github.com/Joao-Felisberto/devprivops/util"
+)
+
+// The file the configuration matrix is written to
+const MATRIX_FILE = "config_matrix.json"
+
+// The outcomes of an item in a configuration
+const (
+	PASS = "pass" // The item had no findings
+	WARN = "warn" // The item had findings below the failing severity
+	FAIL = "fail" // The item had findings that fail the analysis
+)
+
+// The outcomes of a policy, requirement or attack/harm tree across configurations
+type matrixRow struct {
+	Item     string            `json:"item"`     // The kind and name of the item, e.g. `policy gdpr/DPIA`
+	Outcomes map[string]string `json:"outcomes"` // The outcome in each configuration the item was checked in
+}
+
+// Which configurations pass or fail which policies, requirements and attack/harm trees
+type configMatrix struct {
+	Configs []string              `json:"configs"` // The configurations, in the order they were analysed
+	Passed  map[string]bool       `json:"passed"`  // Whether each configuration passed the analysis
+	Rows    []*matrixRow          `json:"rows"`    // The items, in the order they were first checked
+	rows    map[string]*matrixRow // The rows by item
+}
+
+// Creates an empty matrix
+//
+// returns: the matrix
+func newConfigMatrix() *configMatrix {
+	return &configMatrix{
+		Configs: []string{},
+		Passed:  map[string]bool{},
+		Rows:    []*matrixRow{},
+		rows:    map[string]*matrixRow{},
+	}
+}
+
+// Records the outcome of an item in a configuration
+//
+// `config`: The configuration name
+//
+// `item`: The kind and name of the item
+//
+// `outcome`: The outcome
+func (m *configMatrix) set(config string, item string, outcome string) {
+	row, ok := m.rows[item]
+	if !ok {
+		row = &matrixRow{Item: item, Outcomes: map[string]string{}}
+		m.rows[item] = row
+		m.Rows = append(m.Rows, row)
+	}
+	row.Outcomes[config] = outcome
+}
+
+// Records the outcomes of every item of a configuration's report
+//
+// `config`: The configuration name
+//
+// `report`: The configuration's report
+//
+// `failOn`: The lowest severity of a finding that fails the analysis
+//
+// `passed`: Whether the configuration passed the analysis
+func (m *configMatrix) add(config string, report *map[string]interface{}, failOn util.Severity, passed bool) {
+	m.Configs = append(m.Configs, config)
+	m.Passed[config] = passed
+
+	violatedPolicies, violatedRequirements, possibleAttacks := validateReport(report)
+	outcome := func(name string, findings []finding) string {
+		for _, f := range findings {
+			if f.Name == name {
+				if f.Severity >= failOn {
+					return FAIL
+				}
+				return WARN
+			}
+		}
+		return PASS
+	}
+
+	for _, regulation := range (*report)["policies"].([]interface{}) {
+		r := regulation.(map[string]interface{})
+		for _, policy := range r["results"].([]map[string]interface{}) {
+			name := fmt.Sprintf("%s/%s", r["name"].(string), policy["name"].(string))
+			m.set(config, fmt.Sprintf("policy %s", name), outcome(name, violatedPolicies))
+		}
+	}
+	if userStories := (*report)["user stories"].(*[]map[string]interface{}); userStories != nil {
+		for _, us := range *userStories {
+			for _, req := range us["requirements"].([]map[string]interface{}) {
+				name := req["title"].(string)
+				m.set(config, fmt.Sprintf("requirement %s", name), outcome(name, violatedRequirements))
+			}
+		}
+	}
+	for _, tree := range (*report)["attack trees"].([]*attacktree.AttackTree) {
+		name := tree.Root.Description
+		m.set(config, fmt.Sprintf("attack %s", name), outcome(name, possibleAttacks))
+	}
+}
+
+// Writes the matrix as a table, with a column per configuration and a final row with the overall result
+//
+// `w`: Where to write the table
+//
+// returns: an error if the table could not be written
+func (m *configMatrix) print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "ITEM\t%s\n", strings.Join(m.Configs, "\t"))
+	for _, row := range m.Rows {
+		cells := util.Map(m.Configs, func(config string) string {
+			if outcome, ok := row.Outcomes[config]; ok {
+				return outcome
+			}
+			return "-"
+		})
+		fmt.Fprintf(tw, "%s\t%s\n", row.Item, strings.Join(cells, "\t"))
+	}
+	overall := util.Map(m.Configs, func(config string) string {
+		if m.Passed[config] {
+			return "PASSED"
+		}
+		return "FAILED"
+	})
+	fmt.Fprintf(tw, "RESULT\t%s\n", strings.Join(overall, "\t"))
+	return tw.Flush()
+}
+
+// Writes the matrix as JSON
+//
+// `file`: The file to write to
+//
+// returns: an error if the file could not be written
+func (m *configMatrix) write(file string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error serializing the configuration matrix: %s", err)
+	}
+	return os.WriteFile(file, data, 0666)
+}
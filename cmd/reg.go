@@ -0,0 +1,365 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Joao-Felisberto/devprivops/database"
+	"github.com/Joao-Felisberto/devprivops/fs"
+	"github.com/Joao-Felisberto/devprivops/regulation"
+	"github.com/Joao-Felisberto/devprivops/util"
+	"github.com/spf13/cobra"
+)
+
+// The path to the project lockfile
+func lockFile() string {
+	return filepath.Join(fs.LocalDir, regulation.LOCK_FILE)
+}
+
+// The `regulations` directory packages are installed to
+//
+// `global`: Whether to install to the global directory rather than the local one
+func regulationsDir(global bool) string {
+	if global {
+		return filepath.Join(fs.GlobalDir, "regulations")
+	}
+	return filepath.Join(fs.LocalDir, "regulations")
+}
+
+// Finds the manifests of the installed packages, local ones hiding global ones with the same name
+//
+// returns: the manifests by package name, without the regulations that are not packages
+func installedPackages() (map[string]*regulation.Manifest, error) {
+	regulations, err := fs.GetRegulations()
+	if err != nil {
+		return nil, err
+	}
+	installed := map[string]*regulation.Manifest{}
+	for _, r := range regulations {
+		dir, err := fs.GetFile(fmt.Sprintf("regulations/%s", r))
+		if err != nil {
+			continue
+		}
+		if manifest, err := regulation.ReadManifest(dir); err == nil {
+			installed[r] = manifest
+		}
+	}
+	return installed, nil
+}
+
+// Checks that a package can be installed in the project: its dependencies, schemas and `uris.yml` entries
+//
+// `manifest`: The manifest of the package
+//
+// returns: an error listing every unmet requirement
+func checkRequirements(manifest *regulation.Manifest) error {
+	installed, err := installedPackages()
+	if err != nil {
+		return err
+	}
+	versions := map[string]string{}
+	for name, m := range installed {
+		versions[name] = m.Version
+	}
+
+	uris := map[string]string{}
+	if uriMetadata, err := getURIMetadata(); err == nil {
+		uris = util.ArrayToMap(*uriMetadata, func(uri database.URIMetadata) (string, string) {
+			return uri.Abreviation, uri.URI
+		})
+	}
+
+	hasSchema := func(name string) bool {
+		_, err := fs.GetFile(fmt.Sprintf("schemas/%s-schema.json", name))
+		return err == nil
+	}
+
+	unmet := regulation.UnmetRequirements(manifest, versions, hasSchema, uris)
+	if len(unmet) != 0 {
+		return fmt.Errorf("cannot install '%s' %s:\n  - %s", manifest.Name, manifest.Version, strings.Join(unmet, "\n  - "))
+	}
+	return nil
+}
+
+// Fetches a package and installs it
+//
+// `source`: Where to fetch the package from
+//
+// `global`: Whether to install to the global directory
+//
+// `force`: Whether to install even if the requirements are not met
+//
+// `expected`: The lockfile entry the package must match, or nil to accept any version
+//
+// returns: the lockfile entry for the installed package or an error if it could not be fetched, checked or installed
+func fetchAndInstall(source string, global bool, force bool, expected *regulation.LockEntry) (*regulation.LockEntry, error) {
+	pkgDir, cleanup, err := regulation.Fetch(source)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	manifest, err := regulation.ReadManifest(pkgDir)
+	if err != nil {
+		return nil, err
+	}
+	integrity, err := regulation.Hash(pkgDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if expected != nil && (manifest.Name != expected.Name || manifest.Version != expected.Version || integrity != expected.Integrity) {
+		return nil, fmt.Errorf(
+			"'%s' no longer matches the lockfile: expected '%s' %s (%s), found '%s' %s (%s)",
+			source, expected.Name, expected.Version, expected.Integrity, manifest.Name, manifest.Version, integrity,
+		)
+	}
+
+	if err := checkRequirements(manifest); err != nil {
+		if !force {
+			return nil, err
+		}
+		slog.Warn("Installing despite unmet requirements", "package", manifest.Name, "error", err)
+	}
+
+	if err := os.MkdirAll(regulationsDir(global), os.ModePerm); err != nil {
+		return nil, err
+	}
+	manifest, integrity, err = regulation.Install(pkgDir, regulationsDir(global))
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("Installed", "package", manifest.Name, "version", manifest.Version, "integrity", integrity)
+	return &regulation.LockEntry{
+		Name:      manifest.Name,
+		Version:   manifest.Version,
+		Source:    source,
+		Integrity: integrity,
+		Global:    global,
+	}, nil
+}
+
+// Main entry point for the `reg install` command.
+// Installs the given packages and pins them in the lockfile or, without arguments, installs exactly what the lockfile pins.
+//
+// `cmd`: The cobra command
+//
+// `args`: The sources of the packages to install
+//
+// `global`: Whether to install to the global directory
+//
+// `force`: Whether to install even if the requirements are not met
+//
+// returns: an error if any package could not be installed
+func RegInstall(cmd *cobra.Command, args []string, global bool, force bool) error {
+	lock, err := regulation.ReadLock(lockFile())
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		if len(lock.Packages) == 0 {
+			slog.Warn("No packages in the lockfile", "lockfile", lockFile())
+		}
+		for _, entry := range lock.Packages {
+			if _, err := fetchAndInstall(entry.Source, global || entry.Global, force, &entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, source := range args {
+		entry, err := fetchAndInstall(source, global, force, nil)
+		if err != nil {
+			return err
+		}
+		lock.Set(*entry)
+	}
+	return lock.Write(lockFile())
+}
+
+// Main entry point for the `reg list` command.
+// Lists the installed regulations, where they are installed and whether they match the lockfile.
+//
+// `cmd`: The cobra command
+//
+// `args`: The args of said command
+//
+// returns: an error if the regulations or the lockfile could not be read
+func RegList(cmd *cobra.Command, args []string) error {
+	lock, err := regulation.ReadLock(lockFile())
+	if err != nil {
+		return err
+	}
+	regulations, err := fs.GetRegulations()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tVERSION\tLAYER\tSTATUS")
+	listed := map[string]bool{}
+	for _, r := range regulations {
+		if listed[r] {
+			continue
+		}
+		listed[r] = true
+
+		dir, err := fs.GetFile(fmt.Sprintf("regulations/%s", r))
+		if err != nil {
+			continue
+		}
+		layer := fs.GetFileLayers(fmt.Sprintf("regulations/%s", r))[0].Name
+		entry := lock.Get(r)
+		if entry != nil {
+			// the lock pins the installed package, not the files of higher layers overriding it
+			dir, layer = entry.Dir(fs.LocalDir, fs.GlobalDir), "local"
+			if entry.Global {
+				layer = "global"
+			}
+		}
+
+		version, status := "-", "not a package"
+		if manifest, err := regulation.ReadManifest(dir); err == nil {
+			version, status = manifest.Version, "not locked"
+			if entry != nil {
+				integrity, err := regulation.Hash(dir)
+				switch {
+				case err != nil:
+					status = fmt.Sprintf("unreadable: %s", err)
+				case integrity != entry.Integrity || version != entry.Version:
+					status = fmt.Sprintf("modified, locked at %s", entry.Version)
+				default:
+					status = "locked"
+				}
+			}
+		} else if entry != nil {
+			status = "locked but not installed"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r, version, layer, status)
+	}
+	for _, entry := range lock.Packages {
+		if !listed[entry.Name] {
+			fmt.Fprintf(w, "%s\t%s\t-\tlocked but not installed\n", entry.Name, entry.Version)
+		}
+	}
+	return w.Flush()
+}
+
+// Main entry point for the `reg update` command.
+// Fetches the locked packages again from their sources and installs them if they changed.
+//
+// `cmd`: The cobra command
+//
+// `args`: The names of the packages to update, all locked packages if empty
+//
+// `global`: Whether to install to the global directory
+//
+// `force`: Whether to install even if the requirements are not met
+//
+// returns: an error if any package is not locked or could not be updated
+func RegUpdate(cmd *cobra.Command, args []string, global bool, force bool) error {
+	lock, err := regulation.ReadLock(lockFile())
+	if err != nil {
+		return err
+	}
+
+	names := args
+	if len(names) == 0 {
+		names = util.Map(lock.Packages, func(entry regulation.LockEntry) string { return entry.Name })
+	}
+
+	for _, name := range names {
+		entry := lock.Get(name)
+		if entry == nil {
+			return fmt.Errorf("'%s' is not in the lockfile, install it with 'devprivops reg install <source>'", name)
+		}
+
+		pkgDir, cleanup, err := regulation.Fetch(entry.Source)
+		if err != nil {
+			return err
+		}
+		integrity, err := regulation.Hash(pkgDir)
+		cleanup()
+		if err != nil {
+			return err
+		}
+		if integrity == entry.Integrity {
+			slog.Info("Up to date", "package", name, "version", entry.Version)
+			continue
+		}
+
+		updated, err := fetchAndInstall(entry.Source, global || entry.Global, force, nil)
+		if err != nil {
+			return err
+		}
+		if updated.Name != name {
+			return fmt.Errorf("the source of '%s' now has package '%s'", name, updated.Name)
+		}
+		slog.Info("Updated", "package", name, "from", entry.Version, "to", updated.Version)
+		lock.Set(*updated)
+	}
+	return lock.Write(lockFile())
+}
+
+// Main entry point for the `reg remove` command.
+// Removes installed packages and their lockfile entries.
+//
+// `cmd`: The cobra command
+//
+// `args`: The names of the packages to remove
+//
+// `global`: Whether to remove from the global directory
+//
+// `force`: Whether to remove packages other installed packages depend on
+//
+// returns: an error if any package could not be removed
+func RegRemove(cmd *cobra.Command, args []string, global bool, force bool) error {
+	lock, err := regulation.ReadLock(lockFile())
+	if err != nil {
+		return err
+	}
+	installed, err := installedPackages()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range args {
+		for dependent, manifest := range installed {
+			if _, ok := manifest.Dependencies.Packages[name]; ok && !force && !util.Any(args, func(a string) bool { return a == dependent }) {
+				return fmt.Errorf("'%s' depends on '%s', remove it first or use '--force'", dependent, name)
+			}
+		}
+
+		dir := filepath.Join(regulationsDir(global), name)
+		if _, err := os.Stat(dir); err != nil {
+			return fmt.Errorf("'%s' is not installed in '%s'", name, regulationsDir(global))
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return fmt.Errorf("could not remove '%s': %s", name, err)
+		}
+		lock.Remove(name)
+		slog.Info("Removed", "package", name)
+	}
+	return lock.Write(lockFile())
+}
+
+// Checks that the installed regulations are exactly the ones pinned in the lockfile, if there is one
+//
+// returns: an error if any locked package is missing or differs from the locked version
+func verifyLock() error {
+	lock, err := regulation.ReadLock(lockFile())
+	if err != nil {
+		return err
+	}
+	if err := lock.Verify(fs.LocalDir, fs.GlobalDir); err != nil {
+		return fmt.Errorf("%s, run 'devprivops reg install' to restore it", err)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Joao-Felisberto/devprivops/database"
+	"github.com/Joao-Felisberto/devprivops/fs"
+	"github.com/Joao-Felisberto/devprivops/util"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+// The name of the file, in the local directory, choosing which regulations and policies apply to the project
+const REGULATIONS_FILE = "regulations.yml"
+
+// Which regulations and policies an analysis runs.
+// Empty inclusion lists select everything, and exclusions always win over inclusions.
+type selection struct {
+	Regulations     []string `yaml:"regulations"`      // The regulations to run, all if empty
+	SkipRegulations []string `yaml:"skip regulations"` // The regulations not to run
+	Policies        []string `yaml:"policies"`         // The titles or tags of the policies to run, all if empty
+	SkipPolicies    []string `yaml:"skip policies"`    // The titles or tags of the policies not to run
+}
+
+// Reads the project's regulation selection
+//
+// `file`: The path to the selection file
+//
+// returns: the selection, empty if the file does not exist, or an error if it cannot be parsed
+func readSelection(file string) (*selection, error) {
+	sel := &selection{}
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return sel, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read '%s': %s", file, err)
+	}
+	if err := yaml.UnmarshalStrict(data, sel); err != nil {
+		return nil, fmt.Errorf("error reading '%s': %s", file, err)
+	}
+	return sel, nil
+}
+
+// Builds the selection for a run from the project's `regulations.yml` and the command line.
+// Regulations or policies given on the command line replace the ones in the file, skipped ones are added to them.
+//
+// `cmd`: The cobra command, with the `regulation`, `skip-regulation`, `policy` and `skip-policy` flags
+//
+// returns: the selection or an error if the file cannot be read or it names regulations that are not installed
+func getSelection(cmd *cobra.Command) (*selection, error) {
+	sel, err := readSelection(filepath.Join(fs.LocalDir, REGULATIONS_FILE))
+	if err != nil {
+		return nil, err
+	}
+
+	flag := func(name string) []string {
+		values, err := cmd.Flags().GetStringSlice(name)
+		if err != nil {
+			return []string{}
+		}
+		return values
+	}
+	if regulations := flag("regulation"); len(regulations) != 0 {
+		sel.Regulations = regulations
+	}
+	if policies := flag("policy"); len(policies) != 0 {
+		sel.Policies = policies
+	}
+	sel.SkipRegulations = append(sel.SkipRegulations, flag("skip-regulation")...)
+	sel.SkipPolicies = append(sel.SkipPolicies, flag("skip-policy")...)
+
+	installed, err := fs.GetRegulations()
+	if err != nil {
+		return nil, err
+	}
+	if unknown := sel.unknownRegulations(installed); len(unknown) != 0 {
+		return nil, fmt.Errorf("unknown regulations %s, the installed ones are: %s", strings.Join(unknown, ", "), strings.Join(installed, ", "))
+	}
+	return sel, nil
+}
+
+// Finds the selected or skipped regulations that are not installed, most likely typos
+//
+// `installed`: The names of the installed regulations
+//
+// returns: the names of the regulations that are not installed
+func (sel *selection) unknownRegulations(installed []string) []string {
+	unknown := []string{}
+	for _, r := range append(append([]string{}, sel.Regulations...), sel.SkipRegulations...) {
+		if !util.Any(installed, func(i string) bool { return i == r }) {
+			unknown = append(unknown, fmt.Sprintf("'%s'", r))
+		}
+	}
+	return unknown
+}
+
+// Whether a regulation is to be run
+//
+// `regulation`: The regulation name
+//
+// returns: whether the regulation is selected and not skipped
+func (sel *selection) includesRegulation(regulation string) bool {
+	is := func(r string) bool { return r == regulation }
+	if util.Any(sel.SkipRegulations, is) {
+		return false
+	}
+	return len(sel.Regulations) == 0 || util.Any(sel.Regulations, is)
+}
+
+// Whether a policy is to be run
+//
+// `policy`: The policy
+//
+// returns: whether the policy's title or any of its tags are selected, and none of them are skipped
+func (sel *selection) includesPolicy(policy database.Query) bool {
+	matches := func(s string) bool {
+		return strings.EqualFold(s, policy.Title) || util.Any(policy.Tags, func(tag string) bool { return strings.EqualFold(s, tag) })
+	}
+	if util.Any(sel.SkipPolicies, matches) {
+		return false
+	}
+	return len(sel.Policies) == 0 || util.Any(sel.Policies, matches)
+}
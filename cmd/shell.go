@@ -0,0 +1,383 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Joao-Felisberto/devprivops/database"
+	"github.com/Joao-Felisberto/devprivops/fs"
+	"github.com/Joao-Felisberto/devprivops/reasoner"
+	"github.com/Joao-Felisberto/devprivops/repl"
+	"github.com/Joao-Felisberto/devprivops/sparql"
+	"github.com/Joao-Felisberto/devprivops/util"
+	"github.com/Joao-Felisberto/devprivops/variant"
+	"github.com/spf13/cobra"
+)
+
+// The SPARQL keywords offered by tab completion
+var sparqlKeywords = []string{
+	"PREFIX", "SELECT", "DISTINCT", "WHERE", "FILTER", "OPTIONAL", "UNION", "MINUS", "BIND", "VALUES", "AS",
+	"NOT", "EXISTS", "GROUP", "ORDER", "BY", "HAVING", "LIMIT", "OFFSET", "ASK", "CONSTRUCT", "DESCRIBE",
+	"INSERT", "DELETE", "DATA", "COUNT", "STR", "REGEX", "CONTAINS", "BOUND", "IRI",
+}
+
+// The commands of the shell
+var shellCommands = []string{":load", ":explain", ":prefixes", ":help", ":quit"}
+
+// The help of the shell
+const SHELL_HELP = `Queries and updates run when a line ends with ';' or an empty line follows them.
+The uris.yml prefixes are declared in every query.
+
+  :load <file.rq>       runs a query file
+  :explain [query]      shows how the triple store runs a query, the last one if none is given
+  :prefixes             lists the declared prefixes
+  :help                 shows this help
+  :quit                 leaves the shell
+`
+
+// An interactive SPARQL session against the loaded project
+type shell struct {
+	dbManager  *database.DBManager // The DBManager connecting to the database
+	namespaces map[string]string   // The namespace of each `uris.yml` prefix
+	prologue   string              // The prefix declarations put before every query, on a single line
+	predicates []string            // The predicates in the graph, prefixed where possible, for tab completion
+	lastQuery  string              // The last query run, for `:explain`
+	out        io.Writer           // Where results go
+}
+
+// Main entry point for the `shell` command.
+// Loads the project's descriptions, configuration and reasoner output into the triple store and reads SPARQL queries until the input ends.
+//
+// `cmd`: The cobra command
+//
+// `args`: The args of said command, the database's username, password, ip, port and dataset
+//
+// returns: an error if the configuration is not known, loading the project fails or the input cannot be read
+func Shell(cmd *cobra.Command, args []string) error {
+	port, err := strconv.Atoi(args[3])
+	if err != nil {
+		return err
+	}
+	entailment, err := reasoner.ParseEntailment(cmd.Flag("entailment").Value.String())
+	if err != nil {
+		return fmt.Errorf("invalid '--entailment': %s", err)
+	}
+	config, err := exportedConfig(cmd.Flag("config").Value.String())
+	if err != nil {
+		return err
+	}
+	var plan *variant.Plan
+	if config != "" {
+		if plan, err = variant.Load(config); err != nil {
+			return err
+		}
+	}
+
+	dbManager := database.NewDBManager(args[0], args[1], args[2], port, args[4])
+	if _, err := dbManager.CleanDB(); err != nil {
+		return err
+	}
+	defer dbManager.CleanDB()
+	if err := loadGraphs(&dbManager, config, plan, graphCache{}); err != nil {
+		return err
+	}
+	if err := runReasoner(&dbManager, entailment); err != nil {
+		return err
+	}
+
+	uriMetadata, err := getURIMetadata()
+	if err != nil {
+		return err
+	}
+	sh := newShell(&dbManager, database.Namespaces(*uriMetadata), os.Stdout)
+	if err := sh.refreshPredicates(); err != nil {
+		return err
+	}
+
+	reader := repl.NewReader(os.Stdin, os.Stdout, sh.candidates)
+	defer reader.Close()
+	fmt.Fprintf(os.Stdout, "Loaded the project into '%s', type :help for help\n", args[4])
+	return sh.loop(reader)
+}
+
+// Creates a shell
+//
+// `dbManager`: The DBManager connecting to the database
+//
+// `namespaces`: The namespace of each prefix to declare
+//
+// `out`: Where results go
+//
+// returns: the shell
+func newShell(dbManager *database.DBManager, namespaces map[string]string, out io.Writer) *shell {
+	prefixes := make([]string, 0, len(namespaces))
+	for prefix := range namespaces {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	prologue := strings.Join(util.Map(prefixes, func(prefix string) string {
+		return fmt.Sprintf("PREFIX %s: <%s>", prefix, namespaces[prefix])
+	}), " ")
+
+	return &shell{
+		dbManager:  dbManager,
+		namespaces: namespaces,
+		prologue:   prologue,
+		predicates: []string{},
+		out:        out,
+	}
+}
+
+// Reads and runs queries and commands until the input ends or `:quit` is given
+//
+// `reader`: Where the input comes from
+//
+// returns: an error if the input cannot be read
+func (sh *shell) loop(reader *repl.Reader) error {
+	pending := []string{}
+	for {
+		prompt := "sparql> "
+		if len(pending) > 0 {
+			prompt = "   ...> "
+		}
+		line, err := reader.ReadLine(prompt)
+		if errors.Is(err, repl.ErrInterrupt) {
+			pending = pending[:0]
+			continue
+		}
+		if err == io.EOF {
+			if len(pending) > 0 {
+				sh.report(sh.run(strings.Join(pending, "\n")))
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case len(pending) == 0 && strings.HasPrefix(trimmed, ":"):
+			quit, err := sh.command(trimmed)
+			sh.report(err)
+			if quit {
+				return nil
+			}
+		case trimmed == "" && len(pending) > 0, strings.HasSuffix(trimmed, ";"):
+			pending = append(pending, strings.TrimSuffix(strings.TrimRight(line, " \t"), ";"))
+			sh.report(sh.run(strings.Join(pending, "\n")))
+			pending = pending[:0]
+		case trimmed != "":
+			pending = append(pending, line)
+		}
+	}
+}
+
+// Shows an error, if there is one, and carries on
+//
+// `err`: The error
+func (sh *shell) report(err error) {
+	if err != nil {
+		fmt.Fprintf(sh.out, "error: %s\n", err)
+	}
+}
+
+// Runs a shell command
+//
+// `line`: The command and its argument
+//
+// returns: whether to leave the shell and an error if the command fails or is not known
+func (sh *shell) command(line string) (bool, error) {
+	name, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+	switch name {
+	case ":quit", ":q", ":exit":
+		return true, nil
+	case ":help":
+		fmt.Fprint(sh.out, SHELL_HELP)
+	case ":prefixes":
+		fmt.Fprintln(sh.out, strings.ReplaceAll(sh.prologue, " PREFIX", "\nPREFIX"))
+	case ":load":
+		if arg == "" {
+			return false, fmt.Errorf("usage: :load <file.rq>")
+		}
+		file := arg
+		if _, err := os.Stat(file); err != nil {
+			if file, err = fs.GetFile(arg); err != nil {
+				return false, fmt.Errorf("could not find '%s'", arg)
+			}
+		}
+		query, err := os.ReadFile(file)
+		if err != nil {
+			return false, fmt.Errorf("could not read '%s': %s", arg, err)
+		}
+		return false, sh.run(string(query))
+	case ":explain":
+		if arg == "" {
+			arg = sh.lastQuery
+		}
+		if arg == "" {
+			return false, fmt.Errorf("no query to explain")
+		}
+		plan, err := sh.dbManager.Explain(sh.withPrefixes(strings.TrimSuffix(arg, ";")))
+		if err != nil {
+			return false, err
+		}
+		fmt.Fprintln(sh.out, plan)
+	default:
+		return false, fmt.Errorf("unknown command '%s', type :help for help", name)
+	}
+	return false, nil
+}
+
+// The query with the `uris.yml` prefixes declared, on its first line so that syntax errors keep their line numbers
+//
+// `query`: The query
+//
+// returns: the query with the prefixes
+func (sh *shell) withPrefixes(query string) string {
+	return sh.prologue + " " + query
+}
+
+// Runs a query or update and shows its result: a table for SELECT and ASK, Turtle for CONSTRUCT and DESCRIBE
+//
+// `query`: The query or update, without the `uris.yml` prefixes
+//
+// returns: an error if the query is not valid or fails
+func (sh *shell) run(query string) error {
+	if strings.TrimSpace(query) == "" {
+		return nil
+	}
+	text := sh.withPrefixes(query)
+	parsed, err := sparql.Parse(text)
+	if err != nil {
+		var syntaxErr *sparql.SyntaxError
+		if errors.As(err, &syntaxErr) && syntaxErr.Line == 1 {
+			syntaxErr.Column -= len(sh.prologue) + 1
+		}
+		return err
+	}
+	sh.lastQuery = query
+
+	switch parsed.Form {
+	case sparql.UPDATE:
+		before, err := sh.dbManager.CountTriples()
+		if err != nil {
+			return err
+		}
+		if err := sh.dbManager.ExecuteUpdate(text, "shell"); err != nil {
+			return err
+		}
+		after, err := sh.dbManager.CountTriples()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(sh.out, "%d triples before, %d after\n", before, after)
+		return sh.refreshPredicates()
+	case sparql.CONSTRUCT, sparql.DESCRIBE:
+		triples, err := sh.dbManager.Construct(text)
+		if err != nil {
+			return err
+		}
+		if err := database.WriteTurtle(sh.out, triples, sh.namespaces); err != nil {
+			return err
+		}
+		fmt.Fprintf(sh.out, "(%d %s)\n", len(triples), plural(len(triples), "triple"))
+		return nil
+	default:
+		vars, rows, err := sh.dbManager.QueryTable(text)
+		if err != nil {
+			return err
+		}
+		return sh.printTable(vars, rows)
+	}
+}
+
+// Writes the rows of a SELECT or ASK query as a table, with IRIs shortened by the `uris.yml` prefixes
+//
+// `vars`: The variables, in the order of the columns
+//
+// `rows`: The rows
+//
+// returns: an error if the table could not be written
+func (sh *shell) printTable(vars []string, rows []map[string]database.Term) error {
+	tw := tabwriter.NewWriter(sh.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(util.Map(vars, func(v string) string { return "?" + v }), "\t"))
+	for _, row := range rows {
+		cells := util.Map(vars, func(v string) string {
+			value, bound := row[v]
+			if !bound {
+				return "-"
+			}
+			return sh.display(value)
+		})
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(sh.out, "(%d %s)\n", len(rows), plural(len(rows), "row"))
+	return nil
+}
+
+// How a value is shown in a table
+//
+// `term`: The value
+//
+// returns: IRIs prefixed if possible, literals by their lexical form and blank nodes by their label
+func (sh *shell) display(term database.Term) string {
+	if term.Kind == database.IRI {
+		if name, ok := database.CompactIRI(term.Value, sh.namespaces); ok {
+			return name
+		}
+		return term.String()
+	}
+	if term.Kind == database.LITERAL {
+		return strings.ReplaceAll(term.Value, "\n", " ")
+	}
+	return term.Value
+}
+
+// Reads the predicates in the graph again, for tab completion
+//
+// returns: an error if the query fails
+func (sh *shell) refreshPredicates() error {
+	_, rows, err := sh.dbManager.QueryTable("SELECT DISTINCT ?p WHERE { ?s ?p ?o }")
+	if err != nil {
+		return err
+	}
+	sh.predicates = util.Map(rows, func(row map[string]database.Term) string { return sh.display(row["p"]) })
+	return nil
+}
+
+// The candidates for tab completion: the predicates in the graph, the prefixes, SPARQL keywords and the shell's commands
+//
+// returns: the candidates
+func (sh *shell) candidates() []string {
+	candidates := append([]string{}, sh.predicates...)
+	for prefix := range sh.namespaces {
+		candidates = append(candidates, prefix+":")
+	}
+	candidates = append(candidates, sparqlKeywords...)
+	return append(candidates, shellCommands...)
+}
+
+// A noun in the plural unless the count is one
+//
+// `count`: The count
+//
+// `noun`: The noun in the singular
+//
+// returns: the noun in the right number
+func plural(count int, noun string) string {
+	if count == 1 {
+		return noun
+	}
+	return noun + "s"
+}
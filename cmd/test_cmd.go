@@ -6,11 +6,16 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/Joao-Felisberto/devprivops/database"
 	"github.com/Joao-Felisberto/devprivops/fs"
-	"github.com/Joao-Felisberto/devprivops/util"
+	"github.com/Joao-Felisberto/devprivops/reasoner"
+	"github.com/Joao-Felisberto/devprivops/schema"
+	"github.com/Joao-Felisberto/devprivops/sparql"
+	"github.com/Joao-Felisberto/devprivops/variant"
 	"github.com/spf13/cobra"
 )
 
@@ -21,73 +26,68 @@ import (
 //
 // `scenario`: The scenario whose tests are to be executed
 //
-// returns: true if no test failed, error if there was any error reading files or validating their schemas, connecting to the database or executing queries
-func runScenario(dbManager *database.DBManager, scenario database.TestScenario) (bool, error) {
+// `entailment`: The built-in entailment rules to materialise before the tests run
+//
+// `policies`: The policies tests can refer to, see `testPolicies`
+//
+// returns: true if any test failed, error if there was any error reading files or validating their schemas, connecting to the database or executing queries
+func runScenario(dbManager *database.DBManager, scenario database.TestScenario, entailment reasoner.Entailment, policies map[string][]database.Query) (bool, error) {
 	dbManager.CleanDB()
 	slog.Info("Loading scenario", "scenario", scenario.StateDir)
 
-	// 1. Load representations
-	err := loadRepresentations(dbManager, scenario.StateDir)
-	if err != nil {
-		return false, err
-	}
-
-	// 2. Load and apply config
+	// 1. Read the config, load representations with the config applied
 	cfgPath := fmt.Sprintf("%s/config.yml", scenario.StateDir)
-	// if _, err := os.Stat(cfgPath); !errors.Is(err, os.ErrNotExist) {
-	// path/to/whatever does not exist
-
-	err = loadRep(dbManager, cfgPath, "")
+	plan, err := variant.Load(cfgPath)
 	configNotFound := errors.Is(err, os.ErrNotExist)
 	if err != nil && !configNotFound {
 		return false, err
 	}
+	if err := loadRepresentations(dbManager, scenario.StateDir, plan); err != nil {
+		return false, err
+	}
+	if err := loadOntologies(dbManager); err != nil {
+		return false, err
+	}
+
+	// 2. Apply the config's substitutions
 	if !configNotFound {
-		_, err = dbManager.ApplyConfig()
-		if err != nil {
+		if err := applyConfig(dbManager, cfgPath, plan); err != nil {
 			return false, err
 		}
 	}
-	// }
 	// 3. Run all the reasoner rules
-	if err = reasoner(dbManager); err != nil {
+	if err = runReasoner(dbManager, entailment); err != nil {
 		return false, err
 	}
 
 	errors := false
 
 	for _, t := range scenario.Tests {
-		slog.Info("Running test", "test", t.Query)
-		file, err := fs.GetFile(t.Query)
-		if err != nil {
-			return false, fmt.Errorf("error reading test file '%s': %s", t.Query, err)
-		}
-		res, err := dbManager.ExecuteQueryFile(file)
+		slog.Info("Running test", "test", t.Name())
+		res, err := runTest(dbManager, t, policies)
 		if err != nil {
-			return false, fmt.Errorf("error running test '%s': %s", file, err)
+			return false, err
 		}
 
-		/*
-			a := util.Map(t.ExpectedResult, func(m map[string]interface{}) ComparableJSON { return m })
-			b := util.Map(res, func(m map[string]interface{}) ComparableJSON { return m })
-			if !util.CompareSets(b, a) {
-		*/
-		if !util.CompareSets(t.ExpectedResult, res) {
+		failures := t.Check(res)
+		if len(failures) == 0 {
+			continue
+		}
+		errors = true
+		if t.ExpectedResult != nil {
 			expected_json, err := json.MarshalIndent(t.ExpectedResult, "", "  ")
 			if err != nil {
 				return false, fmt.Errorf("could not serialize expected as json: %s", err)
 			}
-			actual_json, err := json.MarshalIndent(res, "", "  ")
-			if err != nil {
-				return false, fmt.Errorf("could not serialize actual as json: %s", err)
-			}
-
-			errors = true
 			fmt.Printf("Expected: %s\n", expected_json)
-			fmt.Printf("Actual  : %s\n", actual_json)
-
-			// return fmt.Errorf("result of '%s' does not match expectations: got '%v', expected '%v'", file, res, t.ExpectedResult)
-			slog.Error("Test failed", "file", file, "actual", res, "expected", t.ExpectedResult)
+		}
+		actual_json, err := json.MarshalIndent(res, "", "  ")
+		if err != nil {
+			return false, fmt.Errorf("could not serialize actual as json: %s", err)
+		}
+		fmt.Printf("Actual  : %s\n", actual_json)
+		for _, f := range failures {
+			slog.Error("Test failed", "test", t.Name(), "reason", f)
 		}
 	}
 
@@ -97,6 +97,148 @@ func runScenario(dbManager *database.DBManager, scenario database.TestScenario)
 	return errors, nil
 }
 
+// Runs the query or policy of a test
+//
+// `dbManager`: The DBManager connecting to the database
+//
+// `t`: The test
+//
+// `policies`: The policies tests can refer to, see `testPolicies`
+//
+// returns: the results, the violations in the case of a policy, or an error if the query or policy cannot be found or run
+func runTest(dbManager *database.DBManager, t database.Test, policies map[string][]database.Query) ([]map[string]interface{}, error) {
+	if t.Policy == "" {
+		file, err := fs.GetFile(t.Query)
+		if err != nil {
+			return nil, fmt.Errorf("error reading test file '%s': %s", t.Query, err)
+		}
+		res, err := dbManager.ExecuteQueryFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error running test '%s': %s", file, err)
+		}
+		return res, nil
+	}
+
+	pol, err := testPolicy(t.Policy, policies)
+	if err != nil {
+		return nil, err
+	}
+	res, err := runPolicy(dbManager, *pol)
+	if err != nil {
+		return nil, fmt.Errorf("error running test of policy '%s': %s", t.Policy, err)
+	}
+	return res, nil
+}
+
+// The policies of every regulation, by `regulation/title` and by title, so that tests can refer to them
+//
+// returns: the policies with each key, more than one if several regulations have a policy with that title,
+// or an error if reading any regulation fails
+func testPolicies() (map[string][]database.Query, error) {
+	policies := map[string][]database.Query{}
+	regulations, err := fs.GetRegulations()
+	if err != nil {
+		return nil, err
+	}
+	for _, regulation := range regulations {
+		queries, err := policyQueries(regulation)
+		if err != nil {
+			return nil, err
+		}
+		for _, pol := range queries {
+			policies[fmt.Sprintf("%s/%s", regulation, pol.Title)] = []database.Query{pol}
+			policies[pol.Title] = append(policies[pol.Title], pol)
+		}
+	}
+	return policies, nil
+}
+
+// Finds the policy a test refers to
+//
+// `title`: The title of the policy, as `regulation/title` or just the title
+//
+// `policies`: The policies, see `testPolicies`
+//
+// returns: the policy or an error if there is none with the title, or several and no regulation was given
+func testPolicy(title string, policies map[string][]database.Query) (*database.Query, error) {
+	switch found := policies[title]; len(found) {
+	case 0:
+		return nil, fmt.Errorf("no policy titled '%s'", title)
+	case 1:
+		return &found[0], nil
+	default:
+		return nil, fmt.Errorf("several regulations have a policy titled '%s', write it as '<regulation>/%s'", title, title)
+	}
+}
+
+// The test specification, `tests/spec.yml` or `tests/spec.json`, from the layer with the highest precedence that has either
+//
+// returns: the path to the file or an error if no layer has one
+func testSpecFile() (string, error) {
+	for _, layer := range fs.Layers() {
+		for _, name := range []string{"tests/spec.yml", "tests/spec.json"} {
+			file := filepath.Join(layer.Dir, name)
+			if _, err := os.Stat(file); err == nil {
+				return file, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no 'tests/spec.yml' or 'tests/spec.json' found")
+}
+
+// Checks the syntax of the reasoner rules and test queries before any of them reaches the triple store
+//
+// `tests`: The test scenarios whose queries to check
+//
+// `policies`: The policies tests can refer to, see `testPolicies`
+//
+// returns: an error if any query has problems other than warnings
+func checkQueries(tests []database.TestScenario, policies map[string][]database.Query) error {
+	queries := []string{}
+	if rules, err := listDir("reasoner"); err == nil {
+		queries = append(queries, rules...)
+	}
+	files := []string{}
+	for _, scenario := range tests {
+		for _, t := range scenario.Tests {
+			if t.Policy == "" {
+				queries = append(queries, t.Query)
+			} else if pol, err := testPolicy(t.Policy, policies); err == nil && strings.HasSuffix(pol.File, ".rq") {
+				files = append(files, pol.File)
+			}
+		}
+	}
+	for _, q := range queries {
+		if file, err := fs.GetFile(q); err == nil {
+			files = append(files, file)
+		}
+	}
+
+	errorCount := 0
+	checked := map[string]bool{}
+	for _, file := range files {
+		if checked[file] {
+			continue
+		}
+		checked[file] = true
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("could not read query '%s': %s", file, err)
+		}
+		for _, issue := range sparql.Lint(string(data), nil) {
+			fmt.Println(schema.Problem{File: file, Line: issue.Line, Message: issue.Message, Warning: issue.Warning})
+			if !issue.Warning {
+				errorCount++
+			}
+		}
+	}
+
+	if errorCount != 0 {
+		return fmt.Errorf("%d problems found in the queries", errorCount)
+	}
+	return nil
+}
+
 // Main entry point to the test command.
 // Run all the tests of each scenario.
 //
@@ -114,6 +256,10 @@ func Test(cmd *cobra.Command, args []string) error {
 		return err
 	}
 	dataset := args[4]
+	entailment, err := reasoner.ParseEntailment(cmd.Flag("entailment").Value.String())
+	if err != nil {
+		return fmt.Errorf("invalid '--entailment': %s", err)
+	}
 
 	dbManager := database.NewDBManager(
 		username,
@@ -124,7 +270,7 @@ func Test(cmd *cobra.Command, args []string) error {
 	)
 
 	// 1. Load test metadata
-	testFile, err := fs.GetFile("tests/spec.json")
+	testFile, err := testSpecFile()
 	if err != nil {
 		return err
 	}
@@ -133,11 +279,26 @@ func Test(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	policies, err := testPolicies()
+	if err != nil {
+		return err
+	}
+	for _, scenario := range tests {
+		for _, t := range scenario.Tests {
+			if _, err := testPolicy(t.Policy, policies); t.Policy != "" && err != nil {
+				return fmt.Errorf("scenario '%s': %s", scenario.StateDir, err)
+			}
+		}
+	}
+
+	if err := checkQueries(tests, policies); err != nil {
+		return err
+	}
 
 	// 4. For each scenario, run the tests
 	errors := false
 	for _, t := range tests {
-		test_fails, err := runScenario(&dbManager, t)
+		test_fails, err := runScenario(&dbManager, t, entailment, policies)
 		if err != nil {
 			return fmt.Errorf("test failed for scenario '%s': %s", t.StateDir, err)
 		}
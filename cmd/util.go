@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"log/slog"
+	"os"
 	"regexp"
 	"strings"
 
@@ -10,6 +11,7 @@ import (
 	"github.com/Joao-Felisberto/devprivops/fs"
 	"github.com/Joao-Felisberto/devprivops/schema"
 	"github.com/Joao-Felisberto/devprivops/util"
+	"github.com/Joao-Felisberto/devprivops/variant"
 )
 
 // Validates and loads the representation in the given file into the database
@@ -21,36 +23,96 @@ import (
 //
 // `schemaFile`: file containing the schema
 //
-// returns: error if reading or validating any file or connecting to the database or running a query fails
-func loadRep(dbManager *database.DBManager, repFile string, schemaFile string) error {
-	repName, err := fs.GetFile(repFile)
+// `plan`: The configuration to apply to the representation before loading it, or nil for none
+//
+// returns: error if reading or validating any file, applying the configuration or connecting to the database or running a query fails
+func loadRep(dbManager *database.DBManager, repFile string, schemaFile string, plan *variant.Plan) error {
+	rep, err := readRep(repFile, schemaFile, plan)
 	if err != nil {
 		return err
 	}
+
+	return addRep(dbManager, repFile, rep)
+}
+
+// Reads a representation and applies a configuration to it
+//
+// `repFile`: file containing the representation
+//
+// `schemaFile`: file containing the schema for the representation, or "" for none
+//
+// `plan`: The configuration to apply to the representation, or nil for none
+//
+// returns: the representation or an error if reading or validating the file or applying the configuration fails
+func readRep(repFile string, schemaFile string, plan *variant.Plan) (interface{}, error) {
+	repName, err := fs.GetFile(repFile)
+	if err != nil {
+		return nil, err
+	}
 	repSchemaFname := ""
 	if schemaFile != "" {
 		repSchemaFname, err = fs.GetFile(schemaFile)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 	rep, err := schema.ReadYAML(
 		repName,
 		repSchemaFname,
 	)
+	if err != nil {
+		return nil, err
+	}
+	if plan != nil {
+		if rep, err = plan.Apply(repFile, rep); err != nil {
+			return nil, err
+		}
+	}
+	return rep, nil
+}
+
+// Loads an already read representation into the database, under the base URI of its file
+//
+// `dbManager`: The DBManager connecting to the database
+//
+// `repFile`: file the representation belongs to, which determines its base URI
+//
+// `rep`: The representation
+//
+// returns: error if the file has no base URI or connecting to the database or running a query fails
+func addRep(dbManager *database.DBManager, repFile string, rep interface{}) error {
+	triples, uriMap, err := repTriples(repFile, rep)
 	if err != nil {
 		return err
 	}
+	statusCode, err := dbManager.AddTriples(triples, uriMap)
+	if err != nil {
+		return err
+	}
+	if statusCode < 200 || statusCode >= 300 {
+		return fmt.Errorf("unexpected status code: %d", statusCode)
+	}
 
+	return nil
+}
+
+// Converts an already read representation to triples, under the base URI of its file
+//
+// `repFile`: file the representation belongs to, which determines its base URI
+//
+// `rep`: The representation
+//
+// returns: the triples and the URI of each `uris.yml` prefix, or an error if the file has no base URI or `uris.yml` cannot be read
+func repTriples(repFile string, rep interface{}) ([]schema.Triple, map[string]string, error) {
 	uriMetadata, err := getURIMetadata()
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	uris := util.Filter(*uriMetadata, func(metadata database.URIMetadata) bool {
 		return util.Any(metadata.Files, func(r *regexp.Regexp) bool { return r.MatchString(repFile) })
 	})
 	if len(uris) == 0 {
-		return fmt.Errorf("no base uri for '%s', please add it to 'uris.yml'", repFile)
+		return nil, nil, fmt.Errorf("no base uri for '%s', please add it to 'uris.yml'", repFile)
 	}
 	uri := uris[0]
 	uriMap := util.ArrayToMap(*uriMetadata, func(uri_ database.URIMetadata) (string, string) {
@@ -64,15 +126,25 @@ func loadRep(dbManager *database.DBManager, repFile string, schemaFile string) e
 		uri.URI,
 		&uriMap,
 	)
-	statusCode, err := dbManager.AddTriples(triples, uriMap)
-	if err != nil {
-		return err
-	}
-	if statusCode != 204 {
-		return fmt.Errorf("unexpected status code: %d", statusCode)
-	}
+	return triples, uriMap, nil
+}
 
-	return nil
+// The schema of a description, by the indicator before its extension, e.g. `dfd` in `system.dfd.yml`
+//
+// `file`: The description file
+//
+// returns: the schema file, or "" if the file is a configuration, which has no schema
+func descriptionSchema(file string) string {
+	fPath := strings.Split(file, "/")
+	fname := fPath[len(fPath)-1]
+
+	nameComponents := strings.Split(fname, ".")
+	schemaIndicator := nameComponents[len(nameComponents)-2]
+
+	if schemaIndicator == "config" {
+		return ""
+	}
+	return fmt.Sprintf("schemas/%s-schema.json", schemaIndicator)
 }
 
 // Loads all representations in the representations directories
@@ -81,8 +153,10 @@ func loadRep(dbManager *database.DBManager, repFile string, schemaFile string) e
 //
 // `root`: The directory with all the representations
 //
-// returns: error if reading or validating any file or connecting to the database or running a query fails
-func loadRepresentations(dbManager *database.DBManager, root string) error {
+// `plan`: The configuration to apply to the representations before loading them, or nil for none
+//
+// returns: error if reading or validating any file, applying the configuration or connecting to the database or running a query fails
+func loadRepresentations(dbManager *database.DBManager, root string, plan *variant.Plan) error {
 	slog.Debug("Getting descriptions", "root", root)
 	entries, err := fs.GetDescriptions(root)
 	if err != nil {
@@ -90,18 +164,14 @@ func loadRepresentations(dbManager *database.DBManager, root string) error {
 	}
 
 	for _, e := range entries {
-		fPath := strings.Split(e, "/")
-		fname := fPath[len(fPath)-1]
-
-		nameComponents := strings.Split(fname, ".")
-		schemaIndicator := nameComponents[len(nameComponents)-2]
-
-		schema := ""
-		if schemaIndicator != "config" {
-			schema = fmt.Sprintf("schemas/%s-schema.json", schemaIndicator)
+		if contentType, isRDF := database.RDFContentType(e); isRDF {
+			if err := loadRDF(dbManager, e, contentType); err != nil {
+				return err
+			}
+			continue
 		}
 
-		if err := loadRep(dbManager, e, schema); err != nil {
+		if err := loadRep(dbManager, e, descriptionSchema(e), plan); err != nil {
 			return err
 		}
 	}
@@ -109,6 +179,93 @@ func loadRepresentations(dbManager *database.DBManager, root string) error {
 	return nil
 }
 
+// Loads an RDF description or ontology into the database as it is, with the `uris.yml` prefixes available to it
+//
+// `dbManager`: The DBManager connecting to the database
+//
+// `repFile`: file containing the description or ontology
+//
+// `contentType`: The media type of the file
+//
+// returns: error if reading the file or `uris.yml` fails or the database rejects the file
+func loadRDF(dbManager *database.DBManager, repFile string, contentType string) error {
+	data, err := readRDF(repFile, contentType)
+	if err != nil {
+		return err
+	}
+	slog.Debug("Loading RDF", "file", repFile, "format", contentType)
+	return dbManager.UploadRDF(data, contentType, repFile)
+}
+
+// Reads an RDF description or ontology, with the `uris.yml` prefixes it does not declare added to it
+//
+// `repFile`: file containing the description or ontology
+//
+// `contentType`: The media type of the file
+//
+// returns: the document or an error if reading the file or `uris.yml` fails
+func readRDF(repFile string, contentType string) ([]byte, error) {
+	file, err := fs.GetFile(repFile)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not read '%s': %s", repFile, err)
+	}
+	uriMetadata, err := getURIMetadata()
+	if err != nil {
+		return nil, err
+	}
+	data, err = database.MergePrefixes(data, contentType, database.Namespaces(*uriMetadata))
+	if err != nil {
+		return nil, fmt.Errorf("'%s': %s", repFile, err)
+	}
+	return data, nil
+}
+
+// Loads the ontologies under `ontologies/` into the database
+//
+// `dbManager`: The DBManager connecting to the database
+//
+// returns: error if reading any ontology or uploading it fails
+func loadOntologies(dbManager *database.DBManager) error {
+	ontologies, err := fs.GetOntologies()
+	if err != nil {
+		return fmt.Errorf("error fetching ontologies: %s", err)
+	}
+	for _, o := range ontologies {
+		if err := loadRDF(dbManager, o, "text/turtle"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Loads the `cfg:value` substitutions of a configuration into the database and applies them,
+// warning about the operations of the configuration that changed no description
+//
+// `dbManager`: The DBManager connecting to the database
+//
+// `config`: The configuration file, which determines the base URI of the substitutions
+//
+// `plan`: The configuration, already applied to the descriptions
+//
+// returns: error if the configuration has no base URI or connecting to the database or running a query fails
+func applyConfig(dbManager *database.DBManager, config string, plan *variant.Plan) error {
+	for _, op := range plan.Unmatched() {
+		slog.Warn("Configuration operation changed no description", "config", config, "operation", op)
+	}
+	if len(plan.Config) == 0 {
+		return nil
+	}
+	if err := addRep(dbManager, config, map[interface{}]interface{}{"config": plan.Config}); err != nil {
+		return err
+	}
+	_, err := dbManager.ApplyConfig()
+	return err
+}
+
 // Reads all the URI metadata provided in the `uris.yml` file
 //
 // returns the list of metadata about each URI or an error if reading the file or serializing it fails
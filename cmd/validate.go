@@ -0,0 +1,760 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Joao-Felisberto/devprivops/database"
+	"github.com/Joao-Felisberto/devprivops/fs"
+	"github.com/Joao-Felisberto/devprivops/reasoner"
+	"github.com/Joao-Felisberto/devprivops/schema"
+	"github.com/Joao-Felisberto/devprivops/sparql"
+	"github.com/Joao-Felisberto/devprivops/util"
+	"github.com/Joao-Felisberto/devprivops/variant"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+// Regex for identifiers with an abreviated URI, e.g. `dfd:some id`
+var refPrefixRe = regexp.MustCompile(`^([a-zA-Z]+):(.*)`)
+
+// Regex for the line number in YAML parsing errors
+var yamlErrLineRe = regexp.MustCompile(`line (\d+)`)
+
+// Regexes for the prefix declarations, IRIs and prefixed names of a Turtle document
+var (
+	turtlePrefixRe = regexp.MustCompile(`(?i)@?prefix\s+([A-Za-z][\w-]*)?:\s*<([^>]*)>`)
+	turtleIRIRe    = regexp.MustCompile(`<([^<>\s]*)>`)
+	turtleNameRe   = regexp.MustCompile(`(?:^|[\s;,(\[])([A-Za-z][\w-]*)?:([\w-](?:[\w.-]*[\w-])?)`)
+)
+
+// Regex for the subjects and objects of an RDF/XML document
+var rdfXMLIRIRe = regexp.MustCompile(`rdf:(?:about|resource)="([^"]*)"`)
+
+// A reference to an identifier in a description, to be resolved once every description has been read
+type reference struct {
+	file string // The file where the reference is
+	line int    // The line of the reference
+	raw  string // The reference as written
+	uri  string // The full URI it refers to
+}
+
+// Gathers the problems found in the project files
+type validator struct {
+	problems    []schema.Problem       // The problems found so far
+	uriMetadata []database.URIMetadata // The contents of `uris.yml`
+	uriMap      map[string]string      // The abreviation to URI map from `uris.yml`
+	declared    map[string]bool        // The URIs of every declared identifier, the ones RDF descriptions mention included
+	references  []reference            // The references to identifiers
+	terms       map[string]bool        // The IRIs the queries mention, which are vocabulary and need no declaration
+	checked     map[string]bool        // The query files already checked, by resolved path
+	vocabulary  *sparql.Vocabulary     // The predicates the descriptions, their schemas and the reasoner rules can produce
+}
+
+// Records a problem
+//
+// `file`: The file with the problem
+//
+// `line`: The line of the problem, or 0 if unknown
+//
+// `format`: The problem's message format, followed by its arguments
+func (v *validator) report(file string, line int, format string, args ...interface{}) {
+	v.problems = append(v.problems, schema.Problem{File: file, Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+// Reads and parses a YAML file, recording any problem
+//
+// `file`: The file to read
+//
+// returns: the raw file contents and the parsed YAML, or nil if reading or parsing failed
+func (v *validator) readYAML(file string) ([]byte, interface{}) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		v.report(file, 0, "could not read file: %s", err)
+		return nil, nil
+	}
+	var parsed interface{}
+	if err := yaml.Unmarshal(data, &parsed); err != nil {
+		line := 0
+		if m := yamlErrLineRe.FindStringSubmatch(err.Error()); m != nil {
+			line, _ = strconv.Atoi(m[1])
+		}
+		v.report(file, line, "invalid YAML: %s", err)
+		return nil, nil
+	}
+	return data, parsed
+}
+
+// Reads a YAML file and checks it against one of the internal schemas
+//
+// `relativePath`: The file, relative to the local or global directory
+//
+// `schemaString`: The schema
+//
+// returns: the raw file contents, the parsed YAML and the path to the file, or nil if the file could not be read or parsed
+func (v *validator) checkWithInternalSchema(relativePath string, schemaString *string) ([]byte, interface{}, string) {
+	file, err := fs.GetFile(relativePath)
+	if err != nil {
+		v.report(relativePath, 0, "file not found in any configuration directory")
+		return nil, nil, ""
+	}
+	data, parsed := v.checkFileWithInternalSchema(file, schemaString)
+	return data, parsed, file
+}
+
+// Reads a YAML file of a specific layer and checks it against one of the internal schemas
+//
+// `file`: The path to the file
+//
+// `schemaString`: The schema
+//
+// returns: the raw file contents and the parsed YAML, or nil if the file could not be read or parsed
+func (v *validator) checkFileWithInternalSchema(file string, schemaString *string) ([]byte, interface{}) {
+	data, parsed := v.readYAML(file)
+	if data == nil {
+		return nil, nil
+	}
+	res, err := schema.ValidateYAMLAgainstSchemaString(file, schemaString)
+	if err != nil {
+		v.report(file, 0, "%s", err)
+		return data, parsed
+	}
+	v.problems = append(v.problems, schema.ValidationProblems(file, data, res)...)
+	return data, parsed
+}
+
+// Checks that a query file referenced by another file exists and is valid SPARQL
+//
+// `query`: The path of the query, relative to the local or global directory
+//
+// `from`: The file that references the query
+//
+// `line`: The line of the reference
+func (v *validator) checkQueryReference(query string, from string, line int) {
+	file, err := fs.GetFile(query)
+	if err != nil {
+		v.report(from, line, "query file '%s' not found in any configuration directory", query)
+		return
+	}
+	if strings.HasSuffix(query, ".ttl") {
+		// SHACL shapes are only checked by the triple store
+		return
+	}
+	v.checkQueryFile(file)
+}
+
+// Checks that a file has valid SPARQL that passes the static checks, once however many files reference it
+//
+// `file`: The path to the query file
+func (v *validator) checkQueryFile(file string) {
+	if v.checked[file] {
+		return
+	}
+	v.checked[file] = true
+	data, err := os.ReadFile(file)
+	if err != nil {
+		v.report(file, 0, "could not read file: %s", err)
+		return
+	}
+	if query, err := sparql.Parse(string(data)); err == nil {
+		for _, iri := range query.IRIs {
+			v.terms[iri] = true
+		}
+		for _, name := range query.PrefixedNames {
+			if namespace, ok := query.Prefixes[name.Prefix]; ok {
+				v.terms[namespace+name.Local] = true
+			} else if uri, ok := v.uriMap[name.Prefix]; ok {
+				v.terms[fmt.Sprintf("%s/%s", uri, name.Local)] = true
+			}
+		}
+	}
+	for _, issue := range sparql.Lint(string(data), v.vocabulary) {
+		v.problems = append(v.problems, schema.Problem{File: file, Line: issue.Line, Message: issue.Message, Warning: issue.Warning})
+	}
+}
+
+// Turns a description value into the URI it refers to
+//
+// `value`: The value as written, e.g. `:some id` or `dfd:some id`
+//
+// `base`: The base URI of the file the value is in
+//
+// returns: the URI and whether the value is a reference at all
+func (v *validator) referenceURI(value string, base string) (string, bool) {
+	if strings.HasPrefix(value, ":") {
+		return fmt.Sprintf("%s/%s", base, strings.ReplaceAll(value[1:], " ", "_")), true
+	}
+	if m := refPrefixRe.FindStringSubmatch(value); m != nil && !strings.HasPrefix(m[2], "//") {
+		if uri, ok := v.uriMap[m[1]]; ok {
+			return fmt.Sprintf("%s/%s", uri, strings.ReplaceAll(m[2], " ", "_")), true
+		}
+	}
+	return "", false
+}
+
+// Collects the identifiers declared and referenced in a description
+//
+// `file`: The description file
+//
+// `data`: The raw contents of the file
+//
+// `node`: The YAML node to traverse
+//
+// `path`: The path to the node, as in schema validation errors
+//
+// `base`: The base URI of the file
+func (v *validator) collectIdentifiers(file string, data []byte, node interface{}, path string, base string) {
+	join := func(segment string) string {
+		if path == "" {
+			return segment
+		}
+		return path + "." + segment
+	}
+
+	switch n := node.(type) {
+	case map[interface{}]interface{}:
+		for key, value := range n {
+			k := fmt.Sprintf("%v", key)
+			if id, ok := value.(string); ok && k == "id" {
+				uri, isRef := v.referenceURI(id, base)
+				if !isRef {
+					uri = fmt.Sprintf("%s/%s", base, strings.ReplaceAll(id, " ", "_"))
+				}
+				v.declared[uri] = true
+				continue
+			}
+			v.collectIdentifiers(file, data, value, join(k), base)
+		}
+	case []interface{}:
+		for i, value := range n {
+			v.collectIdentifiers(file, data, value, join(strconv.Itoa(i)), base)
+		}
+	case string:
+		if uri, isRef := v.referenceURI(n, base); isRef {
+			v.references = append(v.references, reference{file: file, line: schema.FindLine(data, path), raw: n, uri: uri})
+		}
+	}
+}
+
+// Checks a description or configuration file: YAML syntax, schema, matching `uris.yml` pattern, and collects its identifiers
+//
+// `relativePath`: The file, relative to the local or global directory
+//
+// `schemaFile`: The schema, relative to the local or global directory, or "" for none
+func (v *validator) checkDescription(relativePath string, schemaFile string) {
+	file, err := fs.GetFile(relativePath)
+	if err != nil {
+		v.report(relativePath, 0, "file not found in any configuration directory")
+		return
+	}
+	data, parsed := v.readYAML(file)
+	if data == nil {
+		return
+	}
+
+	if schemaFile != "" {
+		schemaPath, err := fs.GetFile(schemaFile)
+		if err != nil {
+			v.report(file, 0, "schema '%s' not found in any configuration directory", schemaFile)
+		} else if res, err := schema.ValidateYAMLAgainstSchemaFile(file, schemaPath); err != nil {
+			v.report(file, 0, "%s", err)
+		} else {
+			v.problems = append(v.problems, schema.ValidationProblems(file, data, res)...)
+		}
+	}
+
+	uris := util.Filter(v.uriMetadata, func(metadata database.URIMetadata) bool {
+		return util.Any(metadata.Files, func(r *regexp.Regexp) bool { return r.MatchString(relativePath) })
+	})
+	if len(uris) == 0 {
+		v.report(file, 0, "no base uri for '%s', please add it to 'uris.yml'", relativePath)
+		return
+	}
+
+	v.collectIdentifiers(file, data, parsed, "", uris[0].URI)
+	v.addKeyPredicates(parsed, uris[0].URI)
+}
+
+// Checks an RDF description can be read, and is valid JSON if it is JSON-LD, as the rest is only checked by the triple store
+//
+// `relativePath`: The file, relative to the local or global directory
+//
+// `contentType`: The media type of the description
+func (v *validator) checkRDF(relativePath string, contentType string) {
+	file, err := fs.GetFile(relativePath)
+	if err != nil {
+		v.report(relativePath, 0, "file not found in any configuration directory")
+		return
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		v.report(file, 0, "could not read file: %s", err)
+		return
+	}
+	if _, err := database.MergePrefixes(data, contentType, map[string]string{}); err != nil {
+		v.report(file, 0, "%s", err)
+		return
+	}
+	for _, iri := range rdfIRIs(data, contentType, database.Namespaces(v.uriMetadata)) {
+		v.declared[iri] = true
+	}
+}
+
+// Finds the IRIs an RDF document mentions, without fully parsing it
+//
+// `data`: The document
+//
+// `contentType`: The media type of the document
+//
+// `namespaces`: The namespace of each prefix the document does not declare itself
+//
+// returns: the IRIs, with prefixed names and compact IRIs expanded
+func rdfIRIs(data []byte, contentType string, namespaces map[string]string) []string {
+	prefixes := map[string]string{}
+	for prefix, namespace := range namespaces {
+		prefixes[prefix] = namespace
+	}
+	expand := func(prefix string, local string) (string, bool) {
+		namespace, ok := prefixes[prefix]
+		return namespace + local, ok
+	}
+
+	iris := []string{}
+	switch contentType {
+	case "application/n-triples":
+		triples, err := database.ParseNTriples(string(data))
+		if err != nil {
+			return iris
+		}
+		for _, t := range triples {
+			for _, term := range []database.Term{t.Subject, t.Predicate, t.Object} {
+				if term.Kind == database.IRI {
+					iris = append(iris, term.Value)
+				}
+			}
+		}
+	case "text/turtle":
+		text := string(data)
+		for _, m := range turtlePrefixRe.FindAllStringSubmatch(text, -1) {
+			prefixes[m[1]] = m[2]
+		}
+		for _, m := range turtleIRIRe.FindAllStringSubmatch(text, -1) {
+			iris = append(iris, m[1])
+		}
+		for _, m := range turtleNameRe.FindAllStringSubmatch(text, -1) {
+			if iri, ok := expand(m[1], m[2]); ok {
+				iris = append(iris, iri)
+			}
+		}
+	case "application/ld+json":
+		var doc interface{}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return iris
+		}
+		if obj, ok := doc.(map[string]interface{}); ok {
+			if ctx, ok := obj["@context"].(map[string]interface{}); ok {
+				for prefix, namespace := range ctx {
+					if ns, ok := namespace.(string); ok {
+						prefixes[prefix] = ns
+					}
+				}
+			}
+		}
+		var walk func(node interface{})
+		walk = func(node interface{}) {
+			switch n := node.(type) {
+			case map[string]interface{}:
+				for key, value := range n {
+					if id, ok := value.(string); ok && (key == "@id" || key == "@type") {
+						if m := refPrefixRe.FindStringSubmatch(id); m != nil {
+							if iri, ok := expand(m[1], m[2]); ok {
+								id = iri
+							}
+						}
+						iris = append(iris, id)
+					} else if key != "@context" {
+						walk(value)
+					}
+				}
+			case []interface{}:
+				for _, value := range n {
+					walk(value)
+				}
+			}
+		}
+		walk(doc)
+	case "application/rdf+xml":
+		for _, m := range rdfXMLIRIRe.FindAllStringSubmatch(string(data), -1) {
+			iris = append(iris, m[1])
+		}
+	}
+	return iris
+}
+
+// Adds the predicates a description produces to the vocabulary, one per key as in `schema.YAMLtoRDF`
+//
+// `node`: The YAML node to traverse
+//
+// `base`: The base URI of the description
+func (v *validator) addKeyPredicates(node interface{}, base string) {
+	switch n := node.(type) {
+	case map[interface{}]interface{}:
+		for key, value := range n {
+			if key != "id" {
+				v.vocabulary.AddPredicate(fmt.Sprintf("%s/%s", base, strings.ReplaceAll(fmt.Sprintf("%v", key), " ", "_")))
+			}
+			v.addKeyPredicates(value, base)
+		}
+	case []interface{}:
+		for _, value := range n {
+			v.addKeyPredicates(value, base)
+		}
+	}
+}
+
+// Adds the predicates any description following a schema can produce to the vocabulary, one per property in the schema
+//
+// `schemaFile`: The path to the JSON schema
+//
+// `base`: The base URI of the descriptions that follow it
+func (v *validator) addSchemaPredicates(schemaFile string, base string) {
+	data, err := os.ReadFile(schemaFile)
+	if err != nil {
+		return
+	}
+	var jsonSchema interface{}
+	if err := json.Unmarshal(data, &jsonSchema); err != nil {
+		v.report(schemaFile, 0, "invalid JSON: %s", err)
+		return
+	}
+
+	var walk func(node interface{})
+	walk = func(node interface{}) {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			if properties, ok := n["properties"].(map[string]interface{}); ok {
+				for name := range properties {
+					if name != "id" {
+						v.vocabulary.AddPredicate(fmt.Sprintf("%s/%s", base, strings.ReplaceAll(name, " ", "_")))
+					}
+				}
+			}
+			for _, value := range n {
+				walk(value)
+			}
+		case []interface{}:
+			for _, value := range n {
+				walk(value)
+			}
+		}
+	}
+	walk(jsonSchema)
+}
+
+// Checks an attack/harm tree file and the queries of all its nodes
+//
+// `relativePath`: The file, relative to the local or global directory
+func (v *validator) checkAttackTree(relativePath string) {
+	data, parsed, file := v.checkWithInternalSchema(relativePath, &schema.ATK_TREE_SCHEMA)
+	if data == nil {
+		return
+	}
+
+	var checkNode func(node interface{}, path string)
+	checkNode = func(node interface{}, path string) {
+		n, ok := node.(map[interface{}]interface{})
+		if !ok {
+			return
+		}
+		if query, ok := n["query"].(string); ok {
+			v.checkQueryReference(query, file, schema.FindLine(data, strings.TrimPrefix(path+".query", ".")))
+		}
+		children, _ := n["children"].([]interface{})
+		for i, child := range children {
+			checkNode(child, fmt.Sprintf("%s.children.%d", path, i))
+		}
+	}
+	checkNode(parsed, "")
+}
+
+// Checks every entry of a list file whose entries reference a query
+//
+// `relativePath`: The file, relative to the local or global directory
+//
+// `schemaString`: The schema of the file
+//
+// `queryKey`: The key with the query in each entry
+//
+// `nestedKey`: The key with nested entries with their own queries, or "" if there is none
+func (v *validator) checkQueryList(relativePath string, schemaString *string, queryKey string, nestedKey string) {
+	file, err := fs.GetFile(relativePath)
+	if err != nil {
+		v.report(relativePath, 0, "file not found in any configuration directory")
+		return
+	}
+	v.checkQueryListFile(file, schemaString, queryKey, nestedKey)
+}
+
+// Checks a list of queries of a specific layer, such as a layer's `policies.yml`
+//
+// `file`: The path to the file
+//
+// `schemaString`: The schema of the file
+//
+// `queryKey`: The key with the query in each entry
+//
+// `nestedKey`: The key with nested entries with their own queries, or "" if there is none
+func (v *validator) checkQueryListFile(file string, schemaString *string, queryKey string, nestedKey string) {
+	data, parsed := v.checkFileWithInternalSchema(file, schemaString)
+	if data == nil {
+		return
+	}
+	entries, _ := parsed.([]interface{})
+	for i, raw := range entries {
+		entry, ok := raw.(map[interface{}]interface{})
+		if !ok {
+			continue
+		}
+		if query, ok := entry[queryKey].(string); ok {
+			v.checkQueryReference(query, file, schema.FindLine(data, fmt.Sprintf("%d.%s", i, queryKey)))
+		}
+		if nestedKey == "" {
+			continue
+		}
+		nested, _ := entry[nestedKey].([]interface{})
+		for j, rawNested := range nested {
+			n, ok := rawNested.(map[interface{}]interface{})
+			if !ok {
+				continue
+			}
+			if query, ok := n[queryKey].(string); ok {
+				v.checkQueryReference(query, file, schema.FindLine(data, fmt.Sprintf("%d.%s.%d.%s", i, nestedKey, j, queryKey)))
+			}
+		}
+	}
+}
+
+// Lists the files in a directory of the local or global directory
+//
+// `relativePath`: The directory, relative to the local or global directory
+//
+// returns: the paths of the files relative to the local or global directory, or an error if the directory does not exist
+func listDir(relativePath string) ([]string, error) {
+	dir, err := fs.GetFile(relativePath)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for _, e := range entries {
+		if !e.IsDir() {
+			files = append(files, fmt.Sprintf("%s/%s", relativePath, e.Name()))
+		}
+	}
+	return files, nil
+}
+
+// Checks every project file without contacting the triple store
+//
+// returns: every problem found, sorted by file and line
+func validateProject() []schema.Problem {
+	v := &validator{
+		problems:    []schema.Problem{},
+		uriMetadata: []database.URIMetadata{},
+		uriMap:      map[string]string{},
+		declared:    map[string]bool{},
+		references:  []reference{},
+		terms:       map[string]bool{},
+		checked:     map[string]bool{},
+		vocabulary:  sparql.NewVocabulary(),
+	}
+
+	// 1. URIs
+	if uriMetadata, err := getURIMetadata(); err != nil {
+		v.report("uris.yml", 0, "%s", err)
+	} else {
+		v.uriMetadata = *uriMetadata
+		v.uriMap = util.ArrayToMap(v.uriMetadata, func(uri database.URIMetadata) (string, string) {
+			return uri.Abreviation, uri.URI
+		})
+		for _, uri := range v.uriMetadata {
+			v.vocabulary.AddNamespace(uri.URI + "/")
+		}
+	}
+
+	// 2. Description schemas, any of which may be loaded, and descriptions and configurations
+	for _, layer := range fs.Layers() {
+		dir := layer.Dir
+		entries, err := os.ReadDir(fmt.Sprintf("%s/schemas", dir))
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			indicator, isSchema := strings.CutSuffix(e.Name(), "-schema.json")
+			if !isSchema {
+				continue
+			}
+			descFile := fmt.Sprintf("descriptions/description.%s.yml", indicator)
+			uris := util.Filter(v.uriMetadata, func(metadata database.URIMetadata) bool {
+				return util.Any(metadata.Files, func(r *regexp.Regexp) bool { return r.MatchString(descFile) })
+			})
+			if len(uris) != 0 {
+				v.addSchemaPredicates(fmt.Sprintf("%s/schemas/%s", dir, e.Name()), uris[0].URI)
+			}
+		}
+	}
+	descriptions, err := fs.GetDescriptions("descriptions")
+	if err != nil {
+		v.report("descriptions", 0, "%s", err)
+	}
+	for _, d := range descriptions {
+		if contentType, isRDF := database.RDFContentType(d); isRDF {
+			v.checkRDF(d, contentType)
+			continue
+		}
+		nameComponents := strings.Split(d, ".")
+		if len(nameComponents) < 3 {
+			v.report(d, 0, "description file names must be '<name>.<schema>.yml'")
+			continue
+		}
+		v.checkDescription(d, fmt.Sprintf("schemas/%s-schema.json", nameComponents[len(nameComponents)-2]))
+	}
+	configs, err := fs.GetConfigs()
+	if err != nil {
+		v.report("config", 0, "%s", err)
+	}
+	for _, c := range configs {
+		v.checkDescription(c, "")
+		if _, err := variant.Load(c); err != nil {
+			v.report(c, 0, "%s", err)
+		}
+	}
+
+	// 3. Reasoner rules, whose templates add to the vocabulary before any query is checked
+	rules, err := listDir("reasoner")
+	if err != nil {
+		v.report("reasoner", 0, "%s", err)
+	}
+	if _, err := fs.GetFile(reasoner.MANIFEST_FILE); err == nil {
+		problems := len(v.problems)
+		v.checkWithInternalSchema(reasoner.MANIFEST_FILE, &schema.REASONER_SCHEMA)
+		if _, err := reasonerRules(); err != nil && len(v.problems) == problems {
+			v.report(reasoner.MANIFEST_FILE, 0, "%s", err)
+		}
+	}
+	ruleFiles := []string{}
+	for _, r := range rules {
+		if r == reasoner.MANIFEST_FILE {
+			continue
+		}
+		file, err := fs.GetFile(r)
+		if err != nil {
+			continue
+		}
+		ruleFiles = append(ruleFiles, file)
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		if rule, err := sparql.Parse(string(data)); err == nil {
+			for _, predicate := range rule.Produced {
+				v.vocabulary.AddPredicate(predicate)
+			}
+		}
+	}
+	for _, file := range ruleFiles {
+		v.checkQueryFile(file)
+	}
+
+	// 4. Regulations, requirements, report data and attack trees
+	regulations, err := fs.GetRegulations()
+	if err != nil {
+		v.report("regulations", 0, "%s", err)
+	}
+	for _, r := range regulations {
+		relativePath := fmt.Sprintf("regulations/%s/policies.yml", r)
+		layers := fs.GetFileLayers(relativePath)
+		if len(layers) == 0 {
+			v.report(relativePath, 0, "file not found in any configuration directory")
+			continue
+		}
+		for _, layer := range layers {
+			v.checkQueryListFile(fmt.Sprintf("%s/%s", layer.Dir, relativePath), &schema.QUERY_SCHEMA, "file", "")
+		}
+		if policies, err := loadPolicies(r); err == nil {
+			for _, p := range policies {
+				if p.Entry == nil {
+					v.problems = append(v.problems, schema.Problem{
+						File:    relativePath,
+						Message: fmt.Sprintf("the %s layer disables '%s', which no lower layer defines", p.DisabledBy, p.Title),
+						Warning: true,
+					})
+				}
+			}
+		}
+	}
+	if sel, err := readSelection(fmt.Sprintf("%s/%s", fs.LocalDir, REGULATIONS_FILE)); err != nil {
+		v.report(REGULATIONS_FILE, 0, "%s", err)
+	} else {
+		for _, r := range sel.unknownRegulations(regulations) {
+			v.report(REGULATIONS_FILE, 0, "regulation %s is not installed", r)
+		}
+	}
+	v.checkQueryList("requirements/requirements.yml", &schema.REQUIREMENT_SCHEMA, "query", "requirements")
+	v.checkQueryList("report_data/report_data.yml", &schema.REPORT_DATA_SCHEMA, "query", "")
+
+	trees, err := listDir("attack_trees/descriptions")
+	if err != nil {
+		v.report("attack_trees/descriptions", 0, "%s", err)
+	}
+	for _, t := range trees {
+		v.checkAttackTree(t)
+	}
+
+	// 5. References between descriptions, terms the queries use are vocabulary and need no declaration,
+	// and terms an RDF description mentions are declared by it
+	for _, ref := range v.references {
+		if !v.declared[ref.uri] && !v.terms[ref.uri] {
+			v.report(ref.file, ref.line, "'%s' does not resolve to any declared id", ref.raw)
+		}
+	}
+
+	sort.SliceStable(v.problems, func(i, j int) bool {
+		if v.problems[i].File != v.problems[j].File {
+			return v.problems[i].File < v.problems[j].File
+		}
+		return v.problems[i].Line < v.problems[j].Line
+	})
+	return v.problems
+}
+
+// Main entry point for the `validate` command.
+// Checks every project file without contacting the triple store and reports all problems at once.
+//
+// `cmd`: The cobra command
+//
+// `args`: The args of said command
+//
+// returns: an error if any problem other than a warning was found
+func Validate(cmd *cobra.Command, args []string) error {
+	problems := validateProject()
+	errorCount := 0
+	for _, p := range problems {
+		fmt.Println(p)
+		if !p.Warning {
+			errorCount++
+		}
+	}
+	if errorCount != 0 {
+		return fmt.Errorf("%d %s found", errorCount, plural(errorCount, "problem"))
+	}
+	return nil
+}
@@ -0,0 +1,647 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Joao-Felisberto/devprivops/attack_tree"
+	"github.com/Joao-Felisberto/devprivops/database"
+	"github.com/Joao-Felisberto/devprivops/fs"
+	"github.com/Joao-Felisberto/devprivops/reasoner"
+	"github.com/Joao-Felisberto/devprivops/schema"
+	"github.com/Joao-Felisberto/devprivops/util"
+	"github.com/Joao-Felisberto/devprivops/variant"
+	"github.com/Joao-Felisberto/devprivops/watch"
+)
+
+// How often the configuration directories are polled for changes
+const WATCH_INTERVAL = time.Second
+
+// A policy or requirement whose outcome is followed while watching
+type check struct {
+	Kind     string                                            // `policy` or `requirement`
+	Name     string                                            // The regulation and title of the policy, or the title of the requirement
+	File     string                                            // The query or shapes file, as the layers resolve it
+	Severity util.Severity                                     // How serious it is for the check to fail
+	violated func(dbManager *database.DBManager) (bool, error) // Runs the check
+}
+
+// The key of the check's finding
+//
+// returns: the kind and name of the check
+func (ch *check) key() string {
+	return fmt.Sprintf("%s %s", ch.Kind, ch.Name)
+}
+
+// Returned when reading a description again would give its anonymous entities different ids than it gave them before
+var errRenumbered = errors.New("the anonymous ids of the descriptions changed")
+
+// Returned when triples to remove have blank nodes, which cannot be matched in the store
+var errBlankNodes = errors.New("triples with blank nodes cannot be removed")
+
+// The anonymous ids a description got when it was read
+type idRange struct {
+	First int // The value of the id counter before the description was read
+	Count int // How many ids it got
+}
+
+// The state of an analysis that follows the changes to the project's files
+type watcher struct {
+	dbManager     *database.DBManager             // The DBManager connecting to the database
+	config        string                          // The configuration file, or "" for none
+	sel           *selection                      // The regulations and policies to run
+	failOn        util.Severity                   // The lowest severity that fails the analysis
+	entailment    reasoner.Entailment             // The built-in entailment to run after the reasoner rules
+	plan          *variant.Plan                   // The configuration, or nil for none
+	configTriples []database.RDFTriple            // The `cfg:value` triples of the configuration
+	loaded        graphCache                      // The named graphs of the files in the store
+	descriptions  map[string][]database.RDFTriple // The triples in the default graph of each description read in memory, by path
+	ids           map[string]idRange              // The anonymous ids of each description read in memory, by path
+	inferred      []database.RDFTriple            // The triples the reasoner added
+	checks        []check                         // The policies and requirements
+	findings      map[string]finding              // The current findings, by kind and name
+	stale         bool                            // Whether a failed update left the store out of sync with the files
+	out           io.Writer                       // Where the summaries go
+}
+
+// Runs the analysis and then again on every change to the files of the configuration layers,
+// reloading only what the change affects and printing the findings that appeared or disappeared, until interrupted
+//
+// `dbManager`: The DBManager connecting to the database
+//
+// `config`: The configuration file, or "" for none
+//
+// `sel`: The regulations and policies to run
+//
+// `failOn`: The lowest severity that fails the analysis
+//
+// `entailment`: The built-in entailment to run after the reasoner rules
+//
+// returns: an error if the first analysis fails or the directories cannot be read
+func watchAnalysis(dbManager *database.DBManager, config string, sel *selection, failOn util.Severity, entailment reasoner.Entailment) error {
+	w := &watcher{
+		dbManager:  dbManager,
+		config:     config,
+		sel:        sel,
+		failOn:     failOn,
+		entailment: entailment,
+		loaded:     graphCache{},
+		findings:   map[string]finding{},
+		out:        os.Stdout,
+	}
+	defer dbManager.CleanDB()
+
+	roots := util.Map(fs.Layers(), func(l fs.Layer) string { return filepath.Clean(l.Dir) })
+	snapshot, err := watch.Take(roots)
+	if err != nil {
+		return err
+	}
+	if err := w.reload(); err != nil {
+		return err
+	}
+	w.summarize("initial analysis", map[string]finding{})
+
+	stop := make(chan struct{})
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	go func() {
+		<-interrupt
+		close(stop)
+	}()
+
+	slog.Info("Watching for changes, press Ctrl-C to stop", "directories", strings.Join(roots, ", "))
+	for {
+		var changes []watch.Change
+		changes, snapshot, err = watch.Wait(roots, snapshot, WATCH_INTERVAL, stop)
+		if err != nil {
+			return err
+		}
+		if len(changes) == 0 {
+			return nil
+		}
+		before := w.findings
+		what, err := w.handle(changes)
+		if err != nil {
+			w.stale = true
+			fmt.Fprintf(w.out, "%s %s: %s\n", time.Now().Format("15:04:05"), what, err)
+			continue
+		}
+		w.summarize(what, before)
+	}
+}
+
+// Updates the analysis after some files changed
+//
+// `changes`: The changed files
+//
+// returns: what was done, and an error if it failed
+func (w *watcher) handle(changes []watch.Change) (string, error) {
+	files := strings.Join(util.Map(changes, func(c watch.Change) string { return c.Path }), ", ")
+	if w.stale {
+		return fmt.Sprintf("%s changed, reloaded everything", files), w.reload()
+	}
+
+	descriptions := []string{}
+	queries := map[string]bool{}
+	rereason, recheck, trees := false, false, false
+	for _, c := range changes {
+		file := filepath.Clean(c.File())
+		if util.Any(w.checks, func(ch check) bool { return filepath.Clean(ch.File) == file }) {
+			queries[file] = true
+			continue
+		}
+		switch {
+		case strings.HasPrefix(c.Path, "descriptions/"):
+			if !inMemoryFormat(c.Path) {
+				return fmt.Sprintf("%s changed, reloaded everything", files), w.reload()
+			}
+			descriptions = append(descriptions, c.Path)
+		case strings.HasPrefix(c.Path, "reasoner/"):
+			rereason = true
+		case strings.HasPrefix(c.Path, "attack_trees/"):
+			trees = true
+		case strings.HasPrefix(c.Path, "regulations/"), strings.HasPrefix(c.Path, "requirements/"), c.Path == "regulations.yml":
+			recheck = true
+		case strings.HasPrefix(c.Path, "report_data/"):
+			// extra data is only in reports, which watching does not write
+		default:
+			return fmt.Sprintf("%s changed, reloaded everything", files), w.reload()
+		}
+	}
+
+	switch {
+	case len(descriptions) > 0 || rereason:
+		if err := w.retract(); errors.Is(err, errBlankNodes) {
+			return fmt.Sprintf("%s changed, reloaded everything as the reasoner inferred blank nodes", files), w.reload()
+		} else if err != nil {
+			return "removing the inferred triples failed", err
+		}
+		added, removed := 0, 0
+		for _, d := range descriptions {
+			a, r, err := w.replaceDescription(d)
+			if errors.Is(err, errRenumbered) || errors.Is(err, errBlankNodes) {
+				return fmt.Sprintf("%s changed, reloaded everything as %s", files, err), w.reload()
+			}
+			if err != nil {
+				return fmt.Sprintf("reloading %s failed", d), err
+			}
+			added, removed = added+a, removed+r
+		}
+		if err := w.reason(); err != nil {
+			return "running the reasoner failed", err
+		}
+		if err := w.runChecks(w.checks); err != nil {
+			return "running the checks failed", err
+		}
+		if len(descriptions) == 0 {
+			return fmt.Sprintf("%s changed, ran the reasoner again", files), w.runAttackTrees()
+		}
+		return fmt.Sprintf("%s changed, reloaded +%d -%d triples", files, added, removed), w.runAttackTrees()
+	case recheck:
+		if err := w.buildChecks(); err != nil {
+			return "reading the policies and requirements failed", err
+		}
+		if err := w.runChecks(w.checks); err != nil {
+			return "running the checks failed", err
+		}
+		return fmt.Sprintf("%s changed, ran every policy and requirement again", files), nil
+	}
+
+	affected := util.Filter(w.checks, func(ch check) bool { return queries[filepath.Clean(ch.File)] })
+	if err := w.runChecks(affected); err != nil {
+		return "running the checks failed", err
+	}
+	if trees {
+		if err := w.runAttackTrees(); err != nil {
+			return "running the attack trees failed", err
+		}
+	}
+	names := util.Map(affected, func(ch check) string { return ch.Name })
+	if trees {
+		names = append(names, "the attack trees")
+	}
+	return fmt.Sprintf("%s changed, ran %s again", files, strings.Join(names, ", ")), nil
+}
+
+// Whether a description can be read in memory, so that its triples can be replaced when it changes: YAML and N-Triples
+//
+// `file`: The description
+//
+// returns: whether it can be read in memory
+func inMemoryFormat(file string) bool {
+	contentType, isRDF := database.RDFContentType(file)
+	return !isRDF || contentType == "application/n-triples"
+}
+
+// Loads everything into an empty store, runs the reasoner and every check
+//
+// returns: an error if any step fails
+func (w *watcher) reload() error {
+	w.plan, w.configTriples = nil, []database.RDFTriple{}
+	w.findings = map[string]finding{}
+	w.descriptions, w.ids = map[string][]database.RDFTriple{}, map[string]idRange{}
+	if w.config != "" {
+		var err error
+		if w.plan, err = variant.Load(w.config); err != nil {
+			return err
+		}
+	}
+	if err := loadGraphs(w.dbManager, w.config, w.plan, w.loaded); err != nil {
+		return err
+	}
+
+	// the descriptions are read again in the same order, so that their anonymous entities get the ids they got in the store
+	schema.SetIDCounter(0)
+	entries, err := fs.GetDescriptions("descriptions")
+	if err != nil {
+		return fmt.Errorf("error fetching description: %s", err)
+	}
+	read := map[string][]database.RDFTriple{}
+	for _, e := range util.Filter(entries, inMemoryFormat) {
+		first := schema.IDCounter()
+		if read[e], err = w.readDescription(e); err != nil {
+			return err
+		}
+		w.ids[e] = idRange{first, schema.IDCounter() - first}
+	}
+	// like `loadGraphs`, the configuration is read after the descriptions
+	if w.plan != nil && len(w.plan.Config) > 0 {
+		configTriples, _, err := repTriples(w.config, map[interface{}]interface{}{"config": w.plan.Config})
+		if err != nil {
+			return err
+		}
+		w.configTriples = database.TriplesFromSchema(configTriples)
+	}
+	for e, triples := range read {
+		w.descriptions[e] = w.configure(triples)
+	}
+
+	w.inferred = []database.RDFTriple{}
+	if err := w.reason(); err != nil {
+		return err
+	}
+	if err := w.buildChecks(); err != nil {
+		return err
+	}
+	if err := w.runChecks(w.checks); err != nil {
+		return err
+	}
+	if err := w.runAttackTrees(); err != nil {
+		return err
+	}
+	w.stale = false
+	return nil
+}
+
+// Replaces the triples of a description in the store with the ones it has now, as the configuration changes them
+//
+// `file`: The description, relative to the layers
+//
+// returns: how many triples were added and removed, or an error if the description cannot be read or the store updated
+func (w *watcher) replaceDescription(file string) (int, int, error) {
+	old := w.descriptions[file]
+	ids, known := w.ids[file]
+	if !known {
+		ids = idRange{schema.IDCounter(), 0}
+	}
+	counter := schema.IDCounter()
+	schema.SetIDCounter(ids.First)
+	current := []database.RDFTriple{}
+	if _, err := fs.GetFile(file); err == nil {
+		current, err = w.readDescription(file)
+		if err != nil {
+			schema.SetIDCounter(counter)
+			return 0, 0, err
+		}
+	}
+	used := schema.IDCounter() - ids.First
+	schema.SetIDCounter(counter)
+	if used != ids.Count {
+		return 0, 0, errRenumbered
+	}
+	read := current
+	current = w.configure(current)
+
+	// triples other descriptions also have must stay
+	others := map[string]bool{}
+	for f, triples := range w.descriptions {
+		if f != file {
+			for _, t := range triples {
+				others[t.String()] = true
+			}
+		}
+	}
+	removed := util.Filter(database.DiffTriples(old, current), func(t database.RDFTriple) bool { return !others[t.String()] })
+	added := database.DiffTriples(current, old)
+	if hasBlankNodes(removed) {
+		return 0, 0, errBlankNodes
+	}
+	if err := w.dbManager.DeleteTriples(removed); err != nil {
+		return 0, 0, err
+	}
+	if err := w.dbManager.InsertTriples(added); err != nil {
+		return 0, 0, err
+	}
+	// the named graph follows the file, so that the next reload only replaces what changed since
+	if _, err := fs.GetFile(file); err != nil {
+		if err := w.dbManager.DropGraph(database.GraphIRI(file)); err != nil {
+			return 0, 0, err
+		}
+		delete(w.loaded, database.GraphIRI(file))
+	} else if err := replaceFileGraph(w.dbManager, file, read, w.loaded); err != nil {
+		return 0, 0, err
+	}
+
+	if len(current) == 0 {
+		delete(w.descriptions, file)
+		delete(w.ids, file)
+	} else {
+		w.descriptions[file] = current
+		w.ids[file] = ids
+	}
+	return len(added), len(removed), nil
+}
+
+// Reads a YAML or N-Triples description in memory
+//
+// `file`: The description, relative to the layers
+//
+// returns: the triples or an error if the description cannot be read
+func (w *watcher) readDescription(file string) ([]database.RDFTriple, error) {
+	var triples []database.RDFTriple
+	if _, isRDF := database.RDFContentType(file); isRDF {
+		path, err := fs.GetFile(file)
+		if err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read '%s': %s", file, err)
+		}
+		if triples, err = database.ParseNTriples(string(data)); err != nil {
+			return nil, fmt.Errorf("'%s': %s", file, err)
+		}
+	} else {
+		rep, err := readRep(file, descriptionSchema(file), w.plan)
+		if err != nil {
+			return nil, err
+		}
+		yamlTriples, _, err := repTriples(file, rep)
+		if err != nil {
+			return nil, err
+		}
+		triples = database.TriplesFromSchema(yamlTriples)
+	}
+	return triples, nil
+}
+
+// The triples of a description as they are in the store, with the `cfg:value`s of the configuration substituted
+//
+// `triples`: The triples of the description
+//
+// returns: the substituted triples
+func (w *watcher) configure(triples []database.RDFTriple) []database.RDFTriple {
+	if len(w.configTriples) == 0 {
+		return database.SortTriples(triples)
+	}
+	configured := database.ApplyConfigTriples(append(triples, w.configTriples...))
+	// the configuration's own triples are substituted too
+	config := append(database.ApplyConfigTriples(w.configTriples), w.configTriples...)
+	return database.DiffTriples(configured, config)
+}
+
+// Removes what the reasoner added, so that inferences from triples that changed do not remain
+//
+// returns: an error if the store could not be updated
+func (w *watcher) retract() error {
+	if hasBlankNodes(w.inferred) {
+		return errBlankNodes
+	}
+	if err := w.dbManager.DeleteTriples(w.inferred); err != nil {
+		return err
+	}
+	w.inferred = []database.RDFTriple{}
+	return nil
+}
+
+// Whether any triple has a blank node
+//
+// `triples`: The triples
+//
+// returns: whether a subject or object is a blank node
+func hasBlankNodes(triples []database.RDFTriple) bool {
+	return util.Any(triples, func(t database.RDFTriple) bool {
+		return t.Subject.Kind == database.BLANK || t.Object.Kind == database.BLANK
+	})
+}
+
+// Runs the reasoner, keeping the triples it adds
+//
+// returns: an error if the reasoner or reading the graph fails
+func (w *watcher) reason() error {
+	before, err := w.dbManager.Graph()
+	if err != nil {
+		return err
+	}
+	if err := runReasoner(w.dbManager, w.entailment); err != nil {
+		return err
+	}
+	after, err := w.dbManager.Graph()
+	if err != nil {
+		return err
+	}
+	w.inferred = database.DiffTriples(after, before)
+	return nil
+}
+
+// Reads the selected policies and the requirements as checks
+//
+// returns: an error if the policies or requirements cannot be read
+func (w *watcher) buildChecks() error {
+	checks := []check{}
+	regulations, err := fs.GetRegulations()
+	if err != nil {
+		return err
+	}
+	for _, regulation := range regulations {
+		if !w.sel.includesRegulation(regulation) {
+			continue
+		}
+		queries, err := policyQueries(regulation)
+		if err != nil {
+			return err
+		}
+		for _, pol := range queries {
+			if !w.sel.includesPolicy(pol) {
+				continue
+			}
+			pol := pol
+			checks = append(checks, check{
+				Kind:     "policy",
+				Name:     fmt.Sprintf("%s/%s", regulation, pol.Title),
+				File:     pol.File,
+				Severity: pol.Severity,
+				violated: func(dbManager *database.DBManager) (bool, error) {
+					res, err := runPolicy(dbManager, pol)
+					return len(res) > pol.MaxViolations, err
+				},
+			})
+		}
+	}
+
+	if _, err := fs.GetFile("requirements/requirements.yml"); err == nil {
+		userStories, err := readUserStories()
+		if err != nil {
+			return err
+		}
+		for _, us := range userStories {
+			isMisuseCase := us.IsMisuseCase
+			for _, r := range us.Requirements {
+				file, err := fs.GetFile(r.Query)
+				if err != nil {
+					return err
+				}
+				checks = append(checks, check{
+					Kind:     "requirement",
+					Name:     r.Title,
+					File:     file,
+					Severity: r.Severity,
+					violated: func(dbManager *database.DBManager) (bool, error) {
+						res, err := dbManager.ExecuteQueryFile(file)
+						return (len(res) == 0) != isMisuseCase, err
+					},
+				})
+			}
+		}
+	}
+
+	w.checks = checks
+	// the findings of checks that were removed, disabled or deselected go away with them
+	current := map[string]bool{}
+	for _, ch := range checks {
+		current[ch.key()] = true
+	}
+	findings := map[string]finding{}
+	for k, f := range w.findings {
+		if strings.HasPrefix(k, "attack ") || current[k] {
+			findings[k] = f
+		}
+	}
+	w.findings = findings
+	return nil
+}
+
+// Runs checks, updating the findings
+//
+// `checks`: The checks
+//
+// returns: an error if any check fails to run
+func (w *watcher) runChecks(checks []check) error {
+	findings := w.copyFindings()
+	for _, ch := range checks {
+		violated, err := ch.violated(w.dbManager)
+		if err != nil {
+			return err
+		}
+		delete(findings, ch.key())
+		if violated {
+			findings[ch.key()] = finding{ch.Name, ch.Severity}
+		}
+	}
+	w.findings = findings
+	return nil
+}
+
+// Runs the attack trees, updating the findings
+//
+// returns: an error if any tree cannot be read or run
+func (w *watcher) runAttackTrees() error {
+	findings := w.copyFindings()
+	for k := range findings {
+		if strings.HasPrefix(k, "attack ") {
+			delete(findings, k)
+		}
+	}
+	if _, err := fs.GetFile("attack_trees/descriptions/"); err != nil {
+		w.findings = findings
+		return nil
+	}
+	trees, err := attackTrees(w.dbManager)
+	if err != nil {
+		return err
+	}
+	for _, tree := range trees {
+		if tree.Root.ExecutionStatus == attacktree.POSSIBLE {
+			findings["attack "+tree.Root.Description] = finding{tree.Root.Description, tree.Root.Severity}
+		}
+	}
+	w.findings = findings
+	return nil
+}
+
+// A copy of the findings, so that the ones before a change are kept to compare
+//
+// returns: the copy
+func (w *watcher) copyFindings() map[string]finding {
+	findings := map[string]finding{}
+	for k, f := range w.findings {
+		findings[k] = f
+	}
+	return findings
+}
+
+// Prints what changed and the findings that appeared or disappeared since the previous ones
+//
+// `what`: What was done
+//
+// `before`: The previous findings
+func (w *watcher) summarize(what string, before map[string]finding) {
+	fmt.Fprintf(w.out, "%s %s\n", time.Now().Format("15:04:05"), what)
+
+	keys := []string{}
+	for k := range w.findings {
+		keys = append(keys, k)
+	}
+	for k := range before {
+		if _, ok := w.findings[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	changed := false
+	for _, k := range keys {
+		f, now := w.findings[k]
+		_, then := before[k]
+		kind, _, _ := strings.Cut(k, " ")
+		switch {
+		case now && !then:
+			fmt.Fprintf(w.out, "  + [%s] %s %s\n", f.Severity, kind, f.Name)
+			changed = true
+		case then && !now:
+			f = before[k]
+			fmt.Fprintf(w.out, "  - [%s] %s %s\n", f.Severity, kind, f.Name)
+			changed = true
+		}
+	}
+	if !changed {
+		fmt.Fprintln(w.out, "  no findings appeared or disappeared")
+	}
+
+	failing := 0
+	for _, f := range w.findings {
+		if f.Severity >= w.failOn {
+			failing++
+		}
+	}
+	fmt.Fprintf(w.out, "  %d findings, %d failing the analysis\n", len(w.findings), failing)
+}
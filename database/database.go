@@ -10,13 +10,15 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
-	"text/template"
 
 	attacktree "github.com/Joao-Felisberto/devprivops/attack_tree"
 	"github.com/Joao-Felisberto/devprivops/fs"
 	"github.com/Joao-Felisberto/devprivops/schema"
+	"github.com/Joao-Felisberto/devprivops/util"
 )
 
 // todo: sanitization https://stackoverflow.com/a/55726984
@@ -38,8 +40,13 @@ type DBManager struct {
 	ip       string // the triple store's IP
 	port     int    // the triple store's port
 	dataset  string // the dataset to which to connect
+
+	noGraphStore bool // whether the triple store lacks the graph store protocol, so triples are inserted through SPARQL updates
 }
 
+// How many triples each request sends when inserting triples, all of them in one request if 0 or less
+var BatchSize = 10000
+
 // Creates a new DBManager instance from which it is possible to communicate with the trile store
 //
 // `username`: the username
@@ -65,6 +72,7 @@ func NewDBManager(
 		ip,
 		port,
 		dataset,
+		false,
 	}
 }
 
@@ -95,64 +103,229 @@ func (db *DBManager) sendSparqlQuery(query string, method QueryMethod) (*http.Re
 	return client.Do(req)
 }
 
-// Removes all triples from the triple store
+// Posts a request to a service of the dataset
+//
+// `service`: the service and its query string, e.g. `data?default`
+//
+// `contentType`: the media type of the body
+//
+// `accept`: the media type of the expected response, or "" for any
+//
+// `body`: the body
+//
+// returns: the response or the error that occured when sending the request
+func (db *DBManager) post(service string, contentType string, accept string, body []byte) (*http.Response, error) {
+	return db.postTo(fmt.Sprintf("%s/%s", db.dataset, service), contentType, accept, body)
+}
+
+// Posts a request to any path of the triple store's server
+//
+// `path`: the path and its query string, without the leading `/`
+//
+// `contentType`: the media type of the body
+//
+// `accept`: the media type of the expected response, or "" for any
+//
+// `body`: the body
+//
+// returns: the response or the error that occured when sending the request
+func (db *DBManager) postTo(path string, contentType string, accept string, body []byte) (*http.Response, error) {
+	endpoint := fmt.Sprintf("http://%s:%d/%s", db.ip, db.port, path)
+	req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	auth := db.username + ":" + db.password
+	req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(auth)))
+
+	return (&http.Client{}).Do(req)
+}
+
+// Removes all triples from the triple store, from the default graph and every named graph
 //
 // returns: the query response or the error that occured whrn executing the query
 func (db *DBManager) CleanDB() (*http.Response, error) {
-	return db.sendSparqlQuery(`
-		DELETE { 
-			?s ?p ?o 
-		} WHERE { 
-			?s ?p ?o 
-		}
-	`, UPDATE,
-	)
+	return db.sendSparqlQuery(`DROP ALL`, UPDATE)
+}
+
+// The base of the IRIs of the named graphs that hold the triples of each file
+const GRAPH_BASE = "https://devprivops.com/graph/"
+
+// The named graph that holds the `cfg:value` substitutions of the configuration
+const CONFIG_GRAPH = GRAPH_BASE + "config"
+
+// The IRI of the named graph that holds the triples of a file
+//
+// `file`: The file, relative to the configuration directories
+//
+// returns: the IRI
+func GraphIRI(file string) string {
+	segments := strings.Split(file, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return GRAPH_BASE + "file/" + strings.Join(segments, "/")
+}
+
+// Replaces the triples of a named graph
+//
+// `graph`: the IRI of the graph
+//
+// `triples`: the triples it will have
+//
+// returns: an error if the update fails
+func (db *DBManager) ReplaceGraph(graph string, triples []RDFTriple) error {
+	if err := db.DropGraph(graph); err != nil {
+		return err
+	}
+	_, err := db.insert(graph, triples, fmt.Sprintf("triples of <%s>", graph))
+	return err
+}
+
+// Removes a named graph
+//
+// `graph`: the IRI of the graph
+//
+// returns: an error if the update fails
+func (db *DBManager) DropGraph(graph string) error {
+	return db.ExecuteUpdate(fmt.Sprintf("DROP SILENT GRAPH <%s>", graph), graph)
+}
+
+// Replaces the default graph, which queries and reasoner rules run on, with a copy of named graphs.
+// The named graphs are kept, so that what the reasoner and configurations change in the default graph does not reach them.
+//
+// `graphs`: the IRIs of the graphs
+//
+// returns: an error if the update fails
+func (db *DBManager) CopyToDefault(graphs []string) error {
+	updates := []string{"CLEAR DEFAULT"}
+	for _, g := range graphs {
+		updates = append(updates, fmt.Sprintf("ADD SILENT <%s> TO DEFAULT", g))
+	}
+	return db.ExecuteUpdate(strings.Join(updates, " ;\n"), "default graph")
 }
 
 // Adds the list of triples to the triple store
 //
 // `triples`: the triples to add
 //
-// `prefixes`: the map of prefix abreviations to the full prefix URI
+// `prefixes`: the map of prefix abreviations to the full prefix URI, to expand the prefixed names in the triples
 //
-// returns: the status code or an error if the query failed
+// returns: the status code of the last request or an error if any request failed
 func (db *DBManager) AddTriples(triples []schema.Triple, prefixes map[string]string) (int, error) {
-	sparqlTemplate := `
-		{{ range $key, $value := .Prefixes }} PREFIX {{ $key }}: <{{ $value }}>
-		{{ end }}
-
-        INSERT DATA {
-		{{ range .Triples }}{{ .Subject }} {{ .Predicate }} {{ .Object }} .
-		{{ end }}
-        }
-    `
-	var sparqlQuery strings.Builder
-
-	tpl := template.Must(template.New("insert triples").Parse(sparqlTemplate))
-	if err := tpl.Execute(&sparqlQuery, struct {
-		Triples  []schema.Triple
-		Prefixes map[string]string
-	}{
-		triples,
-		prefixes,
-	}); err != nil {
-		return -1, err
-	}
-
-	// fmt.Printf("Sending %s\n", sparqlQuery.String())
-
-	response, err := db.sendSparqlQuery(sparqlQuery.String(), UPDATE)
+	expanded := util.Map(triples, func(t schema.Triple) schema.Triple {
+		return schema.Triple{
+			Subject:   expandPrefixedName(t.Subject, prefixes),
+			Predicate: expandPrefixedName(t.Predicate, prefixes),
+			Object:    expandPrefixedName(fmt.Sprint(t.Object), prefixes),
+		}
+	})
+	return db.insert("", TriplesFromSchema(expanded), "triples")
+}
+
+// Expands a prefixed name into an IRI, as the triples are sent without prefix declarations
+//
+// `term`: the term, as written in SPARQL
+//
+// `prefixes`: the map of prefix abreviations to the full prefix URI
+//
+// returns: the IRI in brackets, or the term as it was if it is not a prefixed name with a known prefix
+func expandPrefixedName(term string, prefixes map[string]string) string {
+	prefix, local, found := strings.Cut(term, ":")
+	namespace, known := prefixes[prefix]
+	if !found || !known || strings.ContainsAny(term, " \"<>") {
+		return term
+	}
+	return fmt.Sprintf("<%s%s>", namespace, local)
+}
+
+// Inserts triples into a graph in batches of `BatchSize`, uploading them as N-Triples through the graph store protocol,
+// or through SPARQL updates if the triple store does not support it.
+// Triples with blank nodes are sent in a single batch, as a blank node in two requests would be two different nodes.
+//
+// `graph`: the IRI of the graph, or "" for the default graph
+//
+// `triples`: the triples
+//
+// `name`: where the triples came from, used in progress and error messages
+//
+// returns: the status code of the last request or an error if any request failed
+func (db *DBManager) insert(graph string, triples []RDFTriple, name string) (int, error) {
+	if len(triples) == 0 {
+		return http.StatusNoContent, nil
+	}
+	size := BatchSize
+	if size <= 0 || util.Any(triples, func(t RDFTriple) bool { return t.Subject.Kind == BLANK || t.Object.Kind == BLANK }) {
+		size = len(triples)
+	}
+
+	statusCode := 0
+	for start := 0; start < len(triples); start += size {
+		batch := triples[start:min(start+size, len(triples))]
+		var err error
+		if statusCode, err = db.insertBatch(graph, batch, name); err != nil {
+			return statusCode, err
+		}
+		slog.Debug("Inserted triples", "from", name, "progress", fmt.Sprintf("%d/%d", start+len(batch), len(triples)))
+	}
+	return statusCode, nil
+}
+
+// Inserts a batch of triples into a graph, through the graph store protocol unless the triple store does not support it
+//
+// `graph`: the IRI of the graph, or "" for the default graph
+//
+// `triples`: the triples
+//
+// `name`: where the triples came from, used in error messages
+//
+// returns: the status code or an error if the request failed or was rejected
+func (db *DBManager) insertBatch(graph string, triples []RDFTriple, name string) (int, error) {
+	block := triplesBlock(triples)
+	if !db.noGraphStore {
+		target := "default"
+		if graph != "" {
+			target = "graph=" + url.QueryEscape(graph)
+		}
+		response, err := db.post(fmt.Sprintf("%s?%s", DATA, target), "application/n-triples", "", []byte(block))
+		if err != nil {
+			return -1, fmt.Errorf("could not insert the %s: %s", name, err)
+		}
+		defer response.Body.Close()
+		resTxt, _ := io.ReadAll(response.Body)
+
+		switch {
+		case response.StatusCode >= 200 && response.StatusCode < 300:
+			return response.StatusCode, nil
+		case response.StatusCode == http.StatusNotFound, response.StatusCode == http.StatusMethodNotAllowed,
+			response.StatusCode == http.StatusUnsupportedMediaType, response.StatusCode == http.StatusNotImplemented:
+			slog.Debug("The triple store does not support the graph store protocol, inserting through SPARQL updates", "status", response.StatusCode)
+			db.noGraphStore = true
+		default:
+			return response.StatusCode, fmt.Errorf("the %s were rejected with status %d: %s", name, response.StatusCode, strings.TrimSpace(string(resTxt)))
+		}
+	}
+
+	update := fmt.Sprintf("INSERT DATA {\n%s}", block)
+	if graph != "" {
+		update = fmt.Sprintf("INSERT DATA { GRAPH <%s> {\n%s} }", graph, block)
+	}
+	response, err := db.sendSparqlQuery(update, UPDATE)
 	if err != nil {
 		return -1, fmt.Errorf("error sending SPARQL query: %s", err)
 	}
 	defer response.Body.Close()
-
 	resTxt, err := io.ReadAll(response.Body)
 	if err != nil {
-		return -1, fmt.Errorf("failed to read result of AddTriples: %s", err)
+		return -1, fmt.Errorf("failed to read result of inserting the %s: %s", name, err)
+	}
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return response.StatusCode, fmt.Errorf("the %s were rejected with status %d: %s", name, response.StatusCode, strings.TrimSpace(string(resTxt)))
 	}
-
-	slog.Debug("AddTriples resopnse", "body", resTxt)
 	return response.StatusCode, nil
 }
 
@@ -167,19 +340,299 @@ func (db *DBManager) ExecuteReasonerRule(file string) error {
 		return fmt.Errorf("could not read rule file '%s': %s", file, err)
 	}
 
-	sparqlQuery := string(sparqlQueryBytes)
+	return db.ExecuteUpdate(string(sparqlQueryBytes), file)
+}
 
+// Executes a SPARQL update
+//
+// `sparqlQuery`: the update to execute
+//
+// `name`: where the update came from, used in error messages
+//
+// returns: an error if the update is rejected or cannot be sent
+func (db *DBManager) ExecuteUpdate(sparqlQuery string, name string) error {
 	slog.Debug("Executing reasoner rule", "rule", sparqlQuery)
 
 	response, err := db.sendSparqlQuery(sparqlQuery, UPDATE)
 	if err != nil {
-		return fmt.Errorf("query from '%s' had db errors: %s", file, err)
+		return fmt.Errorf("query from '%s' had db errors: %s", name, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		resTxt, err := io.ReadAll(response.Body)
+		if err != nil {
+			return fmt.Errorf("rule '%s' was rejected with status %d", name, response.StatusCode)
+		}
+		return fmt.Errorf("rule '%s' was rejected with status %d: %s", name, response.StatusCode, strings.TrimSpace(string(resTxt)))
+	}
+
+	return nil
+}
+
+// Uploads an RDF document into the default graph through the graph store protocol
+//
+// `data`: the document
+//
+// `contentType`: the media type of the document, e.g. `text/turtle`
+//
+// `name`: where the document came from, used in error messages
+//
+// returns: an error if the document is rejected or cannot be sent
+func (db *DBManager) UploadRDF(data []byte, contentType string, name string) error {
+	return db.upload("default", data, contentType, name)
+}
+
+// Replaces a named graph with an RDF document, through the graph store protocol
+//
+// `graph`: the IRI of the graph
+//
+// `data`: the document
+//
+// `contentType`: the media type of the document, e.g. `text/turtle`
+//
+// `name`: where the document came from, used in error messages
+//
+// returns: an error if the document is rejected or cannot be sent
+func (db *DBManager) UploadGraph(graph string, data []byte, contentType string, name string) error {
+	if err := db.DropGraph(graph); err != nil {
+		return err
+	}
+	return db.upload("graph="+url.QueryEscape(graph), data, contentType, name)
+}
+
+// Adds an RDF document to a graph through the graph store protocol
+//
+// `target`: the graph, `default` or `graph=<encoded IRI>`
+//
+// `data`: the document
+//
+// `contentType`: the media type of the document
+//
+// `name`: where the document came from, used in error messages
+//
+// returns: an error if the document is rejected or cannot be sent
+func (db *DBManager) upload(target string, data []byte, contentType string, name string) error {
+	response, err := db.post(fmt.Sprintf("%s?%s", DATA, target), contentType, "", data)
+	if err != nil {
+		return fmt.Errorf("could not upload '%s': %s", name, err)
 	}
 	defer response.Body.Close()
 
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		resTxt, _ := io.ReadAll(response.Body)
+		return fmt.Errorf("'%s' was rejected with status %d: %s", name, response.StatusCode, strings.TrimSpace(string(resTxt)))
+	}
+
 	return nil
 }
 
+// Inserts triples into the default graph
+//
+// `triples`: the triples
+//
+// returns: an error if the update fails
+func (db *DBManager) InsertTriples(triples []RDFTriple) error {
+	_, err := db.insert("", triples, "triples")
+	return err
+}
+
+// Deletes triples from the default graph
+//
+// `triples`: the triples, which cannot have blank nodes as they could not be matched
+//
+// returns: an error if a triple has a blank node or the update fails
+func (db *DBManager) DeleteTriples(triples []RDFTriple) error {
+	if len(triples) == 0 {
+		return nil
+	}
+	for _, t := range triples {
+		if t.Subject.Kind == BLANK || t.Object.Kind == BLANK {
+			return fmt.Errorf("cannot delete the triple with a blank node %s", t)
+		}
+	}
+	return db.ExecuteUpdate(fmt.Sprintf("DELETE DATA {\n%s}", triplesBlock(triples)), "delete triples")
+}
+
+// Writes triples in the syntax of the data blocks of SPARQL updates
+//
+// `triples`: the triples
+//
+// returns: a triple per line
+func triplesBlock(triples []RDFTriple) string {
+	var block strings.Builder
+	for _, t := range triples {
+		block.WriteString(t.String())
+		block.WriteString("\n")
+	}
+	return block.String()
+}
+
+// Reads every triple of the default graph
+//
+// returns: the triples or an error if the query fails or its result cannot be parsed
+func (db *DBManager) Graph() ([]RDFTriple, error) {
+	return db.Construct("CONSTRUCT { ?s ?p ?o } WHERE { ?s ?p ?o }")
+}
+
+// Runs a CONSTRUCT or DESCRIBE query
+//
+// `sparqlQuery`: the query
+//
+// returns: the triples it builds or an error if the query fails or its result cannot be parsed
+func (db *DBManager) Construct(sparqlQuery string) ([]RDFTriple, error) {
+	response, err := db.post(string(QUERY), "application/sparql-query", "application/n-triples", []byte(sparqlQuery))
+	if err != nil {
+		return nil, fmt.Errorf("failed to run the query: %s", err)
+	}
+	defer response.Body.Close()
+
+	resTxt, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the result of the query: %s", err)
+	}
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return nil, fmt.Errorf("the query failed with status %d: %s", response.StatusCode, strings.TrimSpace(string(resTxt)))
+	}
+
+	triples, err := ParseNTriples(string(resTxt))
+	if err != nil {
+		return nil, fmt.Errorf("invalid result: %s", err)
+	}
+	return triples, nil
+}
+
+// Runs a SELECT or ASK query, keeping the order of the variables and the kind of each value
+//
+// `sparqlQuery`: the query
+//
+// returns: the variables in the order the query projects them, the rows, without the unbound variables,
+// or an error if the query fails or its result cannot be read. An ASK query has a single row with the `ask` variable.
+func (db *DBManager) QueryTable(sparqlQuery string) ([]string, []map[string]Term, error) {
+	response, err := db.sendSparqlQuery(sparqlQuery, QUERY)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to run the query: %s", err)
+	}
+	defer response.Body.Close()
+
+	resTxt, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read the result of the query: %s", err)
+	}
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return nil, nil, fmt.Errorf("the query failed with status %d: %s", response.StatusCode, strings.TrimSpace(string(resTxt)))
+	}
+	return parseResultsTable(resTxt)
+}
+
+// Reads the SPARQL 1.1 JSON results of a SELECT or ASK query
+//
+// `data`: The results
+//
+// returns: the variables, the rows or an error if the results are not valid
+func parseResultsTable(data []byte) ([]string, []map[string]Term, error) {
+	var results struct {
+		Head struct {
+			Vars []string `json:"vars"`
+		} `json:"head"`
+		Boolean *bool `json:"boolean"`
+		Results struct {
+			Bindings []map[string]struct {
+				Type     string `json:"type"`
+				Value    string `json:"value"`
+				Datatype string `json:"datatype"`
+				Lang     string `json:"xml:lang"`
+			} `json:"bindings"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, nil, fmt.Errorf("invalid query results: %s", err)
+	}
+
+	if results.Boolean != nil {
+		return []string{"ask"}, []map[string]Term{{"ask": {Kind: LITERAL, Value: fmt.Sprint(*results.Boolean), Datatype: XSD_BOOLEAN}}}, nil
+	}
+
+	rows := []map[string]Term{}
+	for _, binding := range results.Results.Bindings {
+		row := map[string]Term{}
+		for variable, value := range binding {
+			switch value.Type {
+			case "uri":
+				row[variable] = Term{Kind: IRI, Value: value.Value}
+			case "bnode":
+				row[variable] = Term{Kind: BLANK, Value: "_:" + value.Value}
+			default:
+				row[variable] = Term{Kind: LITERAL, Value: value.Value, Datatype: value.Datatype, Lang: value.Lang}
+			}
+		}
+		rows = append(rows, row)
+	}
+	return results.Head.Vars, rows, nil
+}
+
+// Explains how the triple store would run a query, with Fuseki's query validation service
+//
+// `sparqlQuery`: the query
+//
+// returns: the optimised algebra of the query or an error if the triple store cannot explain queries or the query is not valid
+func (db *DBManager) Explain(sparqlQuery string) (string, error) {
+	form := url.Values{"query": {sparqlQuery}, "languageSyntax": {"SPARQL"}, "outputFormat": {"algebra-opt"}}
+	response, err := db.postTo("$/validate/query", "application/x-www-form-urlencoded", "application/json", []byte(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to explain the query: %s", err)
+	}
+	defer response.Body.Close()
+
+	resTxt, err := io.ReadAll(response.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the query plan: %s", err)
+	}
+	if response.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("the triple store does not explain queries")
+	}
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return "", fmt.Errorf("explaining the query failed with status %d: %s", response.StatusCode, strings.TrimSpace(string(resTxt)))
+	}
+
+	var plan map[string]interface{}
+	if err := json.Unmarshal(resTxt, &plan); err != nil {
+		return "", fmt.Errorf("invalid query plan: %s", err)
+	}
+	if errs, ok := plan["errors"].([]interface{}); ok && len(errs) > 0 {
+		messages := util.Map(errs, func(e interface{}) string {
+			if m, ok := e.(map[string]interface{}); ok {
+				return fmt.Sprint(m["message"])
+			}
+			return fmt.Sprint(e)
+		})
+		return "", fmt.Errorf("invalid query: %s", strings.Join(messages, "; "))
+	}
+	algebra, ok := plan["algebra-opt"].(string)
+	if !ok {
+		return "", fmt.Errorf("the triple store did not return a query plan")
+	}
+	return strings.TrimSpace(algebra), nil
+}
+
+// Counts the triples in the database, to detect whether a reasoner rule added any
+//
+// returns: the number of triples or an error if the query fails
+func (db *DBManager) CountTriples() (int, error) {
+	res, err := db.ExecuteQuery("SELECT (COUNT(*) AS ?count) WHERE { ?s ?p ?o }", "triple count")
+	if err != nil {
+		return 0, err
+	}
+	if len(res) != 1 {
+		return 0, fmt.Errorf("unexpected triple count result: %v", res)
+	}
+	count, err := strconv.Atoi(fmt.Sprintf("%v", res[0]["count"]))
+	if err != nil {
+		return 0, fmt.Errorf("unexpected triple count '%v': %s", res[0]["count"], err)
+	}
+	return count, nil
+}
+
 // Executes a single query from a file
 //
 // `file`: the file where the reasoner rule resides
@@ -191,8 +644,32 @@ func (db *DBManager) ExecuteQueryFile(file string) ([]map[string]interface{}, er
 		return nil, fmt.Errorf("could not read file '%s': %s", file, err)
 	}
 
-	sparqlQuery := string(sparqlQueryBytes)
+	return db.ExecuteQuery(string(sparqlQueryBytes), file)
+}
+
+// Executes a single query
+//
+// `sparqlQuery`: the query to execute
+//
+// `file`: where the query came from, used in error messages
+//
+// returns: the execution results or an error if running the query results in an error
+func (db *DBManager) ExecuteQuery(sparqlQuery string, file string) ([]map[string]interface{}, error) {
+	terms, err := db.executeQueryTerms(sparqlQuery, file)
+	if err != nil {
+		return nil, err
+	}
+	return resultValues(terms), nil
+}
 
+// Executes a single query, keeping the kind of term of each value
+//
+// `sparqlQuery`: the query to execute
+//
+// `file`: where the query came from, used in error messages
+//
+// returns: the execution results or an error if running the query results in an error
+func (db *DBManager) executeQueryTerms(sparqlQuery string, file string) ([]map[string]attacktree.ResultTerm, error) {
 	response, err := db.sendSparqlQuery(sparqlQuery, QUERY)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute query '%s': %s", file, err)
@@ -204,34 +681,37 @@ func (db *DBManager) ExecuteQueryFile(file string) ([]map[string]interface{}, er
 		return nil, fmt.Errorf("failed to read result of '%s': %s", file, err)
 	}
 
-	var resJSON map[string]interface{}
+	var resJSON struct {
+		Results *struct {
+			Bindings []map[string]attacktree.ResultTerm `json:"bindings"`
+		} `json:"results"`
+	}
 	if err := json.Unmarshal(resTxt, &resJSON); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal result of '%s', was there an error in the query? %s. Result was %s", file, err, resTxt)
 	}
-
-	results, ok := resJSON["results"].(map[string]interface{})
-	if !ok {
+	if resJSON.Results == nil {
 		return nil, errors.New("results not found in response")
 	}
-
-	bindings, ok := results["bindings"].([]interface{})
-	if !ok {
+	if resJSON.Results.Bindings == nil {
 		return nil, errors.New("bindings not found in response")
 	}
 
-	binds := []map[string]interface{}{}
-	for _, bind := range bindings {
-		bindMap, ok := bind.(map[string]interface{})
-		if !ok {
-			return nil, errors.New("invalid binding format")
-		}
-		for k := range bindMap {
-			bindMap[k] = bindMap[k].(map[string]interface{})["value"]
-		}
-		binds = append(binds, bindMap)
-	}
+	return resJSON.Results.Bindings, nil
+}
 
-	return binds, nil
+// The values of query results, without their kind of term
+//
+// `terms`: The results
+//
+// returns: the value of each variable of each result
+func resultValues(terms []map[string]attacktree.ResultTerm) []map[string]interface{} {
+	return util.Map(terms, func(row map[string]attacktree.ResultTerm) map[string]interface{} {
+		values := map[string]interface{}{}
+		for k, term := range row {
+			values[k] = term.Value
+		}
+		return values
+	})
 }
 
 // Executes the query of an attack/harm tree node if it is reachable.
@@ -259,7 +739,8 @@ func (db *DBManager) executeAttackTreeNode(attackNode *attacktree.AttackNode) ([
 		if err != nil {
 			return nil, attackNode, err
 		}
-		binds, err := db.ExecuteQueryFile(qFile)
+		terms, err := db.executeAttackNodeQuery(attackNode, qFile)
+		binds := resultValues(terms)
 
 		if len(binds) == 0 {
 			slog.Info("NOT POSSIBLE", "node", attackNode.Description)
@@ -268,6 +749,7 @@ func (db *DBManager) executeAttackTreeNode(attackNode *attacktree.AttackNode) ([
 			slog.Info("POSSIBLE", "node", attackNode.Description)
 			attackNode.SetExecutionResults(attacktree.POSSIBLE, &binds)
 		}
+		attackNode.ResultTerms = terms
 
 		return binds, attackNode, err
 	}
@@ -275,6 +757,36 @@ func (db *DBManager) executeAttackTreeNode(attackNode *attacktree.AttackNode) ([
 	return nil, nil, nil
 }
 
+// Executes the query of an attack/harm tree node.
+// When the node declares bindings, the query is restricted to the values its children bound to those variables,
+// so the node is only possible for the same entities its pre-conditions matched.
+//
+// `attackNode`: The node whose query is to be executed
+//
+// `qFile`: The file with the node's query
+//
+// returns: The execution results, with the kind of term of each value, or an error when reading the file, injecting the bindings or executing the query fails.
+func (db *DBManager) executeAttackNodeQuery(attackNode *attacktree.AttackNode, qFile string) ([]map[string]attacktree.ResultTerm, error) {
+	sparqlQueryBytes, err := os.ReadFile(qFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read file '%s': %s", qFile, err)
+	}
+	sparqlQuery := string(sparqlQueryBytes)
+
+	if len(attackNode.Bindings) != 0 && len(attackNode.Children) != 0 {
+		rows := attackNode.ChildBindings()
+		if len(rows) == 0 {
+			slog.Debug("No child bound the node's variables", "node", attackNode.Description, "bindings", attackNode.Bindings)
+			return []map[string]attacktree.ResultTerm{}, nil
+		}
+		if sparqlQuery, err = attacktree.InjectValues(sparqlQuery, attackNode.Bindings, rows); err != nil {
+			return nil, fmt.Errorf("could not inject bindings into '%s': %s", qFile, err)
+		}
+	}
+
+	return db.executeQueryTerms(sparqlQuery, qFile)
+}
+
 // Finds out whether the attack/harm described by the tree is possible in the system.
 //
 // `attackTree`: The tree to be executed
@@ -5,8 +5,14 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 
 	attacktree "github.com/Joao-Felisberto/devprivops/attack_tree"
@@ -66,7 +72,7 @@ func TestCleanDB(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if code != 204 {
+	if code < 200 || code >= 300 {
 		t.Fatalf("Unexpected status code: %d", code)
 	}
 
@@ -159,7 +165,7 @@ func TestAddTriples(t *testing.T) {
 		t.Error(err)
 	}
 
-	if code != 204 {
+	if code < 200 || code >= 300 {
 		t.Errorf("Unexpected status code: %d", code)
 	}
 	db.CleanDB()
@@ -185,6 +191,44 @@ func TestExecuteReasonerRule(t *testing.T) {
 	}
 }
 
+// Test for the UploadRDF and CountTriples functions
+func TestUploadRDF(t *testing.T) {
+	db := database.NewDBManager(USER, PASS, HOST, PORT, DB)
+	db.CleanDB()
+	defer db.CleanDB()
+
+	ontology := `@prefix rdfs: <http://www.w3.org/2000/01/rdf-schema#> .
+@prefix ex: <https://example.com/> .
+
+ex:special rdfs:subClassOf ex:personal .
+ex:health rdfs:subClassOf ex:special .
+`
+	if err := db.UploadRDF([]byte(ontology), "text/turtle", "ontology.ttl"); err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := db.CountTriples()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("The ontology should add 2 triples, got %d", count)
+	}
+
+	graph, err := db.Graph()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "<https://example.com/health> <http://www.w3.org/2000/01/rdf-schema#subClassOf> <https://example.com/special> ."
+	if graph = database.SortTriples(graph); len(graph) != 2 || graph[0].String() != expected {
+		t.Errorf("The graph should be the ontology's triples, got %v", graph)
+	}
+
+	if err := db.UploadRDF([]byte("ex:a ex:b"), "text/turtle", "broken.ttl"); err == nil {
+		t.Errorf("Invalid Turtle should be rejected")
+	}
+}
+
 // Test for the ExecuteQueryFile function
 func TestExecuteQueryFile(t *testing.T) {
 	db := database.NewDBManager(USER, PASS, HOST, PORT, DB)
@@ -402,3 +446,352 @@ children:
 
 	db.CleanDB()
 }
+
+// Test for reading the validation results of a SHACL report in N-Triples
+func TestValidationResults(t *testing.T) {
+	report := `_:report <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://www.w3.org/ns/shacl#ValidationReport> .
+_:report <http://www.w3.org/ns/shacl#conforms> "false"^^<http://www.w3.org/2001/XMLSchema#boolean> .
+_:report <http://www.w3.org/ns/shacl#result> _:r1 .
+_:r1 <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://www.w3.org/ns/shacl#ValidationResult> .
+_:r1 <http://www.w3.org/ns/shacl#focusNode> <https://devprivops.com/dfd/message> .
+_:r1 <http://www.w3.org/ns/shacl#resultPath> <https://devprivops.com/dfd/validity> .
+_:r1 <http://www.w3.org/ns/shacl#resultMessage> "Every data type needs a \"validity\""@en .
+_:r1 <http://www.w3.org/ns/shacl#resultSeverity> <http://www.w3.org/ns/shacl#Violation> .
+_:r1 <http://www.w3.org/ns/shacl#sourceShape> <https://example.com/DataTypeShape> .
+`
+
+	triples, err := database.ParseNTriples(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := database.ExValidationResults(triples)
+	expected := []map[string]interface{}{{
+		"focus node": "https://devprivops.com/dfd/message",
+		"path":       "https://devprivops.com/dfd/validity",
+		"message":    `Every data type needs a "validity"`,
+		"severity":   "Violation",
+		"shape":      "https://example.com/DataTypeShape",
+	}}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("Validation results should be %v, got %v", expected, results)
+	}
+
+	if _, err := database.ParseNTriples(`<https://a> <https://b> "unterminated .`); err == nil {
+		t.Errorf("Invalid N-Triples should not parse")
+	}
+}
+
+// Test for making the `uris.yml` prefixes available to RDF descriptions
+func TestMergePrefixes(t *testing.T) {
+	if contentType, ok := database.RDFContentType("descriptions/inventory.jsonld"); !ok || contentType != "application/ld+json" {
+		t.Errorf("JSON-LD files should be RDF, got %s, %v", contentType, ok)
+	}
+	if _, ok := database.RDFContentType("descriptions/main.dfd.yml"); ok {
+		t.Errorf("YAML files should not be RDF")
+	}
+
+	namespaces := database.Namespaces([]database.URIMetadata{
+		{Abreviation: "dfd", URI: "https://devprivops.com/dfd"},
+		{Abreviation: "ex", URI: "https://example.com/vocab#"},
+	})
+	if namespaces["dfd"] != "https://devprivops.com/dfd/" || namespaces["ex"] != "https://example.com/vocab#" {
+		t.Errorf("Namespaces should end like the URIs descriptions produce, got %v", namespaces)
+	}
+
+	turtle, err := database.MergePrefixes([]byte("dfd:db dfd:location \"Portugal\" .\n"), "text/turtle", namespaces)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "@prefix dfd: <https://devprivops.com/dfd/> .\n@prefix ex: <https://example.com/vocab#> .\ndfd:db dfd:location \"Portugal\" .\n"
+	if string(turtle) != expected {
+		t.Errorf("Turtle should be %q, got %q", expected, turtle)
+	}
+
+	jsonld, err := database.MergePrefixes([]byte(`{"@context": {"dfd": "https://other.com/"}, "@id": "dfd:db"}`), "application/ld+json", namespaces)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(jsonld, &doc); err != nil {
+		t.Fatal(err)
+	}
+	expectedContext := map[string]interface{}{"dfd": "https://other.com/", "ex": "https://example.com/vocab#"}
+	if !reflect.DeepEqual(doc["@context"], expectedContext) {
+		t.Errorf("The document's own terms should take precedence, expected %v, got %v", expectedContext, doc["@context"])
+	}
+
+	if _, err := database.MergePrefixes([]byte(`{"@id": `), "application/ld+json", namespaces); err == nil {
+		t.Errorf("Invalid JSON-LD should be rejected")
+	}
+}
+
+// Test for writing a graph in each export format
+func TestWriteGraph(t *testing.T) {
+	triples, err := database.ParseNTriples(`<https://devprivops.com/dfd/db> <https://devprivops.com/dfd/location> "Portugal" .
+<https://devprivops.com/dfd/db> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <https://devprivops.com/dfd/Store> .
+<https://devprivops.com/dfd/db> <https://devprivops.com/dfd/encrypted> "true"^^<http://www.w3.org/2001/XMLSchema#boolean> .
+<https://devprivops.com/dfd/db> <https://devprivops.com/dfd/location> "Portugal" .
+<https://devprivops.com/dfd/api> <https://devprivops.com/dfd/to> <https://devprivops.com/dfd/db> .
+<https://devprivops.com/dfd/api> <https://devprivops.com/dfd/label> "say \"hi\""@en .
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	namespaces := map[string]string{"dfd": "https://devprivops.com/dfd/"}
+
+	var nt strings.Builder
+	if err := database.WriteNTriples(&nt, triples); err != nil {
+		t.Fatal(err)
+	}
+	reparsed, err := database.ParseNTriples(nt.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reparsed) != 5 || !reflect.DeepEqual(reparsed, database.SortTriples(triples)) {
+		t.Errorf("N-Triples should round trip without duplicates, got %v", reparsed)
+	}
+
+	var ttl strings.Builder
+	if err := database.WriteTurtle(&ttl, triples, namespaces); err != nil {
+		t.Fatal(err)
+	}
+	expected := `@prefix dfd: <https://devprivops.com/dfd/> .
+
+dfd:api dfd:label "say \"hi\""@en ;
+    dfd:to dfd:db .
+
+dfd:db a dfd:Store ;
+    dfd:encrypted true ;
+    dfd:location "Portugal" .
+`
+	if ttl.String() != expected {
+		t.Errorf("Turtle should be\n%s\ngot\n%s", expected, ttl.String())
+	}
+
+	var jsonld strings.Builder
+	if err := database.WriteJSONLD(&jsonld, triples, namespaces); err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(jsonld.String()), &doc); err != nil {
+		t.Fatal(err)
+	}
+	nodes := doc["@graph"].([]interface{})
+	db := nodes[1].(map[string]interface{})
+	if len(nodes) != 2 || db["@id"] != "dfd:db" || !reflect.DeepEqual(db["@type"], []interface{}{"dfd:Store"}) {
+		t.Errorf("JSON-LD should have a typed node per subject, got %v", nodes)
+	}
+	encrypted := []interface{}{map[string]interface{}{"@value": "true", "@type": database.XSD_BOOLEAN}}
+	if !reflect.DeepEqual(db["dfd:encrypted"], encrypted) {
+		t.Errorf("JSON-LD literals should keep their datatype, expected %v, got %v", encrypted, db["dfd:encrypted"])
+	}
+}
+
+// Test for building and configuring a graph in memory from the triples of YAML descriptions
+func TestApplyConfigTriples(t *testing.T) {
+	triples := database.TriplesFromSchema([]schema.Triple{
+		{Subject: "<https://devprivops.com/dfd/api>", Predicate: "<https://devprivops.com/dfd/to>", Object: "<https://devprivops.com/dfd/storage>"},
+		{Subject: "<https://devprivops.com/dfd/api>", Predicate: "<https://devprivops.com/dfd/public>", Object: "true"},
+		{Subject: "<https://devprivops.com/dfd/storage>", Predicate: "<https://devprivops.com/config/value>", Object: "<https://devprivops.com/dfd/db>"},
+	})
+	if triples[1].Object != (database.Term{Kind: database.LITERAL, Value: "true", Datatype: database.XSD_BOOLEAN}) {
+		t.Errorf("Booleans should be typed literals, got %v", triples[1].Object)
+	}
+
+	applied := database.ApplyConfigTriples(triples)
+	expected := "<https://devprivops.com/dfd/api> <https://devprivops.com/dfd/to> <https://devprivops.com/dfd/db> ."
+	if len(applied) != 3 || applied[0].String() != expected {
+		t.Errorf("The configured object should be replaced, expected %s, got %v", expected, applied)
+	}
+
+	inferred := database.DiffTriples(applied, triples)
+	if len(inferred) != 1 || inferred[0].String() != expected {
+		t.Errorf("Only the new triple should differ, got %v", inferred)
+	}
+}
+
+// Test for reading SELECT and ASK results as a table
+func TestParseResultsTable(t *testing.T) {
+	vars, rows, err := database.ExParseResultsTable([]byte(`{
+  "head": {"vars": ["flow", "label", "node"]},
+  "results": {"bindings": [
+    {"flow": {"type": "uri", "value": "https://devprivops.com/dfd/f"}, "label": {"type": "literal", "value": "sync", "xml:lang": "en"}},
+    {"node": {"type": "bnode", "value": "b0"}, "label": {"type": "literal", "value": "2", "datatype": "http://www.w3.org/2001/XMLSchema#integer"}}
+  ]}
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(vars, []string{"flow", "label", "node"}) {
+		t.Errorf("The variables should keep their order, got %v", vars)
+	}
+	expected := []map[string]database.Term{
+		{
+			"flow":  {Kind: database.IRI, Value: "https://devprivops.com/dfd/f"},
+			"label": {Kind: database.LITERAL, Value: "sync", Lang: "en"},
+		},
+		{
+			"node":  {Kind: database.BLANK, Value: "_:b0"},
+			"label": {Kind: database.LITERAL, Value: "2", Datatype: "http://www.w3.org/2001/XMLSchema#integer"},
+		},
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("Rows should be %v, got %v", expected, rows)
+	}
+
+	vars, rows, err = database.ExParseResultsTable([]byte(`{"head": {}, "boolean": false}`))
+	if err != nil || len(vars) != 1 || rows[0]["ask"].Value != "false" {
+		t.Errorf("ASK results should be a single row, got %v %v %v", vars, rows, err)
+	}
+}
+
+// Test for writing the data of insertions and deletions, which cannot delete blank nodes
+func TestUpdateTriples(t *testing.T) {
+	triples := []database.RDFTriple{
+		{
+			Subject:   database.Term{Kind: database.IRI, Value: "https://devprivops.com/dfd/f"},
+			Predicate: database.Term{Kind: database.IRI, Value: "https://devprivops.com/dfd/label"},
+			Object:    database.Term{Kind: database.LITERAL, Value: "a \"sync\"", Lang: "en"},
+		},
+		{
+			Subject:   database.Term{Kind: database.IRI, Value: "https://devprivops.com/dfd/f"},
+			Predicate: database.Term{Kind: database.IRI, Value: "https://devprivops.com/dfd/to"},
+			Object:    database.Term{Kind: database.BLANK, Value: "_:b0"},
+		},
+	}
+	expected := "<https://devprivops.com/dfd/f> <https://devprivops.com/dfd/label> \"a \\\"sync\\\"\"@en .\n" +
+		"<https://devprivops.com/dfd/f> <https://devprivops.com/dfd/to> _:b0 .\n"
+	if block := database.ExTriplesBlock(triples); block != expected {
+		t.Errorf("The block should be %q, got %q", expected, block)
+	}
+
+	dbManager := database.NewDBManager("user", "pass", "127.0.0.1", 1, "tmp")
+	if err := dbManager.DeleteTriples(triples); err == nil || !strings.Contains(err.Error(), "blank node") {
+		t.Errorf("Deleting a blank node should fail before contacting the database, got %v", err)
+	}
+	if err := dbManager.InsertTriples([]database.RDFTriple{}); err != nil {
+		t.Errorf("Inserting no triples should not contact the database, got %v", err)
+	}
+}
+
+// Test for naming the graph of a file
+func TestGraphIRI(t *testing.T) {
+	if iri := database.GraphIRI("descriptions/main.dfd.yml"); iri != "https://devprivops.com/graph/file/descriptions/main.dfd.yml" {
+		t.Errorf("Unexpected graph %s", iri)
+	}
+	if iri := database.GraphIRI("descriptions/my system#1.dfd.yml"); iri != "https://devprivops.com/graph/file/descriptions/my%20system%231.dfd.yml" {
+		t.Errorf("Characters not allowed in IRIs should be escaped, got %s", iri)
+	}
+}
+
+// Test for inserting triples in batches, through SPARQL updates when the triple store lacks the graph store protocol
+func TestAddTriplesInBatches(t *testing.T) {
+	requests := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, r.URL.Path+" "+string(body))
+		if strings.HasSuffix(r.URL.Path, "/data") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+	port, _ := strconv.Atoi(serverURL.Port())
+	db := database.NewDBManager(USER, PASS, serverURL.Hostname(), port, DB)
+
+	defer func(size int) { database.BatchSize = size }(database.BatchSize)
+	database.BatchSize = 2
+	code, err := db.AddTriples([]schema.Triple{
+		{Subject: "ex:1", Predicate: "<https://example.com/p>", Object: "\"ex:not a name\""},
+		{Subject: "ex:2", Predicate: "<https://example.com/p>", Object: "true"},
+		{Subject: "ex:3", Predicate: "<https://example.com/p>", Object: "ex:4"},
+	}, map[string]string{"ex": "https://example.com/"})
+	if err != nil || code != http.StatusNoContent {
+		t.Fatalf("Adding triples should succeed, got %d %v", code, err)
+	}
+
+	expected := []string{
+		"/tmp/data <https://example.com/1> <https://example.com/p> \"ex:not a name\" .\n<https://example.com/2> <https://example.com/p> \"true\"^^<http://www.w3.org/2001/XMLSchema#boolean> .\n",
+		"/tmp/update INSERT DATA {\n<https://example.com/1> <https://example.com/p> \"ex:not a name\" .\n<https://example.com/2> <https://example.com/p> \"true\"^^<http://www.w3.org/2001/XMLSchema#boolean> .\n}",
+		"/tmp/update INSERT DATA {\n<https://example.com/3> <https://example.com/p> <https://example.com/4> .\n}",
+	}
+	if !reflect.DeepEqual(requests, expected) {
+		t.Errorf("Requests should be %q, got %q", expected, requests)
+	}
+}
+
+// Test for reading a YAML test specification and checking results against its assertions
+func TestTestAssertions(t *testing.T) {
+	spec := filepath.Join(t.TempDir(), "spec.yml")
+	if err := os.WriteFile(spec, []byte(`
+- state dir: tests/a
+  tests:
+    - policy: gdpr/User data shall not leave the EU
+      count at least: 1
+      contains:
+        - store: https://devprivops.com/dfd/analytics_db
+      does not contain:
+        - store: https://devprivops.com/dfd/message_db
+      matches:
+        location: ^(US|China)$
+    - query: tests/count.rq
+      count: 2
+      expected result:
+        - n: 2
+      empty: false
+`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	scenarios, err := database.TestsFromFile(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scenarios) != 1 || scenarios[0].StateDir != "tests/a" || len(scenarios[0].Tests) != 2 {
+		t.Fatalf("Unexpected scenarios %v", scenarios)
+	}
+	policy, query := scenarios[0].Tests[0], scenarios[0].Tests[1]
+
+	violations := []map[string]interface{}{
+		{"store": "https://devprivops.com/dfd/analytics_db", "location": "US"},
+		{"store": "https://devprivops.com/dfd/backup_db", "location": "China"},
+	}
+	if failures := policy.Check(violations); len(failures) != 0 {
+		t.Errorf("The policy test should pass, got %v", failures)
+	}
+	violations = append(violations, map[string]interface{}{"store": "https://devprivops.com/dfd/message_db", "location": "Ireland"})
+	expected := []string{
+		"a result has store = 'https://devprivops.com/dfd/message_db'",
+		"'location' of a result is 'Ireland', which does not match '^(US|China)$'",
+	}
+	if failures := policy.Check(violations); !reflect.DeepEqual(failures, expected) {
+		t.Errorf("Failures should be %v, got %v", expected, failures)
+	}
+	expected = []string{
+		"no result has store = 'https://devprivops.com/dfd/analytics_db'",
+		"expected at least 1 results, got 0",
+	}
+	if failures := policy.Check([]map[string]interface{}{}); !reflect.DeepEqual(failures, expected) {
+		t.Errorf("Failures should be %v, got %v", expected, failures)
+	}
+
+	if failures := query.Check([]map[string]interface{}{{"n": "2"}}); !reflect.DeepEqual(failures, []string{"expected 2 results, got 1"}) {
+		t.Errorf("YAML values should be compared as strings, got %v", failures)
+	}
+
+	invalid := map[string]string{
+		"- tests: [{query: q.rq}]":                         "no assertions",
+		"- tests: [{query: q.rq, policy: P, empty: true}]": "exactly one",
+		"- tests: [{query: q.rq, matches: {x: '('}}]":      "regular expression",
+		"- tests: [{query: q.rq, count at leats: 1}]":      "YAML",
+	}
+	for content, message := range invalid {
+		if err := os.WriteFile(spec, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := database.TestsFromFile(spec); err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("'%s' should fail with '%s', got %v", content, message, err)
+		}
+	}
+}
@@ -2,3 +2,12 @@ package database
 
 // Exports the sendSparqlQuery function for testing
 var SendSparqlQuery = (*DBManager).sendSparqlQuery
+
+// Exports the validationResults function for testing
+var ExValidationResults = validationResults
+
+// Exports the parseResultsTable function for testing
+var ExParseResultsTable = parseResultsTable
+
+// Exports the triplesBlock function for testing
+var ExTriplesBlock = triplesBlock
@@ -1,15 +1,19 @@
 package database
 
+import "github.com/Joao-Felisberto/devprivops/util"
+
 // Defines the data a query holds
 type Query struct {
-	File           string   // The file where the query resides
-	Title          string   // The query's title
-	Description    string   // The query's purpose description
-	IsConsistency  bool     // Whether the query concerns the consistency of the descriptions or not
-	MaxViolations  int      // The maximum number of violations allowed
-	MappingMessage string   // The message instructing how to map the results of the query to solutions
-	ClearenceLvl   int      // The minimum hierarchical level required to see this in the visualizer
-	Group          []string // The groups allowed to see this in the visualizer
+	File           string        // The file where the query resides
+	Title          string        // The query's title
+	Description    string        // The query's purpose description
+	IsConsistency  bool          // Whether the query concerns the consistency of the descriptions or not
+	MaxViolations  int           // The maximum number of violations allowed
+	MappingMessage string        // The message instructing how to map the results of the query to solutions
+	ClearenceLvl   int           // The minimum hierarchical level required to see this in the visualizer
+	Group          []string      // The groups allowed to see this in the visualizer
+	Tags           []string      // The tags used to select or skip the query at run time
+	Severity       util.Severity // How serious exceeding the maximum violations is
 }
 
 // Constructs a new query
@@ -19,6 +23,8 @@ type Query struct {
 // `isConsistency`: Whether the query concerns the consistency of the descriptions or not
 // `maxViolations`: The maximum number of violations allowed
 // `mappingMessage`: The message instructing how to map the results of the query to solutions
+// `tags`: The tags used to select or skip the query at run time
+// `severity`: How serious exceeding the maximum violations is
 func NewQuery(
 	file string,
 	title string,
@@ -28,6 +34,8 @@ func NewQuery(
 	mappingMessage string,
 	clearenceLvl int,
 	groups []string,
+	tags []string,
+	severity util.Severity,
 ) Query {
 	return Query{
 		File:           file,
@@ -38,5 +46,7 @@ func NewQuery(
 		MappingMessage: mappingMessage,
 		ClearenceLvl:   clearenceLvl,
 		Group:          groups,
+		Tags:           tags,
+		Severity:       severity,
 	}
 }
@@ -0,0 +1,524 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Joao-Felisberto/devprivops/schema"
+	"github.com/Joao-Felisberto/devprivops/util"
+)
+
+// The media types of the RDF formats descriptions can be written in, by file extension
+var rdfContentTypes = map[string]string{
+	".ttl":    "text/turtle",
+	".nt":     "application/n-triples",
+	".jsonld": "application/ld+json",
+	".rdf":    "application/rdf+xml",
+}
+
+// The media type of an RDF file
+//
+// `file`: The file
+//
+// returns: the media type and whether the file is in a known RDF format
+func RDFContentType(file string) (string, bool) {
+	contentType, ok := rdfContentTypes[filepath.Ext(file)]
+	return contentType, ok
+}
+
+// The namespace of each `uris.yml` prefix, as `schema.YAMLtoRDF` expands it
+//
+// `uris`: The URI metadata
+//
+// returns: the namespaces by prefix
+func Namespaces(uris []URIMetadata) map[string]string {
+	namespaces := map[string]string{}
+	for _, uri := range uris {
+		namespace := uri.URI
+		if !strings.HasSuffix(namespace, "/") && !strings.HasSuffix(namespace, "#") {
+			namespace += "/"
+		}
+		namespaces[uri.Abreviation] = namespace
+	}
+	return namespaces
+}
+
+// Makes prefixes available to an RDF document that does not declare them itself.
+// Turtle gets them as `@prefix` directives before its own, which take precedence, and JSON-LD as terms of its context.
+// Other formats are returned as they are.
+//
+// `data`: The document
+//
+// `contentType`: The media type of the document
+//
+// `namespaces`: The namespace of each prefix
+//
+// returns: the document with the prefixes, or an error if a JSON-LD document is not valid JSON
+func MergePrefixes(data []byte, contentType string, namespaces map[string]string) ([]byte, error) {
+	prefixes := make([]string, 0, len(namespaces))
+	for prefix := range namespaces {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+
+	switch contentType {
+	case "text/turtle":
+		var merged strings.Builder
+		for _, prefix := range prefixes {
+			fmt.Fprintf(&merged, "@prefix %s: <%s> .\n", prefix, namespaces[prefix])
+		}
+		merged.Write(data)
+		return []byte(merged.String()), nil
+	case "application/ld+json":
+		var doc interface{}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("invalid JSON-LD: %s", err)
+		}
+		terms := map[string]interface{}{}
+		for _, prefix := range prefixes {
+			terms[prefix] = namespaces[prefix]
+		}
+		obj, ok := doc.(map[string]interface{})
+		if !ok {
+			// a top level array has no context of its own
+			doc = map[string]interface{}{"@context": terms, "@graph": doc}
+			return json.Marshal(doc)
+		}
+		switch ctx := obj["@context"].(type) {
+		case nil:
+			obj["@context"] = terms
+		case map[string]interface{}:
+			for prefix, namespace := range terms {
+				if _, declared := ctx[prefix]; !declared {
+					ctx[prefix] = namespace
+				}
+			}
+		case []interface{}:
+			obj["@context"] = append([]interface{}{terms}, ctx...)
+		default:
+			obj["@context"] = []interface{}{terms, ctx}
+		}
+		return json.Marshal(obj)
+	default:
+		return data, nil
+	}
+}
+
+// The kinds of RDF terms
+type TermKind int
+
+const (
+	IRI     TermKind = iota // An IRI
+	BLANK                   // A blank node
+	LITERAL                 // A literal
+)
+
+// The datatypes of literals that need no explicit datatype
+const (
+	XSD_STRING  = "http://www.w3.org/2001/XMLSchema#string"
+	XSD_BOOLEAN = "http://www.w3.org/2001/XMLSchema#boolean"
+)
+
+// An RDF term
+type Term struct {
+	Kind     TermKind // What kind of term it is
+	Value    string   // The IRI without brackets, the blank node as `_:label` or the literal's lexical form
+	Datatype string   // The datatype of a literal, "" for plain strings
+	Lang     string   // The language tag of a literal, or ""
+}
+
+// An RDF triple
+type RDFTriple struct {
+	Subject   Term // The subject
+	Predicate Term // The predicate
+	Object    Term // The object
+}
+
+// The term in N-Triples syntax
+func (t Term) String() string {
+	switch t.Kind {
+	case IRI:
+		return fmt.Sprintf("<%s>", t.Value)
+	case BLANK:
+		return t.Value
+	default:
+		literal := fmt.Sprintf(`"%s"`, escapeLiteral(t.Value))
+		if t.Lang != "" {
+			return fmt.Sprintf("%s@%s", literal, t.Lang)
+		}
+		if t.Datatype != "" && t.Datatype != XSD_STRING {
+			return fmt.Sprintf("%s^^<%s>", literal, t.Datatype)
+		}
+		return literal
+	}
+}
+
+// The triple in N-Triples syntax
+func (t RDFTriple) String() string {
+	return fmt.Sprintf("%s %s %s .", t.Subject, t.Predicate, t.Object)
+}
+
+// Escapes the lexical form of a literal for N-Triples and Turtle
+//
+// `value`: The lexical form
+//
+// returns: the escaped form
+func escapeLiteral(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`).Replace(value)
+}
+
+// Converts a term as written in the SPARQL updates that load YAML descriptions, see `schema.NewTriple`
+//
+// `term`: The term, an IRI in brackets, a quoted string or a boolean
+//
+// returns: the term
+func TermFromSPARQL(term string) Term {
+	switch {
+	case strings.HasPrefix(term, "<") && strings.HasSuffix(term, ">"):
+		return Term{Kind: IRI, Value: term[1 : len(term)-1]}
+	case term == "true" || term == "false":
+		return Term{Kind: LITERAL, Value: term, Datatype: XSD_BOOLEAN}
+	case len(term) >= 2 && strings.HasPrefix(term, `"`) && strings.HasSuffix(term, `"`):
+		return Term{Kind: LITERAL, Value: term[1 : len(term)-1]}
+	default:
+		return Term{Kind: LITERAL, Value: term}
+	}
+}
+
+// Converts the triples `schema.YAMLtoRDF` makes out of a YAML description
+//
+// `triples`: The triples
+//
+// returns: the triples as terms
+func TriplesFromSchema(triples []schema.Triple) []RDFTriple {
+	return util.Map(triples, func(t schema.Triple) RDFTriple {
+		return RDFTriple{
+			Subject:   TermFromSPARQL(t.Subject),
+			Predicate: TermFromSPARQL(t.Predicate),
+			Object:    TermFromSPARQL(fmt.Sprint(t.Object)),
+		}
+	})
+}
+
+// The predicate that points configuration variables to their values
+const CFG_VALUE = "https://devprivops.com/config/value"
+
+// Applies a configuration to triples in memory, as `ApplyConfig` does in the triple store:
+// every object that has a `cfg:value` is replaced by that value
+//
+// `triples`: The triples of the descriptions and of the configuration
+//
+// returns: the triples with the configuration applied
+func ApplyConfigTriples(triples []RDFTriple) []RDFTriple {
+	values := map[string][]Term{}
+	for _, t := range triples {
+		if t.Predicate.Value == CFG_VALUE {
+			values[t.Subject.String()] = append(values[t.Subject.String()], t.Object)
+		}
+	}
+
+	applied := []RDFTriple{}
+	for _, t := range triples {
+		newValues, configured := values[t.Object.String()]
+		if !configured {
+			applied = append(applied, t)
+			continue
+		}
+		for _, v := range newValues {
+			applied = append(applied, RDFTriple{Subject: t.Subject, Predicate: t.Predicate, Object: v})
+		}
+	}
+	return applied
+}
+
+// Sorts triples and removes duplicates, as a graph is a set of triples
+//
+// `triples`: The triples
+//
+// returns: the distinct triples, sorted by their N-Triples form
+func SortTriples(triples []RDFTriple) []RDFTriple {
+	seen := map[string]bool{}
+	distinct := []RDFTriple{}
+	for _, t := range triples {
+		if key := t.String(); !seen[key] {
+			seen[key] = true
+			distinct = append(distinct, t)
+		}
+	}
+	sort.Slice(distinct, func(i, j int) bool { return distinct[i].String() < distinct[j].String() })
+	return distinct
+}
+
+// The triples of one graph that are not in another
+//
+// `after`: The graph with the triples to keep
+//
+// `before`: The graph with the triples to leave out
+//
+// returns: the triples only in `after`, sorted
+func DiffTriples(after []RDFTriple, before []RDFTriple) []RDFTriple {
+	existing := map[string]bool{}
+	for _, t := range before {
+		existing[t.String()] = true
+	}
+	return SortTriples(util.Filter(after, func(t RDFTriple) bool { return !existing[t.String()] }))
+}
+
+// Shortens an IRI with the namespaces, if the rest is a simple local name, preferring the longest namespace
+//
+// `iri`: The IRI
+//
+// `namespaces`: The namespace of each prefix
+//
+// returns: the prefixed name and whether the IRI could be shortened
+func CompactIRI(iri string, namespaces map[string]string) (string, bool) {
+	best := ""
+	for prefix, namespace := range namespaces {
+		local, ok := strings.CutPrefix(iri, namespace)
+		if ok && localNameRe.MatchString(local) && (best == "" || len(namespaces[best]) < len(namespace)) {
+			best = prefix
+		}
+	}
+	if best == "" {
+		return "", false
+	}
+	return fmt.Sprintf("%s:%s", best, strings.TrimPrefix(iri, namespaces[best])), true
+}
+
+// Regex for the local names prefixed names can have in every serialization
+var localNameRe = regexp.MustCompile(`^[A-Za-z0-9_]([A-Za-z0-9_.-]*[A-Za-z0-9_-])?$`)
+
+// Writes triples as N-Triples
+//
+// `w`: Where to write them
+//
+// `triples`: The triples
+//
+// returns: an error if writing fails
+func WriteNTriples(w io.Writer, triples []RDFTriple) error {
+	for _, t := range SortTriples(triples) {
+		if _, err := fmt.Fprintln(w, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Writes triples as Turtle, grouped by subject and with the namespaces as prefixes
+//
+// `w`: Where to write them
+//
+// `triples`: The triples
+//
+// `namespaces`: The namespace of each prefix
+//
+// returns: an error if writing fails
+func WriteTurtle(w io.Writer, triples []RDFTriple, namespaces map[string]string) error {
+	turtleTerm := func(t Term) string {
+		if t.Kind == IRI {
+			if name, ok := CompactIRI(t.Value, namespaces); ok {
+				return name
+			}
+		}
+		if t.Kind == LITERAL && t.Datatype == XSD_BOOLEAN {
+			return t.Value
+		}
+		return t.String()
+	}
+
+	var out strings.Builder
+	prefixes := make([]string, 0, len(namespaces))
+	for prefix := range namespaces {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	for _, prefix := range prefixes {
+		fmt.Fprintf(&out, "@prefix %s: <%s> .\n", prefix, namespaces[prefix])
+	}
+
+	var subject, predicate string
+	for _, t := range SortTriples(triples) {
+		s, p, o := turtleTerm(t.Subject), turtleTerm(t.Predicate), turtleTerm(t.Object)
+		if t.Predicate.Value == RDF_TYPE {
+			p = "a"
+		}
+		switch {
+		case s != subject:
+			if subject != "" {
+				out.WriteString(" .\n")
+			}
+			fmt.Fprintf(&out, "\n%s %s %s", s, p, o)
+		case p != predicate:
+			fmt.Fprintf(&out, " ;\n    %s %s", p, o)
+		default:
+			fmt.Fprintf(&out, ", %s", o)
+		}
+		subject, predicate = s, p
+	}
+	if subject != "" {
+		out.WriteString(" .\n")
+	}
+
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+// Writes triples as JSON-LD, a node object per subject with the namespaces as the context
+//
+// `w`: Where to write them
+//
+// `triples`: The triples
+//
+// `namespaces`: The namespace of each prefix
+//
+// returns: an error if writing fails
+func WriteJSONLD(w io.Writer, triples []RDFTriple, namespaces map[string]string) error {
+	jsonldIRI := func(iri string) string {
+		if name, ok := CompactIRI(iri, namespaces); ok {
+			return name
+		}
+		return iri
+	}
+
+	nodes := []map[string]interface{}{}
+	bySubject := map[string]map[string]interface{}{}
+	for _, t := range SortTriples(triples) {
+		id := t.Subject.Value
+		if t.Subject.Kind == IRI {
+			id = jsonldIRI(id)
+		}
+		node, ok := bySubject[id]
+		if !ok {
+			node = map[string]interface{}{"@id": id}
+			bySubject[id] = node
+			nodes = append(nodes, node)
+		}
+
+		var value map[string]interface{}
+		switch t.Object.Kind {
+		case IRI:
+			value = map[string]interface{}{"@id": jsonldIRI(t.Object.Value)}
+		case BLANK:
+			value = map[string]interface{}{"@id": t.Object.Value}
+		default:
+			value = map[string]interface{}{"@value": t.Object.Value}
+			if t.Object.Lang != "" {
+				value["@language"] = t.Object.Lang
+			} else if t.Object.Datatype != "" && t.Object.Datatype != XSD_STRING {
+				value["@type"] = t.Object.Datatype
+			}
+		}
+		key := jsonldIRI(t.Predicate.Value)
+		if t.Predicate.Value == RDF_TYPE && t.Object.Kind != LITERAL {
+			key, value = "@type", nil
+		}
+		values, _ := node[key].([]interface{})
+		if value == nil {
+			node[key] = append(values, jsonldIRI(t.Object.Value))
+		} else {
+			node[key] = append(values, value)
+		}
+	}
+
+	context := map[string]interface{}{}
+	for prefix, namespace := range namespaces {
+		context[prefix] = namespace
+	}
+	data, err := json.MarshalIndent(map[string]interface{}{"@context": context, "@graph": nodes}, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// Parses an N-Triples document
+//
+// `text`: The document
+//
+// returns: the triples or an error if a line is not a valid triple
+func ParseNTriples(text string) ([]RDFTriple, error) {
+	triples := []RDFTriple{}
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		terms := []Term{}
+		rest := line
+		for len(terms) < 3 {
+			term, remaining, err := nextNTriplesTerm(strings.TrimLeft(rest, " \t"))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", i+1, err)
+			}
+			terms = append(terms, term)
+			rest = remaining
+		}
+		if strings.TrimSpace(rest) != "." {
+			return nil, fmt.Errorf("line %d: expected '.' at the end of the triple", i+1)
+		}
+		triples = append(triples, RDFTriple{Subject: terms[0], Predicate: terms[1], Object: terms[2]})
+	}
+	return triples, nil
+}
+
+// Reads the first term of a line of an N-Triples document
+//
+// `s`: The rest of the line, starting at the term
+//
+// returns: the term, the rest of the line after it, or an error if there is no valid term
+func nextNTriplesTerm(s string) (Term, string, error) {
+	switch {
+	case strings.HasPrefix(s, "<"):
+		end := strings.Index(s, ">")
+		if end == -1 {
+			return Term{}, "", fmt.Errorf("unterminated IRI")
+		}
+		return Term{Kind: IRI, Value: s[1:end]}, s[end+1:], nil
+	case strings.HasPrefix(s, "_:"):
+		end := strings.IndexAny(s, " \t")
+		if end == -1 {
+			return Term{}, "", fmt.Errorf("unterminated blank node")
+		}
+		return Term{Kind: BLANK, Value: s[:end]}, s[end:], nil
+	case strings.HasPrefix(s, `"`):
+		end := 1
+		for ; end < len(s); end++ {
+			if s[end] == '\\' {
+				end++
+			} else if s[end] == '"' {
+				break
+			}
+		}
+		if end >= len(s) {
+			return Term{}, "", fmt.Errorf("unterminated literal")
+		}
+		value, err := strconv.Unquote(strings.ReplaceAll(s[:end+1], `\'`, "'"))
+		if err != nil {
+			return Term{}, "", fmt.Errorf("invalid literal %s: %s", s[:end+1], err)
+		}
+		literal := Term{Kind: LITERAL, Value: value}
+		rest := s[end+1:]
+		if strings.HasPrefix(rest, "^^<") {
+			typeEnd := strings.Index(rest, ">")
+			if typeEnd == -1 {
+				return Term{}, "", fmt.Errorf("unterminated datatype IRI")
+			}
+			literal.Datatype = rest[3:typeEnd]
+			rest = rest[typeEnd+1:]
+		} else if strings.HasPrefix(rest, "@") {
+			end := strings.IndexAny(rest+" ", " \t")
+			literal.Lang = rest[1:end]
+			rest = rest[end:]
+		}
+		return literal, rest, nil
+	default:
+		return Term{}, "", fmt.Errorf("expected an IRI, blank node or literal")
+	}
+}
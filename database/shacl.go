@@ -0,0 +1,91 @@
+package database
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// The SHACL vocabulary
+const SHACL_URI = "http://www.w3.org/ns/shacl#"
+
+// The rdf:type predicate
+const RDF_TYPE = "http://www.w3.org/1999/02/22-rdf-syntax-ns#type"
+
+// The keys of a SHACL validation result in the report, by the predicate they come from
+var shaclResultKeys = map[string]string{
+	SHACL_URI + "focusNode":      "focus node",
+	SHACL_URI + "resultPath":     "path",
+	SHACL_URI + "resultMessage":  "message",
+	SHACL_URI + "resultSeverity": "severity",
+	SHACL_URI + "sourceShape":    "shape",
+	SHACL_URI + "value":          "value",
+}
+
+// Validates the default graph against SHACL shapes using the triple store's SHACL service
+//
+// `file`: the Turtle file with the shapes
+//
+// returns: a map with the focus node, path, message, severity, shape and value of each validation result, those it has,
+// or an error if reading the file or validating fails
+func (db *DBManager) ValidateShapes(file string) ([]map[string]interface{}, error) {
+	shapes, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not read shapes file '%s': %s", file, err)
+	}
+
+	response, err := db.post("shacl?graph=default", "text/turtle", "application/n-triples", shapes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate shapes '%s': %s", file, err)
+	}
+	defer response.Body.Close()
+
+	resTxt, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read validation report of '%s': %s", file, err)
+	}
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return nil, fmt.Errorf("shapes '%s' were rejected with status %d: %s", file, response.StatusCode, strings.TrimSpace(string(resTxt)))
+	}
+
+	triples, err := ParseNTriples(string(resTxt))
+	if err != nil {
+		return nil, fmt.Errorf("invalid validation report of '%s': %s", file, err)
+	}
+	return validationResults(triples), nil
+}
+
+// Extracts the validation results of a SHACL validation report
+//
+// `report`: The triples of the report
+//
+// returns: a map with the focus node, path, message, severity, shape and value of each validation result, those it has, sorted by focus node and path
+func validationResults(report []RDFTriple) []map[string]interface{} {
+	results := map[string]map[string]interface{}{}
+	for _, t := range report {
+		if t.Predicate.Value == RDF_TYPE && t.Object.Value == SHACL_URI+"ValidationResult" {
+			results[t.Subject.Value] = map[string]interface{}{}
+		}
+	}
+	for _, t := range report {
+		result, isResult := results[t.Subject.Value]
+		key, isKey := shaclResultKeys[t.Predicate.Value]
+		if !isResult || !isKey {
+			continue
+		}
+		result[key] = strings.TrimPrefix(t.Object.Value, SHACL_URI)
+	}
+
+	list := []map[string]interface{}{}
+	for _, r := range results {
+		list = append(list, r)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a := fmt.Sprintf("%v %v %v", list[i]["focus node"], list[i]["path"], list[i]["message"])
+		b := fmt.Sprintf("%v %v %v", list[j]["focus node"], list[j]["path"], list[j]["message"])
+		return a < b
+	})
+	return list
+}
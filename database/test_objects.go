@@ -4,36 +4,183 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Joao-Felisberto/devprivops/util"
+	"gopkg.in/yaml.v2"
 )
 
 // Represents a scenario on which to run tests
 type TestScenario struct {
-	StateDir string `json:"stateDir"` // The directory where the state descriptions are stored
-	Tests    []Test `json:"tests"`    // The list of tests to run
+	StateDir string `json:"stateDir" yaml:"state dir"` // The directory where the state descriptions are stored
+	Tests    []Test `json:"tests" yaml:"tests"`        // The list of tests to run
 }
 
-// Represents a single test to be executed in a specific scenario
+// Represents a single test to be executed in a specific scenario.
+// Every assertion given must hold for the test to pass.
 type Test struct {
-	Query          string                   `json:"query"`          // The query to test
-	ExpectedResult []map[string]interface{} `json:"expectedResult"` // The expected result for the scenario
+	Query          string                   `json:"query" yaml:"query"`                     // The query to test
+	Policy         string                   `json:"policy" yaml:"policy"`                   // The title of the policy to test instead of a query, as `regulation/title` or just the title
+	ExpectedResult []map[string]interface{} `json:"expectedResult" yaml:"expected result"`  // The exact results, in any order
+	Contains       []map[string]interface{} `json:"contains" yaml:"contains"`               // Bindings that some result must have, for each entry
+	DoesNotContain []map[string]interface{} `json:"doesNotContain" yaml:"does not contain"` // Bindings that no result can have, for each entry
+	Count          *int                     `json:"count" yaml:"count"`                     // The number of results
+	CountAtLeast   *int                     `json:"countAtLeast" yaml:"count at least"`     // The minimum number of results
+	Empty          *bool                    `json:"empty" yaml:"empty"`                     // Whether there are no results
+	Matches        map[string]string        `json:"matches" yaml:"matches"`                 // A regular expression that the value of each variable in every result must match
 }
 
 // Get all test scenarios and associated tests from a file where theya re specified
 //
-// `file`: The file containing the test scenarios
+// `file`: The file containing the test scenarios, in YAML if its extension is `.yml` or `.yaml` and in JSON otherwise
 //
-// returns: the list of test scenarios and tests for each or an error if the file could not be read or parsed
+// returns: the list of test scenarios and tests for each or an error if the file could not be read or parsed or a test is invalid
 func TestsFromFile(file string) ([]TestScenario, error) {
-	jsonData, err := os.ReadFile(file)
+	data, err := os.ReadFile(file)
 	if err != nil {
 		return nil, fmt.Errorf("error reading file: %s", err)
 	}
 
-	// Unmarshal YAML data
 	var tests []TestScenario
-	if err := json.Unmarshal(jsonData, &tests); err != nil {
+	if strings.HasSuffix(file, ".yml") || strings.HasSuffix(file, ".yaml") {
+		if err := yaml.UnmarshalStrict(data, &tests); err != nil {
+			return nil, fmt.Errorf("error reading YAML file: %s", err)
+		}
+	} else if err := json.Unmarshal(data, &tests); err != nil {
 		return nil, fmt.Errorf("error reading JSON file: %s", err)
 	}
 
+	for _, scenario := range tests {
+		for i, t := range scenario.Tests {
+			if err := t.validate(); err != nil {
+				return nil, fmt.Errorf("test %d of scenario '%s': %s", i+1, scenario.StateDir, err)
+			}
+			// results are compared as the triple store returns them, as strings
+			for _, rows := range [][]map[string]interface{}{t.ExpectedResult, t.Contains, t.DoesNotContain} {
+				for _, row := range rows {
+					for k, v := range row {
+						row[k] = fmt.Sprint(v)
+					}
+				}
+			}
+		}
+	}
+
 	return tests, nil
 }
+
+// The name of a test, for messages
+//
+// returns: the query or the policy it tests
+func (t *Test) Name() string {
+	if t.Policy != "" {
+		return "policy " + t.Policy
+	}
+	return t.Query
+}
+
+// Checks that a test has either a query or a policy, at least one assertion and valid regular expressions
+//
+// returns: an error describing the first problem found
+func (t *Test) validate() error {
+	if (t.Query == "") == (t.Policy == "") {
+		return fmt.Errorf("exactly one of 'query' or 'policy' must be given")
+	}
+	if t.ExpectedResult == nil && t.Contains == nil && t.DoesNotContain == nil && t.Count == nil && t.CountAtLeast == nil && t.Empty == nil && t.Matches == nil {
+		return fmt.Errorf("%s has no assertions", t.Name())
+	}
+	for variable, expr := range t.Matches {
+		if _, err := regexp.Compile(expr); err != nil {
+			return fmt.Errorf("invalid regular expression for '%s': %s", variable, err)
+		}
+	}
+	return nil
+}
+
+// Checks the results of a test's query or policy against its assertions
+//
+// `results`: The results
+//
+// returns: a description of every assertion that does not hold, none if the test passes
+func (t *Test) Check(results []map[string]interface{}) []string {
+	failures := []string{}
+	if t.ExpectedResult != nil && !util.CompareSets(t.ExpectedResult, results) {
+		failures = append(failures, "the results are not the expected ones")
+	}
+	for _, row := range t.Contains {
+		if !util.Any(results, func(res map[string]interface{}) bool { return hasBindings(res, row) }) {
+			failures = append(failures, fmt.Sprintf("no result has %s", formatBindings(row)))
+		}
+	}
+	for _, row := range t.DoesNotContain {
+		if util.Any(results, func(res map[string]interface{}) bool { return hasBindings(res, row) }) {
+			failures = append(failures, fmt.Sprintf("a result has %s", formatBindings(row)))
+		}
+	}
+	if t.Count != nil && len(results) != *t.Count {
+		failures = append(failures, fmt.Sprintf("expected %d results, got %d", *t.Count, len(results)))
+	}
+	if t.CountAtLeast != nil && len(results) < *t.CountAtLeast {
+		failures = append(failures, fmt.Sprintf("expected at least %d results, got %d", *t.CountAtLeast, len(results)))
+	}
+	if t.Empty != nil && *t.Empty && len(results) != 0 {
+		failures = append(failures, fmt.Sprintf("expected no results, got %d", len(results)))
+	}
+	if t.Empty != nil && !*t.Empty && len(results) == 0 {
+		failures = append(failures, "expected results, got none")
+	}
+
+	variables := []string{}
+	for variable := range t.Matches {
+		variables = append(variables, variable)
+	}
+	sort.Strings(variables)
+	for _, variable := range variables {
+		re := regexp.MustCompile(t.Matches[variable])
+		for _, res := range results {
+			value, bound := res[variable]
+			if !bound {
+				failures = append(failures, fmt.Sprintf("a result has no '%s'", variable))
+				break
+			}
+			if !re.MatchString(fmt.Sprint(value)) {
+				failures = append(failures, fmt.Sprintf("'%s' of a result is '%v', which does not match '%s'", variable, value, t.Matches[variable]))
+				break
+			}
+		}
+	}
+	return failures
+}
+
+// Whether a result has some bindings, ignoring its other variables
+//
+// `result`: The result
+//
+// `bindings`: The bindings
+//
+// returns: whether every variable of the bindings has the same value in the result
+func hasBindings(result map[string]interface{}, bindings map[string]interface{}) bool {
+	for k, v := range bindings {
+		if value, bound := result[k]; !bound || !reflect.DeepEqual(value, v) {
+			return false
+		}
+	}
+	return true
+}
+
+// Writes bindings for messages
+//
+// `bindings`: The bindings
+//
+// returns: the bindings as `variable = 'value'`, sorted by variable
+func formatBindings(bindings map[string]interface{}) string {
+	parts := []string{}
+	for k, v := range bindings {
+		parts = append(parts, fmt.Sprintf("%s = '%v'", k, v))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
@@ -1,6 +1,10 @@
 package database
 
-import "github.com/Joao-Felisberto/devprivops/util"
+import (
+	"fmt"
+
+	"github.com/Joao-Felisberto/devprivops/util"
+)
 
 // Describes a user story and its associated requirements
 type UserStory struct {
@@ -13,11 +17,12 @@ type UserStory struct {
 
 // Describes a requirement
 type Requirement struct {
-	Title        string   // The requirement's title
-	Description  string   // Its description
-	Query        string   // The query that encodes the requirement validation
-	ClearenceLvl int      // The minimum hierarchical level required to see this in the visualizer
-	Groups       []string // The groups allowed to see this in the visualizer
+	Title        string        // The requirement's title
+	Description  string        // Its description
+	Query        string        // The query that encodes the requirement validation
+	ClearenceLvl int           // The minimum hierarchical level required to see this in the visualizer
+	Groups       []string      // The groups allowed to see this in the visualizer
+	Severity     util.Severity // How serious it is for the requirement not to be met, or for a misuse case to be possible
 }
 
 // Reads the user stories from a map.
@@ -45,6 +50,11 @@ func USFromYAML(yaml []interface{}) ([]*UserStory, error) {
 			clearenceLvl := req["clearence level"].(int)
 			groupsRaw := us["groups"].([]interface{})
 			groups := util.Map(groupsRaw, func(raw interface{}) string { return raw.(string) })
+			severityName, _ := req["severity"].(string)
+			severity, err := util.ParseSeverity(severityName)
+			if err != nil {
+				return nil, fmt.Errorf("requirement '%s': %s", title, err)
+			}
 
 			requirements = append(requirements, Requirement{
 				Title:        title,
@@ -52,6 +62,7 @@ func USFromYAML(yaml []interface{}) ([]*UserStory, error) {
 				Query:        query,
 				ClearenceLvl: clearenceLvl,
 				Groups:       groups,
+				Severity:     severity,
 			})
 		}
 
@@ -0,0 +1,252 @@
+// Package for types and functions that deal with data flow diagram descriptions (`.dfd.yml` files)
+package dfd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// An element of the diagram that is only relevant for rendering by its identifier
+type Element struct {
+	Id string `yaml:"id"` // The element's identifier
+}
+
+// A data flow between two elements of the diagram
+type DataFlow struct {
+	Id   string   `yaml:"id"`   // The flow's identifier
+	From string   `yaml:"from"` // The identifier of the source element
+	To   string   `yaml:"to"`   // The identifier of the destination element
+	Data []string `yaml:"data"` // The identifiers of the data types that flow
+}
+
+// The subset of a data flow diagram description that is needed to draw it
+type DataFlowDiagram struct {
+	ExternalEntities []Element  `yaml:"external entities"` // The external entities, drawn as rectangles
+	Processes        []Element  `yaml:"processes"`         // The processes, drawn as circles
+	DataStores       []Element  `yaml:"data stores"`       // The data stores, drawn as open rectangles
+	DataFlows        []DataFlow `yaml:"data flows"`        // The data flows, drawn as labelled arrows
+}
+
+// The diagram formats a data flow diagram can be rendered to
+type RenderFormat string
+
+const (
+	DOT     RenderFormat = "dot"     // Graphviz DOT
+	MERMAID RenderFormat = "mermaid" // Mermaid flowchart
+	SVG     RenderFormat = "svg"     // SVG, produced by running Graphviz on the DOT output
+)
+
+// The fill colour of highlighted elements
+const highlightColor = "#f08080"
+
+// Reads the data flow diagram from a description file
+//
+// `file`: The `.dfd.yml` file
+//
+// returns: the diagram or an error if the file could not be read or parsed
+func NewDataFlowDiagramFromYaml(file string) (*DataFlowDiagram, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file: %s", err)
+	}
+
+	var diagram DataFlowDiagram
+	if err := yaml.Unmarshal(data, &diagram); err != nil {
+		return nil, fmt.Errorf("error reading YAML file '%s': %s", file, err)
+	}
+
+	return &diagram, nil
+}
+
+// Normalizes an identifier to the local name it gets as a URI,
+// dropping the `:` or `prefix:` that references it and replacing spaces by underscores,
+// so the same entity can be matched across descriptions and query results.
+//
+// `id`: The identifier as written in the description or the URI bound in a query result
+//
+// returns: the local name of the identifier
+func LocalName(id string) string {
+	if i := strings.LastIndexAny(id, "/#"); i >= 0 {
+		id = id[i+1:]
+	}
+	if i := strings.Index(id, ":"); i >= 0 {
+		id = id[i+1:]
+	}
+	return strings.ReplaceAll(id, " ", "_")
+}
+
+// Finds the local names of every URI bound in the policy violations of a report produced by the `analyse` command
+//
+// `reportFile`: The JSON report
+//
+// returns: the set of local names or an error if the report could not be read or parsed
+func ViolatingEntities(reportFile string) (map[string]bool, error) {
+	data, err := os.ReadFile(reportFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading report '%s': %s", reportFile, err)
+	}
+
+	var report struct {
+		Policies []struct {
+			Results []struct {
+				Violations []map[string]interface{} `json:"violations"`
+			} `json:"results"`
+		} `json:"policies"`
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("error parsing report '%s': %s", reportFile, err)
+	}
+
+	entities := map[string]bool{}
+	for _, regulation := range report.Policies {
+		for _, policy := range regulation.Results {
+			for _, violation := range policy.Violations {
+				for _, value := range violation {
+					if str, ok := value.(string); ok && strings.Contains(str, "://") {
+						entities[LocalName(str)] = true
+					}
+				}
+			}
+		}
+	}
+
+	return entities, nil
+}
+
+// Renders the diagram with the standard DFD shapes, labelling each flow with the data types it carries.
+//
+// `format`: The diagram format
+//
+// `highlight`: The local names of the elements to highlight, may be nil
+//
+// returns: the diagram source or an error if the format is not supported or Graphviz fails to produce the SVG
+func (diagram *DataFlowDiagram) Render(format RenderFormat, highlight map[string]bool) (string, error) {
+	switch format {
+	case DOT:
+		return diagram.renderDOT(highlight), nil
+	case MERMAID:
+		return diagram.renderMermaid(highlight), nil
+	case SVG:
+		dot := exec.Command("dot", "-Tsvg")
+		dot.Stdin = strings.NewReader(diagram.renderDOT(highlight))
+		var out, stderr bytes.Buffer
+		dot.Stdout = &out
+		dot.Stderr = &stderr
+		if err := dot.Run(); err != nil {
+			return "", fmt.Errorf("could not run graphviz, is 'dot' installed? %s %s", err, stderr.String())
+		}
+		return out.String(), nil
+	default:
+		return "", fmt.Errorf("unsupported format '%s', valid possibilities: [%s, %s, %s]", format, DOT, MERMAID, SVG)
+	}
+}
+
+// Produces the label of a flow from the data types it carries
+func flowLabel(flow DataFlow) string {
+	data := []string{}
+	for _, d := range flow.Data {
+		data = append(data, LocalName(d))
+	}
+	return strings.Join(data, ", ")
+}
+
+// Renders the diagram in the DOT format
+//
+// `highlight`: The local names of the elements to highlight
+//
+// returns: the DOT source
+func (diagram *DataFlowDiagram) renderDOT(highlight map[string]bool) string {
+	var sb strings.Builder
+	sb.WriteString("digraph dfd {\n")
+	sb.WriteString("\trankdir=LR;\n")
+
+	fill := func(id string) string {
+		if highlight[LocalName(id)] {
+			return fmt.Sprintf(", style=filled, fillcolor=\"%s\"", highlightColor)
+		}
+		return ""
+	}
+	for _, e := range diagram.ExternalEntities {
+		sb.WriteString(fmt.Sprintf("\t\"%s\" [shape=box%s];\n", LocalName(e.Id), fill(e.Id)))
+	}
+	for _, p := range diagram.Processes {
+		sb.WriteString(fmt.Sprintf("\t\"%s\" [shape=circle%s];\n", LocalName(p.Id), fill(p.Id)))
+	}
+	for _, s := range diagram.DataStores {
+		bgcolor := ""
+		if highlight[LocalName(s.Id)] {
+			bgcolor = fmt.Sprintf(" bgcolor=\"%s\"", highlightColor)
+		}
+		sb.WriteString(fmt.Sprintf(
+			"\t\"%s\" [shape=plaintext, label=<<table border=\"1\" sides=\"TB\" cellborder=\"0\"%s><tr><td>%s</td></tr></table>>];\n",
+			LocalName(s.Id), bgcolor, LocalName(s.Id),
+		))
+	}
+	for _, f := range diagram.DataFlows {
+		color := ""
+		if highlight[LocalName(f.Id)] {
+			color = fmt.Sprintf(", color=\"%s\", fontcolor=\"%s\"", highlightColor, highlightColor)
+		}
+		sb.WriteString(fmt.Sprintf("\t\"%s\" -> \"%s\" [label=\"%s\"%s];\n", LocalName(f.From), LocalName(f.To), flowLabel(f), color))
+	}
+
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// Renders the diagram as a Mermaid flowchart
+//
+// `highlight`: The local names of the elements to highlight
+//
+// returns: the Mermaid source
+func (diagram *DataFlowDiagram) renderMermaid(highlight map[string]bool) string {
+	var sb strings.Builder
+	sb.WriteString("flowchart LR\n")
+
+	// Mermaid ids cannot have most symbols, the names are kept in the labels
+	ids := map[string]string{}
+	nodeId := func(id string) string {
+		name := LocalName(id)
+		if _, ok := ids[name]; !ok {
+			ids[name] = fmt.Sprintf("n%d", len(ids))
+		}
+		return ids[name]
+	}
+
+	for _, e := range diagram.ExternalEntities {
+		sb.WriteString(fmt.Sprintf("\t%s[\"%s\"]\n", nodeId(e.Id), LocalName(e.Id)))
+	}
+	for _, p := range diagram.Processes {
+		sb.WriteString(fmt.Sprintf("\t%s((\"%s\"))\n", nodeId(p.Id), LocalName(p.Id)))
+	}
+	for _, s := range diagram.DataStores {
+		sb.WriteString(fmt.Sprintf("\t%s[(\"%s\")]\n", nodeId(s.Id), LocalName(s.Id)))
+	}
+	for i, f := range diagram.DataFlows {
+		sb.WriteString(fmt.Sprintf("\t%s -->|\"%s\"| %s\n", nodeId(f.From), flowLabel(f), nodeId(f.To)))
+		if highlight[LocalName(f.Id)] {
+			sb.WriteString(fmt.Sprintf("\tlinkStyle %d stroke:%s\n", i, highlightColor))
+		}
+	}
+
+	highlighted := []string{}
+	for name, id := range ids {
+		if highlight[name] {
+			highlighted = append(highlighted, id)
+		}
+	}
+	if len(highlighted) != 0 {
+		slices.Sort(highlighted)
+		sb.WriteString(fmt.Sprintf("\tclassDef violation fill:%s\n", highlightColor))
+		sb.WriteString(fmt.Sprintf("\tclass %s violation\n", strings.Join(highlighted, ",")))
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,267 @@
+// Tests for the dfd package
+package dfd_test
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Joao-Felisberto/devprivops/dfd"
+	"github.com/Joao-Felisberto/devprivops/schema"
+)
+
+// A small description with one element of each kind
+const dfdFile = `
+data types: []
+external entities:
+  - id: User
+processes:
+  - id: send message
+data stores:
+  - id: MessageDB
+data flows:
+  - id: :Send
+    from: :User
+    to: :send message
+    data:
+      - :Message
+      - dpia:Metadata
+  - id: :Store
+    from: :send message
+    to: :MessageDB
+    data:
+      - :Message
+`
+
+// Test for the LocalName function
+func TestLocalName(t *testing.T) {
+	cases := map[string]string{
+		"User":                             "User",
+		":send message":                    "send_message",
+		"dpia:personal":                    "personal",
+		"https://devprivops.com/dfd/Store": "Store",
+	}
+	for id, expected := range cases {
+		if name := dfd.LocalName(id); name != expected {
+			t.Errorf("Local name of '%s' should be '%s', got '%s'", id, expected, name)
+		}
+	}
+}
+
+// Test whether diagrams are rendered with the standard shapes and highlighted elements
+func TestRender(t *testing.T) {
+	if err := os.WriteFile("tmp.dfd.yml", []byte(dfdFile), 0666); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("tmp.dfd.yml")
+
+	diagram, err := dfd.NewDataFlowDiagramFromYaml("tmp.dfd.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	highlight := map[string]bool{"MessageDB": true, "Send": true}
+	expected := map[dfd.RenderFormat][]string{
+		dfd.DOT: {
+			`"User" [shape=box];`,
+			`"send_message" [shape=circle];`,
+			`sides="TB" cellborder="0" bgcolor="#f08080"><tr><td>MessageDB</td>`,
+			`"User" -> "send_message" [label="Message, Metadata", color="#f08080", fontcolor="#f08080"];`,
+			`"send_message" -> "MessageDB" [label="Message"];`,
+		},
+		dfd.MERMAID: {
+			`n0["User"]`,
+			`n1(("send_message"))`,
+			`n2[("MessageDB")]`,
+			`n0 -->|"Message, Metadata"| n1`,
+			"linkStyle 0 stroke:#f08080",
+			"class n2 violation",
+		},
+	}
+	for format, lines := range expected {
+		out, err := diagram.Render(format, highlight)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range lines {
+			if !strings.Contains(out, line) {
+				t.Errorf("%s diagram does not contain '%s':\n%s", format, line, out)
+			}
+		}
+	}
+
+	if _, err := diagram.Render("png", nil); err == nil {
+		t.Error("Rendering to an unsupported format should fail")
+	}
+}
+
+// Test whether the URIs bound in policy violations are found in a report
+func TestViolatingEntities(t *testing.T) {
+	report := `{"policies": [{"name": "gdpr", "results": [
+		{"name": "p1", "violations": [{"ds": "https://devprivops.com/dfd/MessageDB", "n": "3"}]},
+		{"name": "p2", "violations": []}
+	]}]}`
+	if err := os.WriteFile("tmp.json", []byte(report), 0666); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("tmp.json")
+
+	entities, err := dfd.ViolatingEntities("tmp.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(entities, map[string]bool{"MessageDB": true}) {
+		t.Errorf("Unexpected violating entities: %v", entities)
+	}
+}
+
+// A Threat Dragon v2 model with one element of each kind, a trust boundary and a threat
+const threatDragonFile = `{
+	"summary": {"title": "Chat"},
+	"detail": {"diagrams": [{"title": "Main", "cells": [
+		{"id": "a1", "shape": "actor", "data": {"type": "tm.Actor", "name": "User", "description": "A chat user", "threats": []}},
+		{"id": "p1", "shape": "process", "data": {"type": "tm.Process", "name": "Send", "threats": [{"title": "Spoofing"}]}},
+		{"id": "s1", "shape": "store", "data": {"type": "tm.Store", "name": "DB"}},
+		{"id": "f1", "shape": "flow", "source": {"cell": "a1"}, "target": {"cell": "p1"}, "data": {"type": "tm.Flow", "name": "message", "isEncrypted": true, "protocol": "HTTPS"}},
+		{"id": "f2", "shape": "flow", "source": {"cell": "p1"}, "target": {"cell": "s1"}, "data": {"type": "tm.Flow", "name": "message", "isEncrypted": false}},
+		{"id": "b1", "shape": "trust-boundary-box", "data": {"type": "tm.BoundaryBox", "name": "Internet"}}
+	]}]}
+}`
+
+// A Threat Modeling Tool model with an interactor, a process, a flow between them and a trust boundary
+const tmtFile = `<ThreatModel xmlns="http://schemas.datacontract.org/2004/07/ThreatModeling.Model" xmlns:i="http://www.w3.org/2001/XMLSchema-instance" xmlns:a="http://schemas.microsoft.com/2003/10/Serialization/Arrays" xmlns:b="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase">
+<DrawingSurfaceList><DrawingSurfaceModel>
+<Borders>
+	<a:KeyValueOfguidanyType><a:Key>g1</a:Key><a:Value i:type="StencilRectangle">
+		<GenericTypeId>GE.EI</GenericTypeId><Guid>g1</Guid>
+		<Properties><a:anyType i:type="b:StringDisplayAttribute"><b:DisplayName>Name</b:DisplayName><b:Value>Browser</b:Value></a:anyType></Properties>
+	</a:Value></a:KeyValueOfguidanyType>
+	<a:KeyValueOfguidanyType><a:Key>g2</a:Key><a:Value i:type="StencilEllipse">
+		<GenericTypeId>GE.P</GenericTypeId><Guid>g2</Guid>
+		<Properties><a:anyType i:type="b:StringDisplayAttribute"><b:DisplayName>Name</b:DisplayName><b:Value>Web App</b:Value></a:anyType></Properties>
+	</a:Value></a:KeyValueOfguidanyType>
+	<a:KeyValueOfguidanyType><a:Key>g3</a:Key><a:Value i:type="BorderBoundary">
+		<GenericTypeId>GE.TB.B</GenericTypeId><Guid>g3</Guid>
+		<Properties><a:anyType i:type="b:StringDisplayAttribute"><b:DisplayName>Name</b:DisplayName><b:Value>DMZ</b:Value></a:anyType></Properties>
+	</a:Value></a:KeyValueOfguidanyType>
+</Borders>
+<Lines>
+	<a:KeyValueOfguidanyType><a:Key>g4</a:Key><a:Value i:type="Connector">
+		<GenericTypeId>GE.DF</GenericTypeId><Guid>g4</Guid><SourceGuid>g1</SourceGuid><TargetGuid>g2</TargetGuid>
+		<Properties><a:anyType i:type="b:StringDisplayAttribute"><b:DisplayName>Name</b:DisplayName><b:Value>Request</b:Value></a:anyType></Properties>
+	</a:Value></a:KeyValueOfguidanyType>
+</Lines>
+</DrawingSurfaceModel></DrawingSurfaceList>
+</ThreatModel>`
+
+// Test whether Threat Dragon models are converted into descriptions
+func TestImportThreatDragon(t *testing.T) {
+	if err := os.WriteFile("tmp.json", []byte(threatDragonFile), 0666); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("tmp.json")
+
+	desc, err := dfd.ImportThreatDragon("tmp.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(desc.ExternalEntities) != 1 || desc.ExternalEntities[0].Id != "User" {
+		t.Errorf("Unexpected external entities: %v", desc.ExternalEntities)
+	}
+	if len(desc.Processes) != 1 || desc.Processes[0].Id != "Send" {
+		t.Errorf("Unexpected processes: %v", desc.Processes)
+	}
+	if len(desc.DataStores) != 1 || desc.DataStores[0].Id != "DB" {
+		t.Errorf("Unexpected data stores: %v", desc.DataStores)
+	}
+	if len(desc.DataFlows) != 2 {
+		t.Fatalf("Expected 2 data flows, got %v", desc.DataFlows)
+	}
+	f1, f2 := desc.DataFlows[0], desc.DataFlows[1]
+	if f1.Id != ":message" || f1.From != ":User" || f1.To != ":Send" || f1.Encryption != "HTTPS" {
+		t.Errorf("Unexpected first flow: %v", f1)
+	}
+	if f2.Id != ":message 2" || f2.From != ":Send" || f2.To != ":DB" || f2.Encryption != "none" {
+		t.Errorf("Unexpected second flow: %v", f2)
+	}
+
+	kinds := []string{}
+	for _, a := range desc.Annotations {
+		kinds = append(kinds, a.Kind)
+	}
+	if !reflect.DeepEqual(kinds, []string{"tm.Actor", "threat", "tm.BoundaryBox"}) {
+		t.Errorf("Unexpected annotations: %v", desc.Annotations)
+	}
+}
+
+// Test whether Threat Modeling Tool models are converted into descriptions that abide by the schema
+func TestImportTMT(t *testing.T) {
+	if err := os.WriteFile("tmp.tm7", []byte(tmtFile), 0666); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("tmp.tm7")
+
+	desc, err := dfd.ImportTMT("tmp.tm7")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(desc.ExternalEntities) != 1 || desc.ExternalEntities[0].Id != "Browser" {
+		t.Errorf("Unexpected external entities: %v", desc.ExternalEntities)
+	}
+	if len(desc.Processes) != 1 || desc.Processes[0].Id != "Web App" {
+		t.Errorf("Unexpected processes: %v", desc.Processes)
+	}
+	if len(desc.DataFlows) != 1 || desc.DataFlows[0].From != ":Browser" || desc.DataFlows[0].To != ":Web App" {
+		t.Errorf("Unexpected data flows: %v", desc.DataFlows)
+	}
+	if len(desc.Annotations) != 1 || desc.Annotations[0].Name != "DMZ" {
+		t.Errorf("Unexpected annotations: %v", desc.Annotations)
+	}
+
+	data, err := desc.ToYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "#   name: DMZ") {
+		t.Errorf("The annotations were not written as comments:\n%s", data)
+	}
+	if err := os.WriteFile("tmp.dfd.yml", data, 0666); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove("tmp.dfd.yml")
+
+	res, err := schema.ValidateYAMLAgainstSchemaFile("tmp.dfd.yml", "../examples/global/schemas/dfd-schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Valid() {
+		t.Errorf("The imported description does not abide by the schema: %s", res.Errors())
+	}
+}
+
+// Test whether descriptions are built from the names of their elements
+func TestNewDescriptionFromNames(t *testing.T) {
+	desc := dfd.NewDescriptionFromNames(
+		[]string{"User"},
+		[]string{"Payments"},
+		[]string{"Ledger"},
+		[][2]string{{"User", "Payments"}, {"Payments", "Ledger"}, {"Payments", "Bank"}},
+	)
+
+	if len(desc.ExternalEntities) != 1 || len(desc.Processes) != 1 || len(desc.DataStores) != 1 {
+		t.Fatalf("Unexpected elements: %v", desc)
+	}
+	expected := []dfd.DataFlowDescription{
+		{Id: ":User to Payments", From: ":User", To: ":Payments", Data: []string{}, Encryption: "none", Certification: []string{}, Safeguards: []string{}},
+		{Id: ":Payments to Ledger", From: ":Payments", To: ":Ledger", Data: []string{}, Encryption: "none", Certification: []string{}, Safeguards: []string{}},
+	}
+	if !reflect.DeepEqual(desc.DataFlows, expected) {
+		t.Errorf("Data flows mismatch, expected %v, got %v", expected, desc.DataFlows)
+	}
+	if len(desc.Annotations) != 1 || desc.Annotations[0].Kind != "dangling data flow" {
+		t.Errorf("The flow to an unknown element should be kept as an annotation, got %v", desc.Annotations)
+	}
+}
@@ -0,0 +1,563 @@
+package dfd
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// An external entity with every field required by `dfd-schema.json`
+type ExternalEntityDescription struct {
+	Id                        string   `yaml:"id"`
+	Consumes                  []string `yaml:"consumes"`
+	Produces                  []string `yaml:"produces"`
+	Location                  []string `yaml:"location"`
+	Environment               []string `yaml:"environment"`
+	Categories                []string `yaml:"categories"`
+	Age                       *string  `yaml:"age"`
+	ProducesPublicInformation bool     `yaml:"produces public information"`
+	Safeguards                []string `yaml:"safeguards"`
+	Options                   []string `yaml:"options"`
+}
+
+// A process with every field required by `dfd-schema.json`
+type ProcessDescription struct {
+	Id            string   `yaml:"id"`
+	Consumes      []string `yaml:"consumes"`
+	Produces      []string `yaml:"produces"`
+	Location      []string `yaml:"location"`
+	Environment   []string `yaml:"environment"`
+	Purposes      []string `yaml:"purposes"`
+	Certification []string `yaml:"certification"`
+	Safeguards    []string `yaml:"safeguards"`
+}
+
+// A data store with every field required by `dfd-schema.json`
+type DataStoreDescription struct {
+	Id            string        `yaml:"id"`
+	DataStored    []interface{} `yaml:"data stored"`
+	Location      []string      `yaml:"location"`
+	Environment   []string      `yaml:"environment"`
+	Certification []string      `yaml:"certification"`
+	Safeguards    []string      `yaml:"safeguards"`
+}
+
+// A data flow with every field required by `dfd-schema.json`
+type DataFlowDescription struct {
+	Id                    string   `yaml:"id"`
+	From                  string   `yaml:"from"`
+	To                    string   `yaml:"to"`
+	Data                  []string `yaml:"data"`
+	Encryption            string   `yaml:"encryption"`
+	Periodicity           string   `yaml:"periodicity"`
+	AmountOfDataPerPeriod int      `yaml:"amount of data per period"`
+	Certification         []string `yaml:"certification"`
+	Safeguards            []string `yaml:"safeguards"`
+}
+
+// Information from an imported model that has no counterpart in a data flow diagram description
+type Annotation struct {
+	Kind       string            `yaml:"kind"`                 // What the information is, e.g. a trust boundary or a threat
+	Name       string            `yaml:"name"`                 // The name of the element in the original model
+	Element    string            `yaml:"element,omitempty"`    // The id of the description element the information is attached to, if any
+	Properties map[string]string `yaml:"properties,omitempty"` // Any other properties of the element in the original model
+}
+
+// A complete data flow diagram description, as written in `.dfd.yml` files
+type Description struct {
+	DataTypes        []interface{}               `yaml:"data types"`
+	ExternalEntities []ExternalEntityDescription `yaml:"external entities"`
+	Processes        []ProcessDescription        `yaml:"processes"`
+	DataStores       []DataStoreDescription      `yaml:"data stores"`
+	DataFlows        []DataFlowDescription       `yaml:"data flows"`
+	Annotations      []Annotation                `yaml:"-"` // Written as comments, since the schema does not allow them
+}
+
+// The kinds of element an imported model can have
+type elementKind int
+
+const (
+	kindExternalEntity elementKind = iota
+	kindProcess
+	kindDataStore
+	kindDataFlow
+	kindOther
+)
+
+// An element of an imported model, independent of the tool it came from
+type importedElement struct {
+	kind       elementKind
+	modelId    string            // The identifier in the original model
+	typeName   string            // The type in the original model, for annotations
+	name       string            // The display name
+	source     string            // For flows, the identifier in the original model of the source element
+	target     string            // For flows, the identifier in the original model of the destination element
+	encrypted  bool              // For flows, whether the flow is encrypted
+	protocol   string            // For flows, the protocol used
+	threats    []string          // The threats attached to the element
+	properties map[string]string // The properties with no counterpart
+}
+
+// Builds a description from the elements of an imported model.
+// Names are made unique, flows are linked to the imported elements, and everything that cannot be mapped is kept as an annotation.
+//
+// `elements`: The elements of the model
+//
+// returns: the description
+func newDescription(elements []importedElement) *Description {
+	desc := &Description{
+		DataTypes:        []interface{}{},
+		ExternalEntities: []ExternalEntityDescription{},
+		Processes:        []ProcessDescription{},
+		DataStores:       []DataStoreDescription{},
+		DataFlows:        []DataFlowDescription{},
+		Annotations:      []Annotation{},
+	}
+
+	used := map[string]int{}
+	ids := map[string]string{}
+	uniqueId := func(name string) string {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			name = "unnamed"
+		}
+		used[name]++
+		if used[name] > 1 {
+			return fmt.Sprintf("%s %d", name, used[name])
+		}
+		return name
+	}
+
+	for _, e := range elements {
+		if e.kind == kindDataFlow || e.kind == kindOther {
+			continue
+		}
+		id := uniqueId(e.name)
+		ids[e.modelId] = id
+		switch e.kind {
+		case kindExternalEntity:
+			desc.ExternalEntities = append(desc.ExternalEntities, ExternalEntityDescription{
+				Id: id, Consumes: []string{}, Produces: []string{}, Location: []string{}, Environment: []string{},
+				Categories: []string{}, Safeguards: []string{}, Options: []string{},
+			})
+		case kindProcess:
+			desc.Processes = append(desc.Processes, ProcessDescription{
+				Id: id, Consumes: []string{}, Produces: []string{}, Location: []string{}, Environment: []string{},
+				Purposes: []string{}, Certification: []string{}, Safeguards: []string{},
+			})
+		case kindDataStore:
+			desc.DataStores = append(desc.DataStores, DataStoreDescription{
+				Id: id, DataStored: []interface{}{}, Location: []string{}, Environment: []string{},
+				Certification: []string{}, Safeguards: []string{},
+			})
+		}
+	}
+
+	for _, e := range elements {
+		switch e.kind {
+		case kindDataFlow:
+			from, fromOk := ids[e.source]
+			to, toOk := ids[e.target]
+			if !fromOk || !toOk {
+				desc.Annotations = append(desc.Annotations, Annotation{
+					Kind: "dangling data flow", Name: e.name,
+					Properties: mergeProperties(e.properties, map[string]string{"source": e.source, "target": e.target}),
+				})
+				continue
+			}
+			id := uniqueId(e.name)
+			ids[e.modelId] = id
+			encryption := "none"
+			if e.encrypted {
+				encryption = "encrypted"
+				if e.protocol != "" {
+					encryption = e.protocol
+				}
+			}
+			desc.DataFlows = append(desc.DataFlows, DataFlowDescription{
+				Id: ":" + id, From: ":" + from, To: ":" + to, Data: []string{}, Encryption: encryption,
+				Certification: []string{}, Safeguards: []string{},
+			})
+		case kindOther:
+			desc.Annotations = append(desc.Annotations, Annotation{Kind: e.typeName, Name: e.name, Properties: e.properties})
+			continue
+		}
+
+		for _, threat := range e.threats {
+			desc.Annotations = append(desc.Annotations, Annotation{Kind: "threat", Name: threat, Element: ids[e.modelId]})
+		}
+		if len(e.properties) != 0 {
+			desc.Annotations = append(desc.Annotations, Annotation{Kind: e.typeName, Name: e.name, Element: ids[e.modelId], Properties: e.properties})
+		}
+	}
+
+	return desc
+}
+
+// Joins two property maps, ignoring empty values
+func mergeProperties(a map[string]string, b map[string]string) map[string]string {
+	res := map[string]string{}
+	for _, m := range []map[string]string{a, b} {
+		for k, v := range m {
+			if v != "" {
+				res[k] = v
+			}
+		}
+	}
+	return res
+}
+
+// Serializes the description as a `.dfd.yml` file, with the annotations as a trailing comment block
+//
+// returns: the file contents or an error if serialization fails
+func (desc *Description) ToYAML() ([]byte, error) {
+	data, err := yaml.Marshal(desc)
+	if err != nil {
+		return nil, fmt.Errorf("error serializing description: %s", err)
+	}
+	if len(desc.Annotations) == 0 {
+		return data, nil
+	}
+
+	annotations, err := yaml.Marshal(map[string][]Annotation{"annotations": desc.Annotations})
+	if err != nil {
+		return nil, fmt.Errorf("error serializing annotations: %s", err)
+	}
+	var sb strings.Builder
+	sb.Write(data)
+	sb.WriteString("\n# Elements of the imported model without a counterpart in the description\n")
+	for _, line := range strings.Split(strings.TrimRight(string(annotations), "\n"), "\n") {
+		sb.WriteString("# " + line + "\n")
+	}
+
+	return []byte(sb.String()), nil
+}
+
+// A cell of a Threat Dragon diagram, covering both the v1 and v2 formats
+type threatDragonCell struct {
+	Id     string                 `json:"id"`
+	Type   string                 `json:"type"`  // v1 element type, e.g. `tm.Actor`
+	Shape  string                 `json:"shape"` // v2 shape, e.g. `actor`
+	Name   string                 `json:"name"`
+	Source threatDragonEndpoint   `json:"source"`
+	Target threatDragonEndpoint   `json:"target"`
+	Data   map[string]interface{} `json:"data"` // v2 element properties
+	Attrs  map[string]interface{} `json:"attrs"`
+	Labels []interface{}          `json:"labels"`
+
+	Description string                   `json:"description"`
+	Protocol    string                   `json:"protocol"`
+	IsEncrypted bool                     `json:"isEncrypted"`
+	Threats     []map[string]interface{} `json:"threats"`
+}
+
+// The endpoint of a Threat Dragon flow, `id` in v1 and `cell` in v2
+type threatDragonEndpoint struct {
+	Id   string `json:"id"`
+	Cell string `json:"cell"`
+}
+
+// The parts of a Threat Dragon model that are imported
+type threatDragonModel struct {
+	Summary struct {
+		Title string `json:"title"`
+	} `json:"summary"`
+	Detail struct {
+		Diagrams []struct {
+			Title       string             `json:"title"`
+			Cells       []threatDragonCell `json:"cells"`
+			DiagramJson struct {
+				Cells []threatDragonCell `json:"cells"`
+			} `json:"diagramJson"`
+		} `json:"diagrams"`
+	} `json:"detail"`
+}
+
+// Reads an OWASP Threat Dragon JSON model (v1 or v2) into a description.
+// Actors become external entities, processes become processes, stores become data stores and flows become data flows.
+// Trust boundaries, threats and other properties are kept as annotations.
+//
+// `file`: The Threat Dragon JSON file
+//
+// returns: the description or an error if the file could not be read or parsed
+func ImportThreatDragon(file string) (*Description, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file: %s", err)
+	}
+
+	var model threatDragonModel
+	if err := json.Unmarshal(data, &model); err != nil {
+		return nil, fmt.Errorf("error reading Threat Dragon model '%s': %s", file, err)
+	}
+
+	elements := []importedElement{}
+	for _, diagram := range model.Detail.Diagrams {
+		cells := append(diagram.Cells, diagram.DiagramJson.Cells...)
+		for _, cell := range cells {
+			elements = append(elements, threatDragonElement(cell))
+		}
+	}
+
+	return newDescription(elements), nil
+}
+
+// Converts a Threat Dragon cell into an imported element
+func threatDragonElement(cell threatDragonCell) importedElement {
+	str := func(key string) string {
+		if v, ok := cell.Data[key].(string); ok {
+			return v
+		}
+		return ""
+	}
+
+	typeName := cell.Type
+	if t := str("type"); t != "" {
+		typeName = t
+	}
+	if typeName == "" {
+		typeName = cell.Shape
+	}
+
+	name := cell.Name
+	if n := str("name"); n != "" {
+		name = n
+	}
+	if name == "" && len(cell.Labels) != 0 {
+		name = fmt.Sprintf("%v", cell.Labels[0])
+	}
+
+	description := cell.Description
+	if d := str("description"); d != "" {
+		description = d
+	}
+	protocol := cell.Protocol
+	if p := str("protocol"); p != "" {
+		protocol = p
+	}
+	encrypted := cell.IsEncrypted
+	if e, ok := cell.Data["isEncrypted"].(bool); ok {
+		encrypted = e
+	}
+
+	threats := []string{}
+	rawThreats := cell.Threats
+	if t, ok := cell.Data["threats"].([]interface{}); ok {
+		for _, raw := range t {
+			if m, ok := raw.(map[string]interface{}); ok {
+				rawThreats = append(rawThreats, m)
+			}
+		}
+	}
+	for _, t := range rawThreats {
+		threats = append(threats, fmt.Sprintf("%v", t["title"]))
+	}
+
+	kind := kindOther
+	switch typeName {
+	case "tm.Actor", "actor":
+		kind = kindExternalEntity
+	case "tm.Process", "process":
+		kind = kindProcess
+	case "tm.Store", "store":
+		kind = kindDataStore
+	case "tm.Flow", "flow":
+		kind = kindDataFlow
+	}
+
+	properties := map[string]string{}
+	if description != "" {
+		properties["description"] = description
+	}
+	if kind == kindOther {
+		properties["id"] = cell.Id
+	}
+	if kind != kindDataFlow && protocol != "" {
+		properties["protocol"] = protocol
+	}
+
+	source := cell.Source.Cell
+	if source == "" {
+		source = cell.Source.Id
+	}
+	target := cell.Target.Cell
+	if target == "" {
+		target = cell.Target.Id
+	}
+
+	return importedElement{
+		kind:       kind,
+		modelId:    cell.Id,
+		typeName:   typeName,
+		name:       name,
+		source:     source,
+		target:     target,
+		encrypted:  encrypted,
+		protocol:   protocol,
+		threats:    threats,
+		properties: properties,
+	}
+}
+
+// A property of an element in a Microsoft Threat Modeling Tool model
+type tmtProperty struct {
+	DisplayName string `xml:"DisplayName"`
+	Name        string `xml:"Name"`
+	Value       string `xml:"Value"`
+}
+
+// An element of a Microsoft Threat Modeling Tool model, either a stencil or a connector
+type tmtElement struct {
+	Type          string        `xml:"type,attr"`
+	Guid          string        `xml:"Guid"`
+	GenericTypeId string        `xml:"GenericTypeId"`
+	TypeId        string        `xml:"TypeId"`
+	SourceGuid    string        `xml:"SourceGuid"`
+	TargetGuid    string        `xml:"TargetGuid"`
+	Properties    []tmtProperty `xml:"Properties>anyType"`
+}
+
+// The entries of the serialized guid to element dictionaries
+type tmtEntry struct {
+	Key   string     `xml:"Key"`
+	Value tmtElement `xml:"Value"`
+}
+
+// The parts of a Microsoft Threat Modeling Tool model that are imported
+type tmtModel struct {
+	Surfaces []struct {
+		Header  string     `xml:"Header"`
+		Borders []tmtEntry `xml:"Borders>KeyValueOfguidanyType"`
+		Lines   []tmtEntry `xml:"Lines>KeyValueOfguidanyType"`
+	} `xml:"DrawingSurfaceList>DrawingSurfaceModel"`
+}
+
+// Reads a Microsoft Threat Modeling Tool `.tm7` model into a description.
+// Interactors become external entities, processes become processes, data stores become data stores and data flows become data flows.
+// Trust boundaries and other properties are kept as annotations.
+//
+// `file`: The `.tm7` file
+//
+// returns: the description or an error if the file could not be read or parsed
+func ImportTMT(file string) (*Description, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file: %s", err)
+	}
+
+	var model tmtModel
+	if err := xml.Unmarshal(data, &model); err != nil {
+		return nil, fmt.Errorf("error reading Threat Modeling Tool model '%s': %s", file, err)
+	}
+
+	elements := []importedElement{}
+	for _, surface := range model.Surfaces {
+		for _, entry := range append(surface.Borders, surface.Lines...) {
+			elements = append(elements, tmtToElement(entry))
+		}
+	}
+
+	return newDescription(elements), nil
+}
+
+// Converts a Threat Modeling Tool element into an imported element
+func tmtToElement(entry tmtEntry) importedElement {
+	e := entry.Value
+	guid := e.Guid
+	if guid == "" {
+		guid = entry.Key
+	}
+
+	name := ""
+	properties := map[string]string{}
+	for _, p := range e.Properties {
+		key := p.DisplayName
+		if key == "" {
+			key = p.Name
+		}
+		value := strings.TrimSpace(p.Value)
+		switch {
+		case key == "Name":
+			name = value
+		case key != "" && value != "":
+			properties[key] = value
+		}
+	}
+
+	kind := kindOther
+	switch {
+	case e.GenericTypeId == "GE.EI" || e.Type == "StencilRectangle":
+		kind = kindExternalEntity
+	case e.GenericTypeId == "GE.P" || e.Type == "StencilEllipse":
+		kind = kindProcess
+	case e.GenericTypeId == "GE.DS" || e.Type == "StencilParallelLines":
+		kind = kindDataStore
+	case e.GenericTypeId == "GE.DF" || e.Type == "Connector":
+		kind = kindDataFlow
+	}
+
+	typeName := e.TypeId
+	if typeName == "" {
+		typeName = e.Type
+	}
+	if kind == kindOther {
+		properties["id"] = guid
+	}
+	protocol := properties["Protocol"]
+	encrypted := strings.EqualFold(properties["Is Encrypted"], "true") || strings.EqualFold(properties["Is Encrypted"], "yes")
+	if kind == kindDataFlow {
+		delete(properties, "Protocol")
+		delete(properties, "Is Encrypted")
+	}
+
+	return importedElement{
+		kind:       kind,
+		modelId:    guid,
+		typeName:   typeName,
+		name:       name,
+		source:     e.SourceGuid,
+		target:     e.TargetGuid,
+		protocol:   protocol,
+		encrypted:  encrypted,
+		threats:    []string{},
+		properties: properties,
+	}
+}
+
+// Builds a description from the names of its elements, as when asking the user about the system.
+//
+// `externalEntities`: The names of the external entities
+//
+// `processes`: The names of the processes
+//
+// `dataStores`: The names of the data stores
+//
+// `dataFlows`: The source and destination names of each data flow
+//
+// returns: the description, where flows between unknown elements are kept as annotations
+func NewDescriptionFromNames(externalEntities []string, processes []string, dataStores []string, dataFlows [][2]string) *Description {
+	elements := []importedElement{}
+	// The indexes match the values of kindExternalEntity, kindProcess and kindDataStore
+	for kind, names := range [][]string{externalEntities, processes, dataStores} {
+		for _, name := range names {
+			elements = append(elements, importedElement{kind: elementKind(kind), modelId: name, name: name, threats: []string{}})
+		}
+	}
+	for _, flow := range dataFlows {
+		elements = append(elements, importedElement{
+			kind:     kindDataFlow,
+			modelId:  flow[0] + "->" + flow[1],
+			typeName: "data flow",
+			name:     fmt.Sprintf("%s to %s", flow[0], flow[1]),
+			source:   flow[0],
+			target:   flow[1],
+			threats:  []string{},
+		})
+	}
+
+	return newDescription(elements)
+}
@@ -8,3 +8,12 @@ var ExGetDescriptions = getDescriptions
 
 // Export for the internal getRegulations function
 var ExGetRegulations = getRegulations
+
+// Export for the internal getFileLayers function
+var ExGetFileLayers = getFileLayers
+
+// Export for the internal getConfigs function
+var ExGetConfigs = getConfigs
+
+// Export for the internal getOntologies function
+var ExGetOntologies = getOntologies
@@ -1,11 +1,11 @@
 // Package to abstract file system accesses,
-// namely by handling lookup from both the global and local directories
+// namely by handling lookup across the configuration layers: the local directory, the search path and the global directory
 //
-// By default, the local path is `.devprivops/` and the global path is `/etc/devprivops/`.
-// Files in the local path override those in the global directory.
+// By default, the local path is `.devprivops/` and the global path is `/etc/devprivops/`, with an empty search path in between.
+// Files in a layer override those in every layer after it, so the local directory always wins and the global directory always loses.
 //
-// The unexported functions are independent of the local and global directories and are made to increase
-// testability. These are the ones that should be targeted in unit tests and thus are exported in `export_test.go`.
+// The unexported functions are independent of the configured layers and take their roots, from the highest to the lowest precedence,
+// to increase testability. These are the ones that should be targeted in unit tests and thus are exported in `export_test.go`.
 //
 // This package only supports UNIX paths
 package fs
@@ -15,160 +15,193 @@ import (
 	"fmt"
 	"io/fs"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/Joao-Felisberto/devprivops/util"
 )
 
 /*
 	lookup order:
-	1. /etc/appName
-	2. .appName/
+	1. .appName/
+	2. the search path, in order
+	3. /etc/appName
 */
 
 var (
-	LocalDir  = fmt.Sprintf("./.%s", util.AppName)   // The local directory
-	GlobalDir = fmt.Sprintf("/etc/%s", util.AppName) // The global directory
+	LocalDir   = fmt.Sprintf("./.%s", util.AppName)   // The local directory
+	GlobalDir  = fmt.Sprintf("/etc/%s", util.AppName) // The global directory
+	SearchPath = []string{}                           // The directories between the local and global directories, from the highest to the lowest precedence
 )
 
-// Returns the path of a file relative to the local or global root using the pre-determined paths to the local and global directories
+// The environment variable with the default search path, as directories separated by `:`
+var SearchPathVar = fmt.Sprintf("%s_PATH", strings.ToUpper(util.AppName))
+
+// Reads the search path from the environment
+//
+// returns: the directories in `SearchPathVar`, empty if it is not set
+func SearchPathFromEnv() []string {
+	return util.Filter(filepath.SplitList(os.Getenv(SearchPathVar)), func(dir string) bool { return dir != "" })
+}
+
+// A directory files are looked up in
+type Layer struct {
+	Name string // The name shown to users: `local`, `global` or the directory of a search path entry
+	Dir  string // The root of the layer
+}
+
+// Returns the layers files are looked up in, from the highest to the lowest precedence.
+// A directory that appears more than once is only kept at its highest precedence.
+//
+// returns: the local layer, the search path layers and the global layer
+func Layers() []Layer {
+	layers := []Layer{{Name: "local", Dir: LocalDir}}
+	for _, dir := range SearchPath {
+		layers = append(layers, Layer{Name: dir, Dir: dir})
+	}
+	layers = append(layers, Layer{Name: "global", Dir: GlobalDir})
+
+	seen := map[string]bool{}
+	return util.Filter(layers, func(l Layer) bool {
+		dir := filepath.Clean(l.Dir)
+		if seen[dir] {
+			return false
+		}
+		seen[dir] = true
+		return true
+	})
+}
+
+// Returns the roots of the layers
+//
+// returns: the root of each layer, from the highest to the lowest precedence
+func roots() []string {
+	return util.Map(Layers(), func(l Layer) string { return l.Dir })
+}
+
+// Returns the path of a file relative to the root of the layer with the highest precedence that has it, using the configured layers
 //
-// `relativePath`: the path relative to either root
+// `relativePath`: the path relative to any root
 //
 // returns: the path to the provided file relative to the root it is in, or an error if reading any of the directories fails.
 func GetFile(relativePath string) (string, error) {
-	return getFile(
-		relativePath,
-		LocalDir,
-		GlobalDir,
-	)
+	return getFile(relativePath, roots()...)
 }
 
-// Returns the path of a file relative to the local or global root using the provided paths to the local and global directories
-//
-// `localRoot`: the root of the local directory
+// Returns the path of a file relative to the root of the layer with the highest precedence that has it
 //
-// `globalRoot`: the root of the global directory
+// `relativePath` the path relative to any root
 //
-// `relativePath` the path relative to either root
+// `roots`: the roots of the layers, from the highest to the lowest precedence
 //
 // returns: the path to the provided file relative to the root it is in, or an error if reading any of the directories fails.
-func getFile(relativePath string, localRoot string, globalRoot string) (string, error) {
-	localPath := fmt.Sprintf("%s/%s", localRoot, relativePath)
-	if _, err := os.Stat(localPath); errors.Is(err, os.ErrNotExist) {
-		defaultPath := fmt.Sprintf("%s/%s", globalRoot, relativePath)
-		if _, err := os.Stat(defaultPath); errors.Is(err, os.ErrNotExist) {
+func getFile(relativePath string, roots ...string) (string, error) {
+	err := fmt.Errorf("'%s' not found: %w", relativePath, os.ErrNotExist)
+	for _, root := range roots {
+		path := fmt.Sprintf("%s/%s", root, relativePath)
+		if _, err = os.Stat(path); err == nil {
+			return path, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
 			return "", err
 		}
-		return defaultPath, nil
 	}
-	return localPath, nil
+	return "", err
 }
 
-// Returns the paths of the system descriptions relative to their respective root using the default paths to the local and global directories
+// Returns the layers that have a file using the configured layers
 //
-// `relativePath` the path relative to either root
+// `relativePath`: the path relative to the root of each layer
 //
-// returns: the relative paths of the system descriptions, or an error if reading any of the directories fails.
-func GetDescriptions(descriptionRoot string) ([]string, error) {
-	return getDescriptions(
-		descriptionRoot,
-		LocalDir,
-		GlobalDir,
-	)
+// returns: the layers with the file, from the highest to the lowest precedence
+func GetFileLayers(relativePath string) []Layer {
+	return getFileLayers(relativePath, Layers())
 }
 
-// Returns the paths of the system descriptions relative to their respective root using the provided paths to the local and global directories
-//
-// `localRoot`: the root of the local directory
+// Returns the layers that have a file
 //
-// `globalRoot`: the root of the global directory
+// `relativePath`: the path relative to the root of each layer
 //
-// `relativePath` the path relative to either root
+// `layers`: the layers to look in, from the highest to the lowest precedence
 //
-// returns: the relative paths of the system descriptions, or an error if reading any of the directories fails.
-func getDescriptions(descriptionRoot string, localRoot string, globalRoot string) ([]string, error) {
-	localPath := fmt.Sprintf("%s/%s/", localRoot, descriptionRoot)
-	globalPath := fmt.Sprintf("%s/%s/", globalRoot, descriptionRoot)
-
-	files := []string{}
-
-	entries, err := os.ReadDir(localPath)
-	if err != nil && !errors.Is(err, os.ErrNotExist) {
-		return nil, fmt.Errorf("error reading local directory: %s", err)
-	}
-
-	for _, e := range entries {
-		files = append(files, fmt.Sprintf("%s/%s", descriptionRoot, e.Name()))
-	}
-
-	entries, err = os.ReadDir(globalPath)
-	if err != nil {
-		return files, nil
-	}
+// returns: the layers with the file, in the same order
+func getFileLayers(relativePath string, layers []Layer) []Layer {
+	return util.Filter(layers, func(l Layer) bool {
+		_, err := os.Stat(fmt.Sprintf("%s/%s", l.Dir, relativePath))
+		return err == nil
+	})
+}
 
-	for _, e := range entries {
-		files = append(files, fmt.Sprintf("%s/%s", descriptionRoot, e.Name()))
+// Lists the entries of a directory across layers, each name only once
+//
+// `relativePath`: the directory relative to each root
+//
+// `roots`: the roots of the layers, from the highest to the lowest precedence
+//
+// `keep`: which entries to list
+//
+// returns: the names of the entries, those of higher precedence layers first, or an error if reading any of the directories fails for a reason other than it not existing
+func listLayered(relativePath string, roots []string, keep func(fs.DirEntry) bool) ([]string, error) {
+	names := []string{}
+	seen := map[string]bool{}
+	for _, root := range roots {
+		entries, err := os.ReadDir(fmt.Sprintf("%s/%s/", root, relativePath))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading directory '%s': %s", root, err)
+		}
+		for _, e := range entries {
+			if keep(e) && !seen[e.Name()] {
+				seen[e.Name()] = true
+				names = append(names, e.Name())
+			}
+		}
 	}
-
-	return files, nil
+	return names, nil
 }
 
-// Returns the directory names of the system regulation directories under `regulations/` using the default paths to the local and global directories
+// Returns the paths of the system descriptions relative to their respective root using the configured layers
 //
-// returns: the directory names of the system regulation directories, or an error if reading any of the directories fails.
-func GetRegulations() ([]string, error) {
-	return getRegulations(
-		LocalDir,
-		GlobalDir,
-	)
+// `relativePath` the path relative to any root
+//
+// returns: the relative paths of the system descriptions, or an error if reading any of the directories fails.
+func GetDescriptions(descriptionRoot string) ([]string, error) {
+	return getDescriptions(descriptionRoot, roots()...)
 }
 
-// Returns the directory names of the system regulation directories under `regulations/` using the default paths to the local and global directories
+// Returns the paths of the system descriptions relative to their respective root.
+// A description in several layers is listed once, as it is read from the layer with the highest precedence.
 //
-// `localRoot`: the root of the local directory
+// `descriptionRoot`: the path of the descriptions relative to any root
 //
-// `globalRoot`: the root of the global directory
+// `roots`: the roots of the layers, from the highest to the lowest precedence
 //
-// returns: the directory names of the system regulation directories, or an error if reading any of the directories fails.
-func getRegulations(localRoot string, globalRoot string) ([]string, error) {
-	localPath := fmt.Sprintf("%s/regulations/", localRoot)
-	defaultPath := fmt.Sprintf("%s/regulations/", globalRoot)
-
-	files := []string{}
-
-	localRegulations, err := getDirsInDir(localPath)
-	if err != nil && !errors.Is(err, os.ErrNotExist) {
-		return nil, err
-	}
-
-	defaultRegulations, err := getDirsInDir(defaultPath)
+// returns: the relative paths of the system descriptions, or an error if reading any of the directories fails.
+func getDescriptions(descriptionRoot string, roots ...string) ([]string, error) {
+	names, err := listLayered(descriptionRoot, roots, func(fs.DirEntry) bool { return true })
 	if err != nil {
-		files = append(files, localRegulations...)
-
-		return files, nil
+		return nil, err
 	}
+	return util.Map(names, func(name string) string { return fmt.Sprintf("%s/%s", descriptionRoot, name) }), nil
+}
 
-	files = append(files, localRegulations...)
-	files = append(files, defaultRegulations...)
-
-	return files, nil
+// Returns the directory names of the system regulation directories under `regulations/` using the configured layers
+//
+// returns: the directory names of the system regulation directories, or an error if reading any of the directories fails.
+func GetRegulations() ([]string, error) {
+	return getRegulations(roots()...)
 }
 
-// Find all top level directories inside a directory
+// Returns the directory names of the system regulation directories under `regulations/`.
+// A regulation in several layers is a single regulation, whose files are layered.
 //
-// `path`: The parent directory of which we want to know the subdirectories
+// `roots`: the roots of the layers, from the highest to the lowest precedence
 //
-// returns: The list of subdirectories, or an error if reading any of the directories fails.
-func getDirsInDir(path string) ([]string, error) {
-	entries, err := os.ReadDir(path)
-	if err != nil {
-		return nil, err
-	}
-
-	return util.Map(
-		util.Filter(entries, func(de fs.DirEntry) bool { return de.IsDir() }),
-		func(de fs.DirEntry) string { return de.Name() },
-	), nil
+// returns: the directory names of the system regulation directories, or an error if reading any of the directories fails.
+func getRegulations(roots ...string) ([]string, error) {
+	return listLayered("regulations", roots, func(de fs.DirEntry) bool { return de.IsDir() })
 }
 
 // Find the relative directories of each configuration file.
@@ -176,43 +209,61 @@ func getDirsInDir(path string) ([]string, error) {
 //
 // returns: The list of configuration files, or an error if reading any of the directories fails.
 func GetConfigs() ([]string, error) {
-	return getConfigs(
-		LocalDir,
-		GlobalDir,
-	)
+	return getConfigs(roots()...)
 }
 
 // Find the relative directories of each configuration file.
-// The returned directories contain the root
-//
-// `localRoot`: the root of the local directory
+// The returned directories contain the root, and a configuration in several layers is listed once.
+// Subdirectories are not listed, as they hold files that configurations include.
 //
-// `globalRoot`: the root of the global directory
+// `roots`: the roots of the layers, from the highest to the lowest precedence
 //
 // returns: The list of configuration files, or an error if reading any of the directories fails.
-func getConfigs(localRoot string, globalRoot string) ([]string, error) {
-	localPath := fmt.Sprintf("%s/config/", localRoot)
-	globalPath := fmt.Sprintf("%s/config/", globalRoot)
+func getConfigs(roots ...string) ([]string, error) {
+	names, err := listLayered("config", roots, func(e fs.DirEntry) bool { return !e.IsDir() })
+	if err != nil {
+		return nil, err
+	}
+	return util.Map(names, func(name string) string { return fmt.Sprintf("config/%s", name) }), nil
+}
 
-	files := []string{}
+// Returns the paths of the ontologies under `ontologies/` using the configured layers
+//
+// returns: the relative paths of the ontologies, or an error if reading any of the directories fails.
+func GetOntologies() ([]string, error) {
+	return getOntologies(roots()...)
+}
 
-	entries, err := os.ReadDir(localPath)
-	if err != nil && !errors.Is(err, os.ErrNotExist) {
-		return nil, fmt.Errorf("error reading local directory: %s", err)
-	}
+// Returns the paths of the Turtle ontologies under `ontologies/`.
+// An ontology in several layers is listed once, as it is read from the layer with the highest precedence.
+//
+// `roots`: the roots of the layers, from the highest to the lowest precedence
+//
+// returns: the relative paths of the ontologies, or an error if reading any of the directories fails.
+func getOntologies(roots ...string) ([]string, error) {
+	return listTurtle("ontologies", roots)
+}
 
-	for _, e := range entries {
-		files = append(files, fmt.Sprintf("config/%s", e.Name()))
-	}
+// Returns the paths of the SHACL shapes of a regulation under `regulations/<regulation>/shapes/` using the configured layers
+//
+// `regulation`: the regulation name
+//
+// returns: the relative paths of the shapes files, or an error if reading any of the directories fails.
+func GetShapes(regulation string) ([]string, error) {
+	return listTurtle(fmt.Sprintf("regulations/%s/shapes", regulation), roots())
+}
 
-	entries, err = os.ReadDir(globalPath)
+// Lists the Turtle files of a directory across layers, each name only once
+//
+// `relativePath`: the directory relative to each root
+//
+// `roots`: the roots of the layers, from the highest to the lowest precedence
+//
+// returns: the paths of the files relative to any root, or an error if reading any of the directories fails.
+func listTurtle(relativePath string, roots []string) ([]string, error) {
+	names, err := listLayered(relativePath, roots, func(e fs.DirEntry) bool { return !e.IsDir() && strings.HasSuffix(e.Name(), ".ttl") })
 	if err != nil {
-		return files, nil
-	}
-
-	for _, e := range entries {
-		files = append(files, fmt.Sprintf("config/%s", e.Name()))
+		return nil, err
 	}
-
-	return files, nil
+	return util.Map(names, func(name string) string { return fmt.Sprintf("%s/%s", relativePath, name) }), nil
 }
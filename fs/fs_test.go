@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
@@ -289,3 +290,135 @@ func TestGetRegulations(t *testing.T) {
 		t.Errorf("Results did not match expectations: expected %s, got %s", expectedGlobal, descs)
 	}
 }
+
+// Tests whether regulations in both directories are listed once
+func TestGetRegulationsOverlap(t *testing.T) {
+	local := t.TempDir()
+	global := t.TempDir()
+	for _, dir := range []string{local + "/regulations/gdpr", global + "/regulations/gdpr", global + "/regulations/asvs"} {
+		if err := os.MkdirAll(dir, 0766); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	regs, err := fs.ExGetRegulations(local, global)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"gdpr", "asvs"}
+	if !reflect.DeepEqual(regs, expected) {
+		t.Errorf("Results did not match expectations: expected %s, got %s", expected, regs)
+	}
+}
+
+// Tests for the getFileLayers function
+func TestGetFileLayers(t *testing.T) {
+	layers := []fs.Layer{{Name: "local", Dir: t.TempDir()}, {Name: "global", Dir: t.TempDir()}}
+	for _, file := range []string{layers[0].Dir + "/uris.yml", layers[1].Dir + "/uris.yml", layers[1].Dir + "/only_global.yml"} {
+		if err := os.WriteFile(file, []byte{}, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if found := fs.ExGetFileLayers("uris.yml", layers); !reflect.DeepEqual(found, layers) {
+		t.Errorf("Both layers should have the file, got %v", found)
+	}
+	if found := fs.ExGetFileLayers("only_global.yml", layers); !reflect.DeepEqual(found, layers[1:]) {
+		t.Errorf("Only the global layer should have the file, got %v", found)
+	}
+	if found := fs.ExGetFileLayers("missing.yml", layers); len(found) != 0 {
+		t.Errorf("No layer should have the file, got %v", found)
+	}
+}
+
+// Tests whether files are resolved across more than two layers by precedence
+func TestGetFileSearchPath(t *testing.T) {
+	repo, team, org := t.TempDir(), t.TempDir(), t.TempDir()
+	for _, file := range []string{team + "/uris.yml", org + "/uris.yml", org + "/only_org.yml"} {
+		if err := os.WriteFile(file, []byte{}, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := fs.ExGetFile("uris.yml", repo, team, org)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f != team+"/uris.yml" {
+		t.Errorf("The team layer should take precedence over the organisation one, got %s", f)
+	}
+	f, err = fs.ExGetFile("only_org.yml", repo, team, org)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f != org+"/only_org.yml" {
+		t.Errorf("Files only in the lowest layer should be found, got %s", f)
+	}
+	if _, err := fs.ExGetFile("missing.yml", repo, team, org); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Missing files should not exist, got %s", err)
+	}
+}
+
+// Tests whether descriptions and configurations in several layers are listed once
+func TestListingDeduplication(t *testing.T) {
+	repo, team, org := t.TempDir(), t.TempDir(), t.TempDir()
+	files := []string{
+		repo + "/descriptions/main.dfd.yml",
+		team + "/descriptions/main.dfd.yml",
+		org + "/descriptions/types.dfd.yml",
+		team + "/config/prod.yml",
+		org + "/config/prod.yml",
+		org + "/config/variants/eu.yml",
+		repo + "/ontologies/dpia.ttl",
+		org + "/ontologies/dpia.ttl",
+		org + "/ontologies/README.md",
+	}
+	for _, file := range files {
+		if err := os.MkdirAll(filepath.Dir(file), 0766); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte{}, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	descs, err := fs.ExGetDescriptions(descriptionRoot, repo, team, org)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"descriptions/main.dfd.yml", "descriptions/types.dfd.yml"}
+	if !reflect.DeepEqual(descs, expected) {
+		t.Errorf("Results did not match expectations: expected %s, got %s", expected, descs)
+	}
+
+	configs, err := fs.ExGetConfigs(repo, team, org)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(configs, []string{"config/prod.yml"}) {
+		t.Errorf("Results did not match expectations: expected [config/prod.yml], got %s", configs)
+	}
+
+	ontologies, err := fs.ExGetOntologies(repo, team, org)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ontologies, []string{"ontologies/dpia.ttl"}) {
+		t.Errorf("Results did not match expectations: expected [ontologies/dpia.ttl], got %s", ontologies)
+	}
+}
+
+// Tests the order and de-duplication of the configured layers
+func TestLayers(t *testing.T) {
+	local, global, searchPath := fs.LocalDir, fs.GlobalDir, fs.SearchPath
+	defer func() { fs.LocalDir, fs.GlobalDir, fs.SearchPath = local, global, searchPath }()
+
+	fs.LocalDir, fs.GlobalDir = "repo", "/etc/org"
+	fs.SearchPath = []string{"/srv/team", "repo/", "/srv/unit", "/etc/org"}
+
+	names := util.Map(fs.Layers(), func(l fs.Layer) string { return l.Name })
+	expected := []string{"local", "/srv/team", "/srv/unit", "/etc/org"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("Layers should be %v, got %v", expected, names)
+	}
+}
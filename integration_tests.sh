@@ -1,7 +1,26 @@
 #!/bin/bash
 set -e
+
+# With --update, the outputs are written to test_files/expected_outputs instead of compared to them
+UPDATE=0
+if [ "$1" = "--update" ]; then
+    UPDATE=1
+    shift
+fi
 PKGARGS="$*"
 
+# Compares the output of a command to the next expected output, ignoring the timestamps of pipeline logs
+check() {
+    expected="test_files/expected_outputs/out_$cnt.txt"
+    if [ $UPDATE = 1 ]; then
+        "$@" > "$expected" 2>&1 || true
+    else
+        diff <(sed 's/time=[^ ]* //g' "$expected") <("$@" 2>&1 | sed 's/time=[^ ]* //g')
+    fi
+    echo "================== TEST DONE!"
+    cnt=$((cnt + 1))
+}
+
 rm -rf covdatafiles
 mkdir covdatafiles
 export GOCOVERDIR=covdatafiles
@@ -35,39 +54,13 @@ cmp res.json schema/schemas/requirement-schema.json
 rm res.json
 
 cnt=0
-diff "test_files/expected_outputs/out_$cnt.txt" <(./devprivops analyse user pass 127.0.0.1 3030 tmp --report-endpoint http://localhost:8000 2>&1)
-echo "================== TEST DONE!"
-cnt=$((cnt + 1))
-diff "test_files/expected_outputs/out_$cnt.txt" <(./devprivops test user pass 127.0.0.1 3030 tmp 2>&1)
-echo "================== TEST DONE!"
-cnt=$((cnt + 1))
-diff "test_files/expected_outputs/out_$cnt.txt" <(./devprivops analyse user pass 127.0.0.1 3030 tmp --local-dir test_files/test_1 2>&1)
-echo "================== TEST DONE!"
-cnt=$((cnt + 1))
-diff "test_files/expected_outputs/out_$cnt.txt" <(./devprivops analyse user pass 127.0.0.1 3030 tmp --local-dir test_files/test_2 2>&1)
-echo "================== TEST DONE!"
-cnt=$((cnt + 1))
-diff "test_files/expected_outputs/out_$cnt.txt" <(./devprivops analyse user pass 127.0.0.1 3030 tmp --local-dir test_files/test_3 2>&1)
-echo "================== TEST DONE!"
-cnt=$((cnt + 1))
-diff "test_files/expected_outputs/out_$cnt.txt" <(./devprivops analyse user pass 127.0.0.1 3030 tmp --local-dir test_files/test_4 2>&1)
-echo "================== TEST DONE!"
-cnt=$((cnt + 1))
-diff "test_files/expected_outputs/out_$cnt.txt" <(./devprivops analyse user pass 127.0.0.1 3030 tmp --local-dir test_files/test_5 2>&1)
-echo "================== TEST DONE!"
-cnt=$((cnt + 1))
-diff "test_files/expected_outputs/out_$cnt.txt" <(./devprivops analyse user pass 127.0.0.1 3030 tmp --local-dir test_files/test_6 2>&1)
-echo "================== TEST DONE!"
-cnt=$((cnt + 1))
-diff "test_files/expected_outputs/out_$cnt.txt" <(./devprivops analyse user pass 127.0.0.1 3030 tmp --local-dir test_files/test_7 2>&1)
-echo "================== TEST DONE!"
-cnt=$((cnt + 1))
-diff "test_files/expected_outputs/out_$cnt.txt" <(./devprivops test user pass 127.0.0.1 3030 tmp --local-dir test_files/test_7 2>&1)
-echo "================== TEST DONE!"
-cnt=$((cnt + 1))
-# diff <(grep -vE "$timestamp_regex" "test_files/expected_outputs/out_$cnt.txt") <(./devprivops test user pass 127.0.0.1 3030 tmp --pipeline --local-dir test_files/test_7 2>&1 | grep -vE "$timestamp_regex")
-diff <(sed 's/time=[^ ]* //g' "test_files/expected_outputs/out_$cnt.txt") <(./devprivops test user pass 127.0.0.1 3030 tmp --pipeline --local-dir test_files/test_7 2>&1 | sed 's/time=[^ ]* //g')
-echo "================== TEST DONE!"
+check ./devprivops analyse user pass 127.0.0.1 3030 tmp --report-endpoint http://localhost:8000
+check ./devprivops test user pass 127.0.0.1 3030 tmp
+for t in 1 2 3 4 5 6 7; do
+    check ./devprivops analyse user pass 127.0.0.1 3030 tmp --local-dir test_files/test_$t
+done
+check ./devprivops test user pass 127.0.0.1 3030 tmp --local-dir test_files/test_7
+check ./devprivops test user pass 127.0.0.1 3030 tmp --pipeline --local-dir test_files/test_7
 
 # Close the server
 kill $SERVER_PID
@@ -9,14 +9,22 @@ import (
 	"os"
 
 	"github.com/Joao-Felisberto/devprivops/cmd"
+	"github.com/Joao-Felisberto/devprivops/database"
 	"github.com/Joao-Felisberto/devprivops/fs"
 	"github.com/Joao-Felisberto/devprivops/schema"
 	"github.com/Joao-Felisberto/devprivops/util"
 	"github.com/spf13/cobra"
 )
 
-var verbose = false   // Whether the log should log more information or not
-var writeYaml = false // Whether the report file should be writen in yaml
+var verbose = false       // Whether the log should log more information or not
+var writeYaml = false     // Whether the report file should be writen in yaml
+var diagramFormat = "dot" // The format in which to render diagrams
+var overlayReport = ""    // The report whose results to overlay on rendered diagrams
+var outputFile = ""       // The file to write generated output to
+var interactive = false   // Whether to ask the user for input
+var useTemplates = false  // Whether to copy the templates from the global directory
+var force = false         // Whether to overwrite existing files
+var globalInstall = false // Whether to install regulations to the global directory
 
 // Builds the command and delegates execution to the appropriate function from the cmd package
 func main() {
@@ -85,13 +93,214 @@ func main() {
 		},
 	}
 
+	var attackTreeCmd = &cobra.Command{
+		Use:   "attack-tree",
+		Short: "Utilities to work with attack/harm trees",
+		RunE: func(cmd_ *cobra.Command, args []string) error {
+			return fmt.Errorf("please specify a subcommand. Use '%s attack-tree --help' for usage details", util.AppName)
+		},
+	}
+
+	var attackTreeRenderCmd = &cobra.Command{
+		Use:   "render <file>",
+		Short: "Renders an attack/harm tree as a diagram",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd_ *cobra.Command, args []string) error {
+			logLevel := slog.LevelInfo
+			if verbose {
+				logLevel = slog.LevelDebug
+			}
+			util.SetupLogger(logLevel)
+			return cmd.RenderAttackTree(cmd_, args, diagramFormat, overlayReport)
+		},
+	}
+
+	var dfdCmd = &cobra.Command{
+		Use:   "dfd",
+		Short: "Utilities to work with data flow diagram descriptions",
+		RunE: func(cmd_ *cobra.Command, args []string) error {
+			return fmt.Errorf("please specify a subcommand. Use '%s dfd --help' for usage details", util.AppName)
+		},
+	}
+
+	var dfdRenderCmd = &cobra.Command{
+		Use:   "render <file>",
+		Short: "Renders a data flow diagram description as a diagram",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd_ *cobra.Command, args []string) error {
+			logLevel := slog.LevelInfo
+			if verbose {
+				logLevel = slog.LevelDebug
+			}
+			util.SetupLogger(logLevel)
+			return cmd.RenderDFD(cmd_, args, diagramFormat, overlayReport)
+		},
+	}
+
+	var shellCmd = &cobra.Command{
+		Use:   "shell <username> <password> <database ip> <database port> <dataset>",
+		Short: "Loads the project and opens an interactive SPARQL shell on it",
+		Args:  cobra.ExactArgs(5),
+		RunE: func(cmd_ *cobra.Command, args []string) error {
+			logLevel := slog.LevelInfo
+			if verbose {
+				logLevel = slog.LevelDebug
+			}
+			util.SetupLogger(logLevel)
+			return cmd.Shell(cmd_, args)
+		},
+	}
+
+	var graphCmd = &cobra.Command{
+		Use:   "graph",
+		Short: "Utilities to work with the knowledge graph the analysis runs on",
+		RunE: func(cmd_ *cobra.Command, args []string) error {
+			return fmt.Errorf("please specify a subcommand. Use '%s graph --help' for usage details", util.AppName)
+		},
+	}
+
+	var graphExportCmd = &cobra.Command{
+		Use:   "export [<username> <password> <database ip> <database port> <dataset>]",
+		Short: "Writes out the knowledge graph, built in the triple store if one is given and in memory otherwise",
+		Args: func(cmd_ *cobra.Command, args []string) error {
+			if len(args) != 0 && len(args) != 5 {
+				return fmt.Errorf("expected no arguments or the 5 database arguments, got %d", len(args))
+			}
+			return nil
+		},
+		RunE: func(cmd_ *cobra.Command, args []string) error {
+			logLevel := slog.LevelInfo
+			if verbose {
+				logLevel = slog.LevelDebug
+			}
+			util.SetupLogger(logLevel)
+			return cmd.GraphExport(cmd_, args)
+		},
+	}
+
+	var importCmd = &cobra.Command{
+		Use:   "import <threat-dragon|tmt> <file>",
+		Short: "Converts a threat model from another tool into a data flow diagram description",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd_ *cobra.Command, args []string) error {
+			logLevel := slog.LevelInfo
+			if verbose {
+				logLevel = slog.LevelDebug
+			}
+			util.SetupLogger(logLevel)
+			return cmd.Import(cmd_, args, outputFile, force)
+		},
+	}
+
+	var initCmd = &cobra.Command{
+		Use:   "init [project name]",
+		Short: "Creates a minimal local configuration directory",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd_ *cobra.Command, args []string) error {
+			logLevel := slog.LevelInfo
+			if verbose {
+				logLevel = slog.LevelDebug
+			}
+			util.SetupLogger(logLevel)
+			return cmd.Init(cmd_, args, interactive, useTemplates, force)
+		},
+	}
+
+	var regCmd = &cobra.Command{
+		Use:   "reg",
+		Short: "Manages regulation packages",
+	}
+
+	var regInstallCmd = &cobra.Command{
+		Use:   "install [source...]",
+		Short: "Installs regulation packages from tarballs, directories or 'git+<url>#<ref>', or the ones in the lockfile if no source is given",
+		RunE: func(cmd_ *cobra.Command, args []string) error {
+			logLevel := slog.LevelInfo
+			if verbose {
+				logLevel = slog.LevelDebug
+			}
+			util.SetupLogger(logLevel)
+			return cmd.RegInstall(cmd_, args, globalInstall, force)
+		},
+	}
+
+	var regListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "Lists the installed regulations and whether they match the lockfile",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd_ *cobra.Command, args []string) error {
+			logLevel := slog.LevelInfo
+			if verbose {
+				logLevel = slog.LevelDebug
+			}
+			util.SetupLogger(logLevel)
+			return cmd.RegList(cmd_, args)
+		},
+	}
+
+	var regUpdateCmd = &cobra.Command{
+		Use:   "update [name...]",
+		Short: "Updates the given or all locked regulation packages from their sources",
+		RunE: func(cmd_ *cobra.Command, args []string) error {
+			logLevel := slog.LevelInfo
+			if verbose {
+				logLevel = slog.LevelDebug
+			}
+			util.SetupLogger(logLevel)
+			return cmd.RegUpdate(cmd_, args, globalInstall, force)
+		},
+	}
+
+	var regRemoveCmd = &cobra.Command{
+		Use:   "remove <name...>",
+		Short: "Removes installed regulation packages",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd_ *cobra.Command, args []string) error {
+			logLevel := slog.LevelInfo
+			if verbose {
+				logLevel = slog.LevelDebug
+			}
+			util.SetupLogger(logLevel)
+			return cmd.RegRemove(cmd_, args, globalInstall, force)
+		},
+	}
+
+	var explainConfigCmd = &cobra.Command{
+		Use:   "explain-config",
+		Short: "Shows which configuration layer every file and policy comes from",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd_ *cobra.Command, args []string) error {
+			logLevel := slog.LevelInfo
+			if verbose {
+				logLevel = slog.LevelDebug
+			}
+			util.SetupLogger(logLevel)
+			return cmd.ExplainConfig(cmd_, args)
+		},
+	}
+
+	var validateCmd = &cobra.Command{
+		Use:   "validate",
+		Short: "Checks every project file without contacting the triple store",
+		RunE: func(cmd_ *cobra.Command, args []string) error {
+			logLevel := slog.LevelInfo
+			if verbose {
+				logLevel = slog.LevelDebug
+			}
+			util.SetupLogger(logLevel)
+			return cmd.Validate(cmd_, args)
+		},
+	}
+
 	analyseCmd.Flags().StringVar(&util.ReportEndpoint, "report-endpoint", "", "Endpoint where to send the final report")
 
 	analyseCmd.Flags().BoolVar(&util.Pipeline, "pipeline", false, "whether to format the output for pipeline usage")
 	testCmd.Flags().BoolVar(&util.Pipeline, "pipeline", false, "whether to format the output for pipeline usage")
 
 	analyseCmd.Flags().StringVar(&fs.GlobalDir, "global-dir", fmt.Sprintf("/etc/%s", util.AppName), "The path to the global configurations")
+	analyseCmd.Flags().StringArrayVar(&fs.SearchPath, "config-dir", fs.SearchPathFromEnv(), fmt.Sprintf("A configuration directory between the local and global ones, repeatable, earlier ones take precedence (defaults to $%s)", fs.SearchPathVar))
 	testCmd.Flags().StringVar(&fs.GlobalDir, "global-dir", fmt.Sprintf("/etc/%s", util.AppName), "The path to the global configurations")
+	testCmd.Flags().StringArrayVar(&fs.SearchPath, "config-dir", fs.SearchPathFromEnv(), fmt.Sprintf("A configuration directory between the local and global ones, repeatable, earlier ones take precedence (defaults to $%s)", fs.SearchPathVar))
 
 	analyseCmd.Flags().StringVar(&fs.LocalDir, "local-dir", fmt.Sprintf("./.%s", util.AppName), "The path to the local configurations")
 	testCmd.Flags().StringVar(&fs.LocalDir, "local-dir", fmt.Sprintf("./.%s", util.AppName), "The path to the local configurations")
@@ -100,10 +309,99 @@ func main() {
 	testCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "whether to display debug messages")
 
 	analyseCmd.Flags().BoolVar(&writeYaml, "yaml-report", false, "whether to write the report in YAML")
+	analyseCmd.Flags().String("entailment", "", "Built-in entailment to materialise before the policies run: rdfs or owl-rl, none if not given")
+	testCmd.Flags().String("entailment", "", "Built-in entailment to materialise before the tests run: rdfs or owl-rl, none if not given")
+	analyseCmd.Flags().String("fail-on", "info", "The lowest severity that fails the analysis: info, low, medium, high or critical")
+	analyseCmd.Flags().StringSlice("config", []string{}, "The names of the configurations to analyse, e.g. 'prod' for 'config/prod.yml', all if not given")
+	analyseCmd.Flags().IntVar(&database.BatchSize, "batch-size", database.BatchSize, "How many triples each request sends when loading descriptions, all of them in one request if 0")
+	testCmd.Flags().IntVar(&database.BatchSize, "batch-size", database.BatchSize, "How many triples each request sends when loading descriptions, all of them in one request if 0")
+	analyseCmd.Flags().Bool("watch", false, "whether to keep analysing as the configuration directories change, without writing reports")
+
+	attackTreeRenderCmd.Flags().StringVar(&diagramFormat, "format", "dot", "The diagram format: dot, mermaid or plantuml")
+	attackTreeRenderCmd.Flags().StringVar(&overlayReport, "report", "", "A JSON report whose execution status to overlay on the nodes")
+	attackTreeRenderCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "whether to display debug messages")
+
+	dfdRenderCmd.Flags().StringVar(&diagramFormat, "format", "dot", "The diagram format: dot, mermaid or svg")
+	dfdRenderCmd.Flags().StringVar(&overlayReport, "report", "", "A JSON report whose violating entities to highlight")
+	dfdRenderCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "whether to display debug messages")
+
+	shellCmd.Flags().String("config", "", "The name of the configuration to apply, e.g. 'prod' for 'config/prod.yml', none if not given")
+	shellCmd.Flags().String("entailment", "", "Built-in entailment to materialise after the reasoner rules: rdfs or owl-rl, none if not given")
+	shellCmd.Flags().StringVar(&fs.GlobalDir, "global-dir", fmt.Sprintf("/etc/%s", util.AppName), "The path to the global configurations")
+	shellCmd.Flags().StringArrayVar(&fs.SearchPath, "config-dir", fs.SearchPathFromEnv(), fmt.Sprintf("A configuration directory between the local and global ones, repeatable, earlier ones take precedence (defaults to $%s)", fs.SearchPathVar))
+	shellCmd.Flags().StringVar(&fs.LocalDir, "local-dir", fmt.Sprintf("./.%s", util.AppName), "The path to the local configurations")
+	shellCmd.Flags().IntVar(&database.BatchSize, "batch-size", database.BatchSize, "How many triples each request sends when loading descriptions, all of them in one request if 0")
+	shellCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "whether to display debug messages")
+
+	graphExportCmd.Flags().String("format", "ttl", "The format of the graph: ttl, nt or jsonld")
+	graphExportCmd.Flags().String("config", "", "The name of the configuration to apply, e.g. 'prod' for 'config/prod.yml', none if not given")
+	graphExportCmd.Flags().String("entailment", "", "Built-in entailment to materialise after the reasoner rules: rdfs or owl-rl, none if not given")
+	graphExportCmd.Flags().Bool("diff-before-reasoner", false, "whether to export only the triples the reasoner inferred")
+	graphExportCmd.Flags().StringVarP(&outputFile, "output", "o", "", "The file to write the graph to, defaults to the standard output")
+	graphExportCmd.Flags().StringVar(&fs.GlobalDir, "global-dir", fmt.Sprintf("/etc/%s", util.AppName), "The path to the global configurations")
+	graphExportCmd.Flags().StringArrayVar(&fs.SearchPath, "config-dir", fs.SearchPathFromEnv(), fmt.Sprintf("A configuration directory between the local and global ones, repeatable, earlier ones take precedence (defaults to $%s)", fs.SearchPathVar))
+	graphExportCmd.Flags().StringVar(&fs.LocalDir, "local-dir", fmt.Sprintf("./.%s", util.AppName), "The path to the local configurations")
+	graphExportCmd.Flags().IntVar(&database.BatchSize, "batch-size", database.BatchSize, "How many triples each request sends when loading descriptions, all of them in one request if 0")
+	graphExportCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "whether to display debug messages")
+
+	importCmd.Flags().StringVarP(&outputFile, "output", "o", "", "The file to write the description to, defaults to the local descriptions directory")
+	importCmd.Flags().StringVar(&fs.GlobalDir, "global-dir", fmt.Sprintf("/etc/%s", util.AppName), "The path to the global configurations")
+	importCmd.Flags().StringArrayVar(&fs.SearchPath, "config-dir", fs.SearchPathFromEnv(), fmt.Sprintf("A configuration directory between the local and global ones, repeatable, earlier ones take precedence (defaults to $%s)", fs.SearchPathVar))
+	importCmd.Flags().StringVar(&fs.LocalDir, "local-dir", fmt.Sprintf("./.%s", util.AppName), "The path to the local configurations")
+	importCmd.Flags().BoolVar(&force, "force", false, "whether to overwrite an existing description")
+	importCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "whether to display debug messages")
+
+	initCmd.Flags().BoolVarP(&interactive, "interactive", "i", false, "whether to ask about the system's entities to write its first description")
+	initCmd.Flags().BoolVar(&useTemplates, "templates", false, "whether to copy the templates under 'templates/' in the global directory")
+	initCmd.Flags().BoolVar(&force, "force", false, "whether to overwrite existing files")
+	initCmd.Flags().StringVar(&fs.GlobalDir, "global-dir", fmt.Sprintf("/etc/%s", util.AppName), "The path to the global configurations")
+	initCmd.Flags().StringVar(&fs.LocalDir, "local-dir", fmt.Sprintf("./.%s", util.AppName), "The path to the local configurations")
+	initCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "whether to display debug messages")
+
+	regCmd.PersistentFlags().StringVar(&fs.GlobalDir, "global-dir", fmt.Sprintf("/etc/%s", util.AppName), "The path to the global configurations")
+	regCmd.PersistentFlags().StringArrayVar(&fs.SearchPath, "config-dir", fs.SearchPathFromEnv(), fmt.Sprintf("A configuration directory between the local and global ones, repeatable, earlier ones take precedence (defaults to $%s)", fs.SearchPathVar))
+	regCmd.PersistentFlags().StringVar(&fs.LocalDir, "local-dir", fmt.Sprintf("./.%s", util.AppName), "The path to the local configurations")
+	regCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "whether to display debug messages")
+	for _, c := range []*cobra.Command{regInstallCmd, regUpdateCmd, regRemoveCmd} {
+		c.Flags().BoolVar(&globalInstall, "global", false, "whether to use the global directory instead of the local one")
+		c.Flags().BoolVar(&force, "force", false, "whether to proceed despite unmet requirements or dependent packages")
+	}
+
+	validateCmd.Flags().StringVar(&fs.GlobalDir, "global-dir", fmt.Sprintf("/etc/%s", util.AppName), "The path to the global configurations")
+	validateCmd.Flags().StringArrayVar(&fs.SearchPath, "config-dir", fs.SearchPathFromEnv(), fmt.Sprintf("A configuration directory between the local and global ones, repeatable, earlier ones take precedence (defaults to $%s)", fs.SearchPathVar))
+	validateCmd.Flags().StringVar(&fs.LocalDir, "local-dir", fmt.Sprintf("./.%s", util.AppName), "The path to the local configurations")
+	validateCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "whether to display debug messages")
+
+	explainConfigCmd.Flags().StringVar(&fs.GlobalDir, "global-dir", fmt.Sprintf("/etc/%s", util.AppName), "The path to the global configurations")
+	explainConfigCmd.Flags().StringArrayVar(&fs.SearchPath, "config-dir", fs.SearchPathFromEnv(), fmt.Sprintf("A configuration directory between the local and global ones, repeatable, earlier ones take precedence (defaults to $%s)", fs.SearchPathVar))
+	explainConfigCmd.Flags().StringVar(&fs.LocalDir, "local-dir", fmt.Sprintf("./.%s", util.AppName), "The path to the local configurations")
+	explainConfigCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "whether to display debug messages")
+	for _, c := range []*cobra.Command{analyseCmd, explainConfigCmd} {
+		c.Flags().StringSlice("regulation", []string{}, "The regulations to run, overriding the ones in 'regulations.yml'")
+		c.Flags().StringSlice("skip-regulation", []string{}, "The regulations not to run")
+		c.Flags().StringSlice("policy", []string{}, "The titles or tags of the policies to run, overriding the ones in 'regulations.yml'")
+		c.Flags().StringSlice("skip-policy", []string{}, "The titles or tags of the policies not to run")
+	}
 
 	rootCmd.AddCommand(analyseCmd)
 	rootCmd.AddCommand(testCmd)
 	rootCmd.AddCommand(schemaCmd)
+	attackTreeCmd.AddCommand(attackTreeRenderCmd)
+	rootCmd.AddCommand(attackTreeCmd)
+	dfdCmd.AddCommand(dfdRenderCmd)
+	rootCmd.AddCommand(dfdCmd)
+	rootCmd.AddCommand(shellCmd)
+	graphCmd.AddCommand(graphExportCmd)
+	rootCmd.AddCommand(graphCmd)
+	rootCmd.AddCommand(importCmd)
+	rootCmd.AddCommand(initCmd)
+	rootCmd.AddCommand(validateCmd)
+	rootCmd.AddCommand(explainConfigCmd)
+	regCmd.AddCommand(regInstallCmd)
+	regCmd.AddCommand(regListCmd)
+	regCmd.AddCommand(regUpdateCmd)
+	regCmd.AddCommand(regRemoveCmd)
+	rootCmd.AddCommand(regCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		slog.Error(err.Error())
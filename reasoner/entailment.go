@@ -0,0 +1,54 @@
+package reasoner
+
+import (
+	_ "embed"
+	"fmt"
+)
+
+// A built-in rule set that materialises what the ontologies entail
+type Entailment string
+
+const (
+	NO_ENTAILMENT Entailment = "none"   // Only the rules in `reasoner/` run
+	RDFS          Entailment = "rdfs"   // Sub-classes, sub-properties, domains and ranges
+	OWL_RL        Entailment = "owl-rl" // RDFS and the OWL 2 RL rules for equality, property characteristics, equivalence and restrictions
+)
+
+// How many passes an entailment rule set gets to reach a fixpoint
+const ENTAILMENT_MAX_ITERATIONS = 50
+
+//go:embed entailment/rdfs.rq
+var rdfsRules string
+
+//go:embed entailment/owl-rl.rq
+var owlRLRules string
+
+// Parses an entailment name
+//
+// `name`: The name, or "" for no entailment
+//
+// returns: the entailment or an error if the name is not known
+func ParseEntailment(name string) (Entailment, error) {
+	switch e := Entailment(name); e {
+	case "":
+		return NO_ENTAILMENT, nil
+	case NO_ENTAILMENT, RDFS, OWL_RL:
+		return e, nil
+	default:
+		return NO_ENTAILMENT, fmt.Errorf("unknown entailment '%s', expected one of %s, %s or %s", name, NO_ENTAILMENT, RDFS, OWL_RL)
+	}
+}
+
+// The SPARQL updates of the rule set, to run together until they add no triples
+//
+// returns: the updates, none if there is no entailment
+func (e Entailment) Rules() []string {
+	switch e {
+	case RDFS:
+		return []string{rdfsRules}
+	case OWL_RL:
+		return []string{rdfsRules, owlRLRules}
+	default:
+		return []string{}
+	}
+}
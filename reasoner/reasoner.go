@@ -0,0 +1,110 @@
+// Package for the reasoner rules: their manifest, dependencies and execution order
+package reasoner
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Joao-Felisberto/devprivops/schema"
+	"github.com/Joao-Felisberto/devprivops/util"
+)
+
+// The manifest with the dependencies and repetition of the reasoner rules, relative to the local or global directory
+const MANIFEST_FILE = "reasoner/rules.yml"
+
+// How many times a repeated rule runs at most when its manifest entry does not say
+const DEFAULT_MAX_ITERATIONS = 20
+
+// A reasoner rule and how it is executed
+type Rule struct {
+	File          string   // The rule file, relative to the local or global directory, e.g. `reasoner/closure.rq`
+	DependsOn     []string // The rules whose output it needs, which run before it
+	Repeat        bool     // Whether it runs until a pass adds no triples
+	MaxIterations int      // How many times it runs at most if repeated
+}
+
+// Reads the reasoner manifest
+//
+// `file`: The path to the manifest
+//
+// returns: the rules in the manifest or an error if the file cannot be read or does not abide by the schema
+func ReadManifest(file string) ([]Rule, error) {
+	raw, err := schema.ReadYAMLWithStringSchema(file, &schema.REASONER_SCHEMA)
+	if err != nil {
+		return nil, err
+	}
+	entries, _ := raw.([]interface{})
+
+	rules := []Rule{}
+	for _, e := range entries {
+		entry := e.(map[interface{}]interface{})
+		dependsOnRaw, _ := entry["depends on"].([]interface{})
+		repeat, _ := entry["repeat"].(bool)
+		maxIterations, ok := entry["max iterations"].(int)
+		if !ok {
+			maxIterations = DEFAULT_MAX_ITERATIONS
+		}
+		rules = append(rules, Rule{
+			File:          entry["file"].(string),
+			DependsOn:     util.Map(dependsOnRaw, func(raw interface{}) string { return raw.(string) }),
+			Repeat:        repeat,
+			MaxIterations: maxIterations,
+		})
+	}
+	return rules, nil
+}
+
+// Orders the rules so every rule runs after its dependencies, otherwise keeping the order of the rule files.
+// Rule files the manifest does not mention run once, without dependencies.
+//
+// `files`: The rule files, relative to the local or global directory, in listing order
+//
+// `manifest`: The rules in the manifest
+//
+// returns: the rules in execution order, or an error if the manifest mentions a rule that does not exist, twice, or dependencies are cyclic
+func Order(files []string, manifest []Rule) ([]Rule, error) {
+	exists := map[string]bool{}
+	for _, f := range files {
+		exists[f] = true
+	}
+	byFile := map[string]Rule{}
+	for _, r := range manifest {
+		if !exists[r.File] {
+			return nil, fmt.Errorf("rule '%s' in '%s' does not exist", r.File, MANIFEST_FILE)
+		}
+		if _, repeated := byFile[r.File]; repeated {
+			return nil, fmt.Errorf("rule '%s' appears more than once in '%s'", r.File, MANIFEST_FILE)
+		}
+		for _, d := range r.DependsOn {
+			if !exists[d] {
+				return nil, fmt.Errorf("rule '%s' depends on '%s', which does not exist", r.File, d)
+			}
+		}
+		byFile[r.File] = r
+	}
+
+	pending := util.Map(files, func(f string) Rule {
+		if r, ok := byFile[f]; ok {
+			return r
+		}
+		return Rule{File: f, DependsOn: []string{}, MaxIterations: 1}
+	})
+	done := map[string]bool{}
+	ordered := []Rule{}
+	for len(pending) != 0 {
+		next := -1
+		for i, r := range pending {
+			if !util.Any(r.DependsOn, func(d string) bool { return !done[d] }) {
+				next = i
+				break
+			}
+		}
+		if next == -1 {
+			return nil, fmt.Errorf("cyclic dependencies between rules %s", strings.Join(util.Map(pending, func(r Rule) string { return r.File }), ", "))
+		}
+		ordered = append(ordered, pending[next])
+		done[pending[next].File] = true
+		pending = append(pending[:next], pending[next+1:]...)
+	}
+	return ordered, nil
+}
@@ -0,0 +1,120 @@
+// Tests for the reasoner package
+package reasoner_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Joao-Felisberto/devprivops/reasoner"
+	"github.com/Joao-Felisberto/devprivops/sparql"
+	"github.com/Joao-Felisberto/devprivops/util"
+)
+
+// Tests reading the manifest and its defaults
+func TestReadManifest(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "rules.yml")
+	manifest := `- file: reasoner/closure.rq
+  depends on: [reasoner/base.rq]
+  repeat: true
+- file: reasoner/flows.rq
+  max iterations: 5
+`
+	if err := os.WriteFile(file, []byte(manifest), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := reasoner.ReadManifest(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []reasoner.Rule{
+		{File: "reasoner/closure.rq", DependsOn: []string{"reasoner/base.rq"}, Repeat: true, MaxIterations: reasoner.DEFAULT_MAX_ITERATIONS},
+		{File: "reasoner/flows.rq", DependsOn: []string{}, Repeat: false, MaxIterations: 5},
+	}
+	if !reflect.DeepEqual(rules, expected) {
+		t.Errorf("Rules should be %v, got %v", expected, rules)
+	}
+
+	if err := os.WriteFile(file, []byte("- file: reasoner/a.rq\n  repeats: true\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reasoner.ReadManifest(file); err == nil {
+		t.Errorf("A manifest with unknown keys should not abide by the schema")
+	}
+}
+
+// Tests that rules run after their dependencies and otherwise in listing order
+func TestOrder(t *testing.T) {
+	files := []string{"reasoner/a.rq", "reasoner/b.rq", "reasoner/c.rq", "reasoner/d.rq"}
+	manifest := []reasoner.Rule{
+		{File: "reasoner/a.rq", DependsOn: []string{"reasoner/c.rq"}, Repeat: true, MaxIterations: 3},
+		{File: "reasoner/c.rq", DependsOn: []string{"reasoner/d.rq"}},
+	}
+
+	rules, err := reasoner.Order(files, manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := util.Map(rules, func(r reasoner.Rule) string { return r.File })
+	expected := []string{"reasoner/b.rq", "reasoner/d.rq", "reasoner/c.rq", "reasoner/a.rq"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("Order should be %v, got %v", expected, order)
+	}
+	if !rules[3].Repeat || rules[3].MaxIterations != 3 {
+		t.Errorf("The manifest entry should be kept, got %v", rules[3])
+	}
+	if rules[0].Repeat || rules[0].MaxIterations != 1 {
+		t.Errorf("Rules not in the manifest should run once, got %v", rules[0])
+	}
+}
+
+// Tests the errors of invalid manifests
+func TestOrderErrors(t *testing.T) {
+	files := []string{"reasoner/a.rq", "reasoner/b.rq"}
+	cases := map[string][]reasoner.Rule{
+		"cyclic dependencies": {
+			{File: "reasoner/a.rq", DependsOn: []string{"reasoner/b.rq"}},
+			{File: "reasoner/b.rq", DependsOn: []string{"reasoner/a.rq"}},
+		},
+		"does not exist": {
+			{File: "reasoner/a.rq", DependsOn: []string{"reasoner/z.rq"}},
+		},
+		"more than once": {
+			{File: "reasoner/a.rq"},
+			{File: "reasoner/a.rq"},
+		},
+	}
+	for expected, manifest := range cases {
+		if _, err := reasoner.Order(files, manifest); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Ordering should fail with '%s', got %v", expected, err)
+		}
+	}
+}
+
+// Tests parsing entailment names and that the built-in rule sets are valid SPARQL
+func TestEntailment(t *testing.T) {
+	for name, expected := range map[string]reasoner.Entailment{"": reasoner.NO_ENTAILMENT, "rdfs": reasoner.RDFS, "owl-rl": reasoner.OWL_RL} {
+		e, err := reasoner.ParseEntailment(name)
+		if err != nil || e != expected {
+			t.Errorf("'%s' should parse as %s, got %s, %v", name, expected, e, err)
+		}
+	}
+	if _, err := reasoner.ParseEntailment("owl-full"); err == nil {
+		t.Errorf("Unknown entailments should not parse")
+	}
+
+	if n := len(reasoner.NO_ENTAILMENT.Rules()); n != 0 {
+		t.Errorf("No entailment should have no rules, got %d", n)
+	}
+	if n := len(reasoner.OWL_RL.Rules()); n != 2 {
+		t.Errorf("OWL 2 RL should include the RDFS rules, got %d rule sets", n)
+	}
+	for _, rules := range reasoner.OWL_RL.Rules() {
+		if _, err := sparql.Parse(rules); err != nil {
+			t.Errorf("The built-in rules should be valid SPARQL: %s", err)
+		}
+	}
+}
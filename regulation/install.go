@@ -0,0 +1,232 @@
+package regulation
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// The prefix of sources that are cloned with git, e.g. `git+https://example.com/regulations.git#v1.0.0`
+const GIT_PREFIX = "git+"
+
+// Obtains a package from its source: a `.tar.gz`, `.tgz` or `.tar` file, a directory such as a git checkout, or a git repository to clone.
+// The package is the source itself or its only top level directory, whichever has a manifest.
+//
+// `source`: The source
+//
+// returns: the package directory, a function to remove any temporary files, and an error if the package could not be obtained
+func Fetch(source string) (string, func(), error) {
+	noop := func() {}
+
+	if strings.HasPrefix(source, GIT_PREFIX) {
+		tmp, err := os.MkdirTemp("", "devprivops-reg-")
+		if err != nil {
+			return "", noop, err
+		}
+		cleanup := func() { os.RemoveAll(tmp) }
+		url, ref, _ := strings.Cut(strings.TrimPrefix(source, GIT_PREFIX), "#")
+		args := []string{"clone", "--depth", "1"}
+		if ref != "" {
+			args = append(args, "--branch", ref)
+		}
+		git := exec.Command("git", append(args, url, tmp)...)
+		var stderr bytes.Buffer
+		git.Stderr = &stderr
+		if err := git.Run(); err != nil {
+			cleanup()
+			return "", noop, fmt.Errorf("could not clone '%s', is 'git' installed? %s %s", url, err, stderr.String())
+		}
+		dir, err := findPackageRoot(tmp)
+		if err != nil {
+			cleanup()
+			return "", noop, err
+		}
+		return dir, cleanup, nil
+	}
+
+	info, err := os.Stat(source)
+	if err != nil {
+		return "", noop, fmt.Errorf("could not read package source '%s': %s", source, err)
+	}
+	if info.IsDir() {
+		dir, err := findPackageRoot(source)
+		return dir, noop, err
+	}
+
+	tmp, err := os.MkdirTemp("", "devprivops-reg-")
+	if err != nil {
+		return "", noop, err
+	}
+	cleanup := func() { os.RemoveAll(tmp) }
+	if err := extractTarball(source, tmp); err != nil {
+		cleanup()
+		return "", noop, err
+	}
+	dir, err := findPackageRoot(tmp)
+	if err != nil {
+		cleanup()
+		return "", noop, err
+	}
+	return dir, cleanup, nil
+}
+
+// Finds the directory with the manifest: the given one or its only subdirectory
+//
+// `dir`: The directory where the package was fetched to
+//
+// returns: the package directory or an error if there is no manifest
+func findPackageRoot(dir string) (string, error) {
+	if _, err := os.Stat(filepath.Join(dir, MANIFEST_FILE)); err == nil {
+		return dir, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	subdirs := []string{}
+	for _, e := range entries {
+		if e.IsDir() && e.Name() != ".git" {
+			subdirs = append(subdirs, e.Name())
+		}
+	}
+	if len(subdirs) == 1 {
+		if _, err := os.Stat(filepath.Join(dir, subdirs[0], MANIFEST_FILE)); err == nil {
+			return filepath.Join(dir, subdirs[0]), nil
+		}
+	}
+	return "", fmt.Errorf("no '%s' found in '%s', is it a regulation package?", MANIFEST_FILE, dir)
+}
+
+// Extracts a tarball, compressed with gzip or not, refusing entries that would be written outside the destination
+//
+// `file`: The tarball
+//
+// `dest`: The directory to extract to
+//
+// returns: an error if the tarball could not be read or extracted
+func extractTarball(file string, dest string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("could not open '%s': %s", file, err)
+	}
+	defer f.Close()
+
+	var reader io.Reader = f
+	if strings.HasSuffix(file, ".gz") || strings.HasSuffix(file, ".tgz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("could not decompress '%s': %s", file, err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	tr := tar.NewReader(reader)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("could not read '%s': %s", file, err)
+		}
+
+		name := filepath.Clean(header.Name)
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("'%s' has an entry outside the package: '%s'", file, header.Name)
+		}
+		path := filepath.Join(dest, name)
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(out, tr)
+			out.Close()
+			if err != nil {
+				return fmt.Errorf("could not extract '%s': %s", header.Name, err)
+			}
+		}
+	}
+}
+
+// Installs a package by copying it to `<regulationsDir>/<name>`, replacing any previous version.
+// The files are copied next to the target first, so installing a package from its installed directory keeps it intact
+//
+// `pkgDir`: The package directory, as returned by `Fetch`
+//
+// `regulationsDir`: The `regulations` directory to install to
+//
+// returns: the manifest of the package and the integrity hash of the installed files, or an error if the package could not be installed
+func Install(pkgDir string, regulationsDir string) (*Manifest, string, error) {
+	manifest, err := ReadManifest(pkgDir)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := os.MkdirAll(regulationsDir, 0755); err != nil {
+		return nil, "", fmt.Errorf("could not create '%s': %s", regulationsDir, err)
+	}
+	tmp, err := os.MkdirTemp(regulationsDir, "."+manifest.Name+"-")
+	if err != nil {
+		return nil, "", fmt.Errorf("could not install '%s': %s", manifest.Name, err)
+	}
+	defer os.RemoveAll(tmp)
+
+	err = filepath.WalkDir(pkgDir, func(path string, d iofs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(pkgDir, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" || d.Name() == filepath.Base(tmp) {
+				return filepath.SkipDir
+			}
+			return os.MkdirAll(filepath.Join(tmp, rel), 0755)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(tmp, rel), data, 0644)
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("could not install '%s': %s", manifest.Name, err)
+	}
+	if err := os.Chmod(tmp, 0755); err != nil {
+		return nil, "", fmt.Errorf("could not install '%s': %s", manifest.Name, err)
+	}
+
+	target := filepath.Join(regulationsDir, manifest.Name)
+	if err := os.RemoveAll(target); err != nil {
+		return nil, "", fmt.Errorf("could not remove the previous version of '%s': %s", manifest.Name, err)
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		return nil, "", fmt.Errorf("could not install '%s': %s", manifest.Name, err)
+	}
+
+	integrity, err := Hash(target)
+	if err != nil {
+		return nil, "", err
+	}
+	return manifest, integrity, nil
+}
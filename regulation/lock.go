@@ -0,0 +1,140 @@
+package regulation
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
+
+// The name of the project lockfile, in the local directory
+const LOCK_FILE = "regulations.lock.yml"
+
+// A package pinned by the lockfile
+type LockEntry struct {
+	Name      string `yaml:"name"`             // The package name
+	Version   string `yaml:"version"`          // The installed version
+	Source    string `yaml:"source"`           // Where the package was installed from: a tarball, a directory or a `git+<url>#<ref>`
+	Integrity string `yaml:"integrity"`        // The hash of the installed files, as computed by `Hash`
+	Global    bool   `yaml:"global,omitempty"` // Whether the package was installed to the global directory rather than the local one
+}
+
+// The directory a locked package was installed to, regardless of any other layer overriding it
+//
+// `localDir`: The local directory
+//
+// `globalDir`: The global directory
+//
+// returns: the package directory under the `regulations` directory of the layer it was installed to
+func (entry *LockEntry) Dir(localDir string, globalDir string) string {
+	root := localDir
+	if entry.Global {
+		root = globalDir
+	}
+	return filepath.Join(root, "regulations", entry.Name)
+}
+
+// The packages a project depends on, pinned to the exact installed contents
+type Lockfile struct {
+	Packages []LockEntry `yaml:"packages"` // The pinned packages, sorted by name
+}
+
+// Reads a lockfile
+//
+// `file`: The path to the lockfile
+//
+// returns: the lockfile, empty if the file does not exist, or an error if it cannot be read or parsed
+func ReadLock(file string) (*Lockfile, error) {
+	lock := &Lockfile{Packages: []LockEntry{}}
+	data, err := os.ReadFile(file)
+	if isNotExist(err) {
+		return lock, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read lockfile '%s': %s", file, err)
+	}
+	if err := yaml.Unmarshal(data, lock); err != nil {
+		return nil, fmt.Errorf("error reading lockfile '%s': %s", file, err)
+	}
+	return lock, nil
+}
+
+// Writes the lockfile, with the packages sorted by name
+//
+// `file`: The path to the lockfile
+//
+// returns: an error if the file could not be written
+func (lock *Lockfile) Write(file string) error {
+	sort.Slice(lock.Packages, func(i, j int) bool { return lock.Packages[i].Name < lock.Packages[j].Name })
+	data, err := yaml.Marshal(lock)
+	if err != nil {
+		return err
+	}
+	header := "# Generated by `devprivops reg`, commit it so every analysis uses the same regulations\n"
+	return os.WriteFile(file, append([]byte(header), data...), 0666)
+}
+
+// Finds the entry of a package
+//
+// `name`: The package name
+//
+// returns: the entry or nil if the package is not locked
+func (lock *Lockfile) Get(name string) *LockEntry {
+	for i := range lock.Packages {
+		if lock.Packages[i].Name == name {
+			return &lock.Packages[i]
+		}
+	}
+	return nil
+}
+
+// Adds or replaces the entry of a package
+//
+// `entry`: The new entry
+func (lock *Lockfile) Set(entry LockEntry) {
+	if existing := lock.Get(entry.Name); existing != nil {
+		*existing = entry
+		return
+	}
+	lock.Packages = append(lock.Packages, entry)
+}
+
+// Removes the entry of a package, if there is one
+//
+// `name`: The package name
+func (lock *Lockfile) Remove(name string) {
+	packages := []LockEntry{}
+	for _, p := range lock.Packages {
+		if p.Name != name {
+			packages = append(packages, p)
+		}
+	}
+	lock.Packages = packages
+}
+
+// Checks that every locked package is installed with exactly the locked contents.
+// Packages are read from the layer they were installed to, so overriding their files in a higher layer does not break the lock.
+//
+// `localDir`: The local directory
+//
+// `globalDir`: The global directory
+//
+// returns: an error for the first package that is missing or differs from the locked version
+func (lock *Lockfile) Verify(localDir string, globalDir string) error {
+	for _, entry := range lock.Packages {
+		dir := entry.Dir(localDir, globalDir)
+		if _, err := os.Stat(dir); err != nil {
+			return fmt.Errorf("regulation '%s' is locked but not installed in '%s'", entry.Name, dir)
+		}
+		integrity, err := Hash(dir)
+		if err != nil {
+			return err
+		}
+		if integrity != entry.Integrity {
+			return fmt.Errorf("regulation '%s' in '%s' does not match the lockfile, which pins version %s", entry.Name, dir, entry.Version)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+// Package for regulation packages: their manifests, versions, integrity hashes, installation and the project lockfile
+package regulation
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// The name of the manifest file at the root of every regulation package
+const MANIFEST_FILE = "manifest.yml"
+
+// A `uris.yml` entry a package's queries rely on
+type URIRequirement struct {
+	Abreviation string `yaml:"abreviation"` // The abreviation used in the queries, e.g. `dfd`
+	URI         string `yaml:"uri"`         // The complete URI
+}
+
+// The packages and schemas a package depends on
+type Dependencies struct {
+	Packages map[string]string `yaml:"packages"` // The names of the required packages and their version constraints, e.g. `>=1.0.0`
+	Schemas  []string          `yaml:"schemas"`  // The description schemas the queries assume, e.g. `dfd` for `schemas/dfd-schema.json`
+}
+
+// The manifest of a regulation package
+type Manifest struct {
+	Name         string           `yaml:"name"`         // The package name, also the directory it is installed to under `regulations/`
+	Version      string           `yaml:"version"`      // The package version, e.g. `1.2.0`
+	Description  string           `yaml:"description"`  // What the regulation is about
+	Dependencies Dependencies     `yaml:"dependencies"` // The packages and schemas it depends on
+	URIs         []URIRequirement `yaml:"uris"`         // The `uris.yml` entries it requires
+}
+
+// Reads the manifest of the package in a directory
+//
+// `dir`: The package directory
+//
+// returns: the manifest or an error if it does not exist, cannot be parsed or lacks a name or valid version
+func ReadManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, MANIFEST_FILE))
+	if err != nil {
+		return nil, fmt.Errorf("could not read the manifest of '%s': %s", dir, err)
+	}
+
+	var manifest Manifest
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("error reading manifest of '%s': %s", dir, err)
+	}
+	if manifest.Name == "" || strings.ContainsAny(manifest.Name, "/\\") || manifest.Name == "." || manifest.Name == ".." {
+		return nil, fmt.Errorf("the manifest of '%s' must have a valid 'name'", dir)
+	}
+	if _, err := ParseVersion(manifest.Version); err != nil {
+		return nil, fmt.Errorf("the manifest of '%s' has an invalid 'version': %s", dir, err)
+	}
+	for name, constraint := range manifest.Dependencies.Packages {
+		if _, err := Satisfies("0.0.0", constraint); err != nil {
+			return nil, fmt.Errorf("the manifest of '%s' has an invalid constraint for '%s': %s", dir, name, err)
+		}
+	}
+
+	return &manifest, nil
+}
+
+// A version as its numeric components, e.g. `1.2.0` as [1, 2, 0]
+type Version [3]int
+
+// Parses a version of up to three numeric components, with an optional `v` prefix
+//
+// `version`: The version, e.g. `1.2.0`, `v2` or `1.4`
+//
+// returns: the version or an error if it is not numeric
+func ParseVersion(version string) (Version, error) {
+	var v Version
+	parts := strings.Split(strings.TrimPrefix(strings.TrimSpace(version), "v"), ".")
+	if len(parts) > 3 || parts[0] == "" {
+		return v, fmt.Errorf("'%s' is not of the form 'major.minor.patch'", version)
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("'%s' is not of the form 'major.minor.patch'", version)
+		}
+		v[i] = n
+	}
+	return v, nil
+}
+
+// Compares two versions
+//
+// returns: -1, 0 or 1 if `v` is lower, equal or higher than `other`
+func (v Version) Compare(other Version) int {
+	for i := range v {
+		if v[i] != other[i] {
+			if v[i] < other[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// Whether a version satisfies a constraint.
+// Constraints are comma separated conditions that must all hold, each one a version with an optional operator:
+// `=`, `>`, `>=`, `<`, `<=`, `^` (same major version and at least the given one) or `~` (same minor version and at least the given one).
+// An empty constraint or `*` is satisfied by every version.
+//
+// `version`: The version
+//
+// `constraint`: The constraint, e.g. `>=1.0.0, <2`
+//
+// returns: whether the constraint holds or an error if the version or constraint are not valid
+func Satisfies(version string, constraint string) (bool, error) {
+	v, err := ParseVersion(version)
+	if err != nil {
+		return false, err
+	}
+
+	for _, condition := range strings.Split(constraint, ",") {
+		condition = strings.TrimSpace(condition)
+		if condition == "" || condition == "*" {
+			continue
+		}
+
+		opEnd := strings.IndexFunc(condition, func(r rune) bool { return !strings.ContainsRune("<>=^~", r) })
+		if opEnd == -1 {
+			return false, fmt.Errorf("'%s' has no version", condition)
+		}
+		op := condition[:opEnd]
+		target, err := ParseVersion(condition[len(op):])
+		if err != nil {
+			return false, err
+		}
+
+		cmp := v.Compare(target)
+		holds := false
+		switch op {
+		case "", "=":
+			holds = cmp == 0
+		case ">":
+			holds = cmp > 0
+		case ">=":
+			holds = cmp >= 0
+		case "<":
+			holds = cmp < 0
+		case "<=":
+			holds = cmp <= 0
+		case "^":
+			holds = cmp >= 0 && v[0] == target[0]
+		case "~":
+			holds = cmp >= 0 && v[0] == target[0] && v[1] == target[1]
+		default:
+			return false, fmt.Errorf("unknown operator '%s' in '%s'", op, condition)
+		}
+		if !holds {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Computes the integrity hash of a package directory.
+// The hash covers the path and contents of every file, so renaming, changing, adding or removing files changes it.
+//
+// `dir`: The package directory
+//
+// returns: the hash as `sha256-<hex>` or an error if any file cannot be read
+func Hash(dir string) (string, error) {
+	files := []string{}
+	err := filepath.WalkDir(dir, func(path string, d iofs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not list the files of '%s': %s", dir, err)
+	}
+	sort.Strings(files)
+
+	hash := sha256.New()
+	for _, file := range files {
+		f, err := os.Open(filepath.Join(dir, file))
+		if err != nil {
+			return "", fmt.Errorf("could not read '%s': %s", file, err)
+		}
+		fileHash := sha256.New()
+		_, err = io.Copy(fileHash, f)
+		f.Close()
+		if err != nil {
+			return "", fmt.Errorf("could not read '%s': %s", file, err)
+		}
+		fmt.Fprintf(hash, "%s\x00%x\n", file, fileHash.Sum(nil))
+	}
+
+	return "sha256-" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Finds what a package needs that the project does not have
+//
+// `manifest`: The manifest of the package
+//
+// `installed`: The names of the installed packages and their versions
+//
+// `hasSchema`: Whether the project has a given description schema
+//
+// `uris`: The abreviations in the project's `uris.yml` and their URIs
+//
+// returns: a description of each unmet requirement
+func UnmetRequirements(manifest *Manifest, installed map[string]string, hasSchema func(string) bool, uris map[string]string) []string {
+	unmet := []string{}
+
+	names := []string{}
+	for name := range manifest.Dependencies.Packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		constraint := manifest.Dependencies.Packages[name]
+		version, ok := installed[name]
+		if !ok {
+			unmet = append(unmet, fmt.Sprintf("package '%s' %s is not installed", name, constraint))
+			continue
+		}
+		if ok, err := Satisfies(version, constraint); err != nil || !ok {
+			unmet = append(unmet, fmt.Sprintf("package '%s' is at version %s, but %s is required", name, version, constraint))
+		}
+	}
+
+	for _, s := range manifest.Dependencies.Schemas {
+		if !hasSchema(s) {
+			unmet = append(unmet, fmt.Sprintf("schema 'schemas/%s-schema.json' is missing", s))
+		}
+	}
+
+	for _, u := range manifest.URIs {
+		uri, ok := uris[u.Abreviation]
+		if !ok {
+			unmet = append(unmet, fmt.Sprintf("'uris.yml' has no entry for '%s' (%s)", u.Abreviation, u.URI))
+		} else if strings.TrimSuffix(uri, "/") != strings.TrimSuffix(u.URI, "/") {
+			unmet = append(unmet, fmt.Sprintf("'uris.yml' maps '%s' to '%s', but the package expects '%s'", u.Abreviation, uri, u.URI))
+		}
+	}
+
+	return unmet
+}
+
+// Whether an error is caused by a missing file or directory
+func isNotExist(err error) bool {
+	return errors.Is(err, os.ErrNotExist)
+}
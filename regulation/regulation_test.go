@@ -0,0 +1,284 @@
+// Tests for the regulation package
+package regulation_test
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Joao-Felisberto/devprivops/regulation"
+)
+
+// The manifest of the test package
+const manifest = `name: gdpr
+version: 1.2.0
+dependencies:
+  packages:
+    base: ^1.0
+  schemas:
+    - dfd
+uris:
+  - abreviation: dfd
+    uri: https://devprivops.com/dfd
+`
+
+// Writes a package tarball with a top level directory, as produced by `tar czf gdpr.tgz gdpr`
+func writeTarball(t *testing.T, file string, files map[string]string) {
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	defer gz.Close()
+	tw := tar.NewWriter(gz)
+	defer tw.Close()
+
+	for name, data := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: "gdpr/" + name, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Test for version constraints
+func TestSatisfies(t *testing.T) {
+	cases := []struct {
+		version    string
+		constraint string
+		expected   bool
+	}{
+		{"1.2.0", "", true},
+		{"1.2.0", "1.2", true},
+		{"1.2.0", "=1.2.1", false},
+		{"1.2.0", ">=1.0.0, <2", true},
+		{"2.0.0", ">=1.0.0, <2", false},
+		{"1.9.3", "^1.2", true},
+		{"2.0.0", "^1.2", false},
+		{"1.2.9", "~1.2.1", true},
+		{"1.3.0", "~1.2.1", false},
+		{"v1.0.1", ">1", true},
+	}
+	for _, c := range cases {
+		ok, err := regulation.Satisfies(c.version, c.constraint)
+		if err != nil {
+			t.Errorf("'%s' '%s' should be valid, got '%s'", c.version, c.constraint, err)
+		}
+		if ok != c.expected {
+			t.Errorf("'%s' satisfying '%s' should be %v", c.version, c.constraint, c.expected)
+		}
+	}
+
+	if _, err := regulation.Satisfies("1.0.0", "!1.0"); err == nil {
+		t.Errorf("Unknown operators should be an error")
+	}
+}
+
+// Test whether packages are extracted, installed with the hash of their contents, and changes are detected
+func TestFetchAndInstall(t *testing.T) {
+	dir := t.TempDir()
+	tarball := filepath.Join(dir, "gdpr.tgz")
+	writeTarball(t, tarball, map[string]string{
+		"manifest.yml":        manifest,
+		"policies.yml":        "[]\n",
+		"policies/consent.rq": "SELECT * WHERE { ?s ?p ?o }\n",
+	})
+
+	pkgDir, cleanup, err := regulation.Fetch(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	fetchedHash, err := regulation.Hash(pkgDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	regulations := filepath.Join(dir, "regulations")
+	m, installedHash, err := regulation.Install(pkgDir, regulations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Name != "gdpr" || m.Version != "1.2.0" || m.Dependencies.Packages["base"] != "^1.0" {
+		t.Errorf("Manifest not read correctly: '%#v'", m)
+	}
+	if installedHash != fetchedHash {
+		t.Errorf("The installed package should have the hash of the fetched one, '%s' != '%s'", installedHash, fetchedHash)
+	}
+	if _, err := os.Stat(filepath.Join(regulations, "gdpr", "policies", "consent.rq")); err != nil {
+		t.Errorf("Package files should be installed under its name: %s", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(regulations, "gdpr", "policies.yml"), []byte("[] # edited\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if changed, _ := regulation.Hash(filepath.Join(regulations, "gdpr")); changed == installedHash {
+		t.Errorf("Changing a file should change the hash")
+	}
+}
+
+// Test whether installing a package from its installed directory keeps its files
+func TestInstallInPlace(t *testing.T) {
+	regulations := filepath.Join(t.TempDir(), "regulations")
+	installed := filepath.Join(regulations, "gdpr")
+	if err := os.MkdirAll(filepath.Join(installed, "policies"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(installed, regulation.MANIFEST_FILE), []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(installed, "policies", "consent.rq"), []byte("SELECT * WHERE { ?s ?p ?o }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	before, err := regulation.Hash(installed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, after, err := regulation.Install(installed, regulations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after != before {
+		t.Errorf("Reinstalling a package in place should keep its files, '%s' != '%s'", after, before)
+	}
+	if _, err := os.Stat(filepath.Join(installed, "policies", "consent.rq")); err != nil {
+		t.Errorf("Package files should survive a reinstall in place: %s", err)
+	}
+	entries, err := os.ReadDir(regulations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Only the package should be left in the regulations directory, got %d entries", len(entries))
+	}
+}
+
+// Test whether tarballs with entries outside the package are refused
+func TestFetchTraversal(t *testing.T) {
+	dir := t.TempDir()
+	tarball := filepath.Join(dir, "evil.tgz")
+	writeTarball(t, tarball, map[string]string{"../../escaped": "x"})
+
+	if _, cleanup, err := regulation.Fetch(tarball); err == nil {
+		cleanup()
+		t.Errorf("Entries outside the package should be refused")
+	}
+}
+
+// Test for the requirements of a package
+func TestUnmetRequirements(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, regulation.MANIFEST_FILE), []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := regulation.ReadManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unmet := regulation.UnmetRequirements(m, map[string]string{"base": "2.0.0"}, func(string) bool { return false }, map[string]string{})
+	expected := []string{
+		"package 'base' is at version 2.0.0, but ^1.0 is required",
+		"schema 'schemas/dfd-schema.json' is missing",
+		"'uris.yml' has no entry for 'dfd' (https://devprivops.com/dfd)",
+	}
+	if !reflect.DeepEqual(unmet, expected) {
+		t.Errorf("Unmet requirements should be '%v', got '%v'", expected, unmet)
+	}
+
+	unmet = regulation.UnmetRequirements(m, map[string]string{"base": "1.4.0"}, func(string) bool { return true }, map[string]string{"dfd": "https://devprivops.com/dfd/"})
+	if len(unmet) != 0 {
+		t.Errorf("All requirements should be met, got '%v'", unmet)
+	}
+}
+
+// Test whether the lockfile keeps its entries across writes
+func TestLock(t *testing.T) {
+	file := filepath.Join(t.TempDir(), regulation.LOCK_FILE)
+
+	lock, err := regulation.ReadLock(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lock.Packages) != 0 {
+		t.Errorf("A missing lockfile should be empty")
+	}
+
+	lock.Set(regulation.LockEntry{Name: "gdpr", Version: "1.0.0", Source: "gdpr.tgz", Integrity: "sha256-a"})
+	lock.Set(regulation.LockEntry{Name: "asvs", Version: "1.0.0", Source: "asvs.tgz", Integrity: "sha256-b"})
+	lock.Set(regulation.LockEntry{Name: "gdpr", Version: "1.1.0", Source: "gdpr.tgz", Integrity: "sha256-c"})
+	if err := lock.Write(file); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := regulation.ReadLock(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []regulation.LockEntry{
+		{Name: "asvs", Version: "1.0.0", Source: "asvs.tgz", Integrity: "sha256-b"},
+		{Name: "gdpr", Version: "1.1.0", Source: "gdpr.tgz", Integrity: "sha256-c"},
+	}
+	if !reflect.DeepEqual(read.Packages, expected) {
+		t.Errorf("Lockfile should be '%v', got '%v'", expected, read.Packages)
+	}
+
+	read.Remove("asvs")
+	if read.Get("asvs") != nil || read.Get("gdpr") == nil {
+		t.Errorf("Only 'asvs' should be removed")
+	}
+}
+
+// Test whether locked packages are verified in the layer they were installed to, ignoring a local overlay
+func TestVerifyLockOverlay(t *testing.T) {
+	dir := t.TempDir()
+	localDir, globalDir := filepath.Join(dir, "local"), filepath.Join(dir, "global")
+	tarball := filepath.Join(dir, "gdpr.tgz")
+	writeTarball(t, tarball, map[string]string{
+		"manifest.yml":        manifest,
+		"policies.yml":        "[]\n",
+		"policies/consent.rq": "SELECT * WHERE { ?s ?p ?o }\n",
+	})
+
+	pkgDir, cleanup, err := regulation.Fetch(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	m, integrity, err := regulation.Install(pkgDir, filepath.Join(globalDir, "regulations"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock := &regulation.Lockfile{}
+	lock.Set(regulation.LockEntry{Name: m.Name, Version: m.Version, Source: tarball, Integrity: integrity, Global: true})
+
+	overlay := filepath.Join(localDir, "regulations", "gdpr", "policies")
+	if err := os.MkdirAll(overlay, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(overlay, "consent.rq"), []byte("SELECT * WHERE { ?s a ?o }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := lock.Verify(localDir, globalDir); err != nil {
+		t.Errorf("A local overlay should not break the lock of a global package: %s", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(globalDir, "regulations", "gdpr", "policies.yml"), []byte("[] # edited\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := lock.Verify(localDir, globalDir); err == nil {
+		t.Errorf("Changing the installed package should break the lock")
+	}
+
+	lock.Packages[0].Global = false
+	if err := lock.Verify(localDir, globalDir); err == nil {
+		t.Errorf("A package locked in the local directory should be read from it")
+	}
+}
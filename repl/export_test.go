@@ -0,0 +1,11 @@
+package repl
+
+import (
+	"bufio"
+	"io"
+)
+
+// Creates a reader that edits lines key by key without a terminal, for testing
+func ExEditingReader(in io.Reader, out io.Writer, complete Completer) *Reader {
+	return &Reader{in: bufio.NewReader(in), out: out, complete: complete, history: []string{}, editing: true}
+}
@@ -0,0 +1,244 @@
+// Package with a line editor for interactive prompts, with history and tab completion
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Returned by `ReadLine` when the line is cancelled with Ctrl-C
+var ErrInterrupt = errors.New("interrupted")
+
+// The characters that end the word being completed
+const WORD_SEPARATORS = " \t(){},;"
+
+// Gives every candidate for tab completion, the ones matching the word are picked by `Complete`
+type Completer func() []string
+
+// Reads lines from the user, editing them in place when the input is a terminal
+type Reader struct {
+	in       *bufio.Reader // Where the lines come from
+	out      io.Writer     // Where the prompt and the echo of the input go
+	complete Completer     // The candidates for tab completion
+	history  []string      // The lines read so far, oldest first
+	editing  bool          // Whether lines are read one key press at a time
+	terminal *os.File      // The terminal whose settings were changed, nil if the input is not a terminal
+	settings string        // The terminal settings to restore
+}
+
+// Creates a reader. If the input is a terminal, it is switched to reading single key presses without echoing them,
+// so that lines can be edited, until `Close` is called. Otherwise lines are read as they come.
+//
+// `in`: The input
+//
+// `out`: Where the prompt and the echo of the input go
+//
+// `complete`: The candidates for tab completion
+//
+// returns: the reader
+func NewReader(in *os.File, out io.Writer, complete Completer) *Reader {
+	r := &Reader{in: bufio.NewReader(in), out: out, complete: complete, history: []string{}}
+	if info, err := in.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return r
+	}
+	settings, err := stty(in, "-g")
+	if err != nil {
+		return r
+	}
+	if _, err := stty(in, "-icanon", "-echo", "-isig", "min", "1"); err != nil {
+		return r
+	}
+	r.terminal = in
+	r.settings = strings.TrimSpace(settings)
+	r.editing = true
+	return r
+}
+
+// Runs `stty` on a terminal
+//
+// `terminal`: The terminal
+//
+// `args`: The arguments to `stty`
+//
+// returns: what `stty` printed or an error if it failed
+func stty(terminal *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = terminal
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// Restores the terminal settings changed by `NewReader`
+//
+// returns: an error if the settings could not be restored
+func (r *Reader) Close() error {
+	if r.terminal == nil {
+		return nil
+	}
+	_, err := stty(r.terminal, r.settings)
+	r.terminal = nil
+	return err
+}
+
+// Reads a line
+//
+// `prompt`: What to show before the line
+//
+// returns: the line without its newline, `io.EOF` when the input ends or Ctrl-D is pressed on an empty line,
+// or `ErrInterrupt` when Ctrl-C is pressed
+func (r *Reader) ReadLine(prompt string) (string, error) {
+	if !r.editing {
+		line, err := r.in.ReadString('\n')
+		if err == io.EOF && line != "" {
+			err = nil
+		}
+		return strings.TrimRight(line, "\r\n"), err
+	}
+	return r.editLine(prompt)
+}
+
+// Reads a line one key press at a time, echoing it and handling editing keys:
+// backspace, Ctrl-U to clear the line, tab to complete, the up and down arrows to go through the history,
+// Ctrl-C to cancel the line and Ctrl-D to end the input
+//
+// `prompt`: What to show before the line
+//
+// returns: the line, `io.EOF` or `ErrInterrupt`, as `ReadLine`
+func (r *Reader) editLine(prompt string) (string, error) {
+	line := []rune{}
+	historyPos := len(r.history)
+	redraw := func() { fmt.Fprintf(r.out, "\r\033[K%s%s", prompt, string(line)) }
+	fmt.Fprint(r.out, prompt)
+
+	for {
+		key, _, err := r.in.ReadRune()
+		if err != nil {
+			if err == io.EOF && len(line) > 0 {
+				fmt.Fprintln(r.out)
+				return string(line), nil
+			}
+			return "", err
+		}
+
+		switch key {
+		case '\r', '\n':
+			fmt.Fprintln(r.out)
+			if len(line) > 0 {
+				r.history = append(r.history, string(line))
+			}
+			return string(line), nil
+		case 3: // Ctrl-C
+			fmt.Fprintln(r.out, "^C")
+			return "", ErrInterrupt
+		case 4: // Ctrl-D
+			if len(line) == 0 {
+				fmt.Fprintln(r.out)
+				return "", io.EOF
+			}
+		case 127, '\b':
+			if len(line) > 0 {
+				line = line[:len(line)-1]
+				fmt.Fprint(r.out, "\b \b")
+			}
+		case 21: // Ctrl-U
+			line = line[:0]
+			redraw()
+		case '\t':
+			completed, options := Complete(string(line), r.complete())
+			line = []rune(completed)
+			if len(options) > 0 {
+				fmt.Fprintf(r.out, "\n%s\n", strings.Join(options, "  "))
+			}
+			redraw()
+		case 27: // escape sequences, of which only the up and down arrows are used
+			arrow := r.readEscape()
+			if arrow == 'A' && historyPos > 0 {
+				historyPos--
+			} else if arrow == 'B' && historyPos < len(r.history) {
+				historyPos++
+			} else {
+				continue
+			}
+			line = []rune{}
+			if historyPos < len(r.history) {
+				line = []rune(r.history[historyPos])
+			}
+			redraw()
+		default:
+			if unicode.IsPrint(key) {
+				line = append(line, key)
+				fmt.Fprint(r.out, string(key))
+			}
+		}
+	}
+}
+
+// Consumes the rest of an escape sequence
+//
+// returns: the final character of a control sequence, e.g. `A` for the up arrow, or 0 if it is not one
+func (r *Reader) readEscape() rune {
+	if next, _, err := r.in.ReadRune(); err != nil || next != '[' {
+		return 0
+	}
+	for {
+		key, _, err := r.in.ReadRune()
+		if err != nil {
+			return 0
+		}
+		if key >= 0x40 && key <= 0x7e {
+			return key
+		}
+	}
+}
+
+// Completes the last word of a line, ignoring case
+//
+// `line`: The line
+//
+// `candidates`: Every word that can be completed
+//
+// returns: the line with the word completed, followed by a space if only one candidate matches,
+// or extended as far as all matching candidates agree, and the matching candidates if the word could not be extended
+func Complete(line string, candidates []string) (string, []string) {
+	start := strings.LastIndexAny(line, WORD_SEPARATORS) + 1
+	word := line[start:]
+	if word == "" {
+		return line, nil
+	}
+
+	seen := map[string]bool{}
+	matches := []string{}
+	for _, c := range candidates {
+		if !seen[c] && len(c) >= len(word) && strings.EqualFold(c[:len(word)], word) {
+			seen[c] = true
+			matches = append(matches, c)
+		}
+	}
+	sort.Strings(matches)
+
+	switch len(matches) {
+	case 0:
+		return line, nil
+	case 1:
+		return line[:start] + matches[0] + " ", nil
+	}
+	common := matches[0]
+	for _, m := range matches[1:] {
+		n := 0
+		for n < len(common) && n < len(m) && strings.EqualFold(common[n:n+1], m[n:n+1]) {
+			n++
+		}
+		common = common[:n]
+	}
+	if len(common) > len(word) {
+		return line[:start] + common, nil
+	}
+	return line, matches
+}
@@ -0,0 +1,64 @@
+// Tests for the repl package
+package repl_test
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Joao-Felisberto/devprivops/repl"
+)
+
+// Tests completing the last word of a line
+func TestComplete(t *testing.T) {
+	candidates := []string{"dfd:from", "dfd:to", "dfd:type", "SELECT", "dfd:from"}
+	cases := []struct {
+		line     string
+		expected string
+		options  []string
+	}{
+		{"SELECT * WHERE { ?s dfd:f", "SELECT * WHERE { ?s dfd:from ", nil},
+		{"sel", "SELECT ", nil},
+		{"?s df", "?s dfd:", nil},
+		{"?s dfd:t", "?s dfd:t", []string{"dfd:to", "dfd:type"}},
+		{"?s rdf:", "?s rdf:", nil},
+		{"?s ", "?s ", nil},
+	}
+	for _, c := range cases {
+		line, options := repl.Complete(c.line, candidates)
+		if line != c.expected || !reflect.DeepEqual(options, c.options) {
+			t.Errorf("Completing %q should give %q and %v, got %q and %v", c.line, c.expected, c.options, line, options)
+		}
+	}
+}
+
+// Tests editing lines key by key
+func TestEditLine(t *testing.T) {
+	complete := func() []string { return []string{"dfd:from"} }
+	input := "ab\x7fc\n" + // backspace
+		"junk\x15SELECT ?s { ?s dfd:f\t}\n" + // clear the line, then complete
+		"\x1b[A\x1b[A\n" + // the line before the last one
+		"\x1b[Dx\x03" + // unknown sequence, then cancel
+		"\x04"
+	var out bytes.Buffer
+	r := repl.ExEditingReader(strings.NewReader(input), &out, complete)
+
+	expected := []string{"ac", "SELECT ?s { ?s dfd:from }", "ac"}
+	for _, e := range expected {
+		line, err := r.ReadLine("> ")
+		if err != nil || line != e {
+			t.Errorf("Line should be %q, got %q, %v", e, line, err)
+		}
+	}
+	if _, err := r.ReadLine("> "); err != repl.ErrInterrupt {
+		t.Errorf("Ctrl-C should interrupt the line, got %v", err)
+	}
+	if _, err := r.ReadLine("> "); err != io.EOF {
+		t.Errorf("Ctrl-D on an empty line should end the input, got %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "> ab\b \bc\n") {
+		t.Errorf("Input should be echoed, got %q", out.String())
+	}
+}
@@ -0,0 +1,181 @@
+package schema
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// A problem found in a file, with the line it is at
+type Problem struct {
+	File    string // The file with the problem
+	Line    int    // The line of the problem, starting at 1, or 0 if unknown
+	Message string // The description of the problem
+	Warning bool   // Whether the problem does not prevent the file from being used
+}
+
+// Formats the problem as `file:line: message`, with warnings marked as such
+func (p Problem) String() string {
+	message := p.Message
+	if p.Warning {
+		message = "warning: " + message
+	}
+	if p.Line == 0 {
+		return p.File + ": " + message
+	}
+	return p.File + ":" + strconv.Itoa(p.Line) + ": " + message
+}
+
+// A line of a block style YAML document, reduced to what is needed to find paths in it
+type yamlLine struct {
+	number    int    // The line number, starting at 1
+	dashCol   int    // The column of the sequence item indicator, or -1 if the line does not start an item
+	keyCol    int    // The column of the mapping key, or -1 if the line has no key
+	key       string // The mapping key, if any
+	indentCol int    // The column of the first meaningful character
+}
+
+// Splits a YAML document into its meaningful lines, ignoring blank lines and comments
+func yamlLines(data []byte) []yamlLine {
+	lines := []yamlLine{}
+	for i, raw := range strings.Split(string(data), "\n") {
+		content := strings.TrimLeft(raw, " ")
+		if content == "" || content[0] == '#' || strings.HasPrefix(content, "---") {
+			continue
+		}
+		line := yamlLine{number: i + 1, dashCol: -1, keyCol: -1, indentCol: len(raw) - len(content)}
+		col := line.indentCol
+		for strings.HasPrefix(content, "- ") || content == "-" {
+			line.dashCol = col
+			trimmed := strings.TrimLeft(strings.TrimPrefix(content, "-"), " ")
+			col += len(content) - len(trimmed)
+			content = trimmed
+		}
+		if idx := strings.Index(content, ":"); idx > 0 && (idx == len(content)-1 || content[idx+1] == ' ') && content[0] != '"' && content[0] != '\'' {
+			line.keyCol = col
+			line.key = content[:idx]
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Finds the line of a field in a YAML document.
+// Only block style documents are supported, for flow style the closest enclosing field is returned.
+//
+// `data`: The YAML document
+//
+// `field`: The path of the field, with segments separated by `.`, as reported by the schema validation, e.g. `data flows.0.from`
+//
+// returns: the line of the field, of its closest enclosing field if it cannot be found, or 0 if nothing could be found
+func FindLine(data []byte, field string) int {
+	lines := yamlLines(data)
+	if field == "" || field == "(root)" {
+		if len(lines) == 0 {
+			return 0
+		}
+		return lines[0].number
+	}
+
+	found := 0
+	start, end := 0, len(lines)
+	for _, segment := range strings.Split(field, ".") {
+		if start >= end {
+			break
+		}
+		if index, err := strconv.Atoi(segment); err == nil {
+			// Sequence item: the items are the lines with the smallest dash column in the block
+			col := -1
+			for _, l := range lines[start:end] {
+				if l.dashCol >= 0 && (col == -1 || l.dashCol < col) {
+					col = l.dashCol
+				}
+			}
+			if col == -1 {
+				break
+			}
+			n, itemStart := -1, -1
+			for i := start; i < end; i++ {
+				if lines[i].dashCol == col && lines[i].indentCol == col {
+					n++
+					if n == index {
+						itemStart = i
+						break
+					}
+				}
+			}
+			if itemStart == -1 {
+				break
+			}
+			itemEnd := end
+			for i := itemStart + 1; i < end; i++ {
+				if lines[i].indentCol <= col {
+					itemEnd = i
+					break
+				}
+			}
+			found = lines[itemStart].number
+			// the item starts at the dash line, since its first key may be on it
+			start, end = itemStart, itemEnd
+			continue
+		}
+
+		// Mapping key: the keys of the block are at the smallest key column in it
+		col := -1
+		for _, l := range lines[start:end] {
+			if l.keyCol >= 0 && (col == -1 || l.keyCol < col) {
+				col = l.keyCol
+			}
+		}
+		keyLine := -1
+		for i := start; i < end; i++ {
+			if lines[i].keyCol == col && lines[i].key == segment {
+				keyLine = i
+				break
+			}
+		}
+		if keyLine == -1 {
+			break
+		}
+		valueEnd := end
+		for i := keyLine + 1; i < end; i++ {
+			// the value ends at a sibling or ancestor, sequences may be at the same column as their key
+			if lines[i].indentCol < col || (lines[i].indentCol == col && lines[i].dashCol != col) {
+				valueEnd = i
+				break
+			}
+		}
+		found = lines[keyLine].number
+		start, end = keyLine+1, valueEnd
+	}
+
+	return found
+}
+
+// Converts the errors of a schema validation into problems located in the validated file
+//
+// `file`: The validated file
+//
+// `data`: The contents of the validated file
+//
+// `res`: The validation results
+//
+// returns: one problem per validation error
+func ValidationProblems(file string, data []byte, res *gojsonschema.Result) []Problem {
+	problems := []Problem{}
+	for _, e := range res.Errors() {
+		field := e.Field()
+		// missing properties are reported on the object that should have them
+		if e.Type() == "required" || e.Type() == "additional_property_not_allowed" {
+			field = e.Context().String()
+			field = strings.TrimPrefix(strings.TrimPrefix(field, "(root)"), ".")
+		}
+		problems = append(problems, Problem{
+			File:    file,
+			Line:    FindLine(data, field),
+			Message: e.String(),
+		})
+	}
+	return problems
+}
@@ -17,9 +17,15 @@ import (
 //go:embed schemas/atk-tree-schema.json
 var ATK_TREE_SCHEMA string
 
+//go:embed schemas/dfd-schema.json
+var DFD_SCHEMA string
+
 //go:embed schemas/query-schema.json
 var QUERY_SCHEMA string
 
+//go:embed schemas/reasoner-schema.json
+var REASONER_SCHEMA string
+
 //go:embed schemas/report_data-schema.json
 var REPORT_DATA_SCHEMA string
 
@@ -196,7 +202,7 @@ func ReadYAMLWithStringSchema(yamlFile string, schema *string) (interface{}, err
 	}
 
 	if *schema != "" {
-		res, err := validateYAMLAgainstSchemaString(yamlFile, schema)
+		res, err := ValidateYAMLAgainstSchemaString(yamlFile, schema)
 		if err != nil {
 			return nil, fmt.Errorf("error validating schema: %s", err)
 		}
@@ -257,7 +263,7 @@ func ValidateYAMLAgainstSchemaFile(yamlFile string, schemaFile string) (*gojsons
 // `schemaFile`: The path to the json schema the yaml file should follow. If "", there is no schema validation
 //
 // returns: the schema validation results or an error if the file or schema could not be read or the schema could not be validated
-func validateYAMLAgainstSchemaString(yamlFile string, schemaString *string) (*gojsonschema.Result, error) {
+func ValidateYAMLAgainstSchemaString(yamlFile string, schemaString *string) (*gojsonschema.Result, error) {
 	// Load JSON schema
 	schemaLoader := gojsonschema.NewStringLoader(*schemaString)
 	schema, err := gojsonschema.NewSchema(schemaLoader)
@@ -291,6 +297,20 @@ func validateYAMLAgainstSchemaString(yamlFile string, schemaString *string) (*go
 	return result, nil
 }
 
+// The number of anonymous ids generated so far
+//
+// returns: the value of the internal counter
+func IDCounter() int {
+	return idCounter
+}
+
+// Sets the number of anonymous ids generated so far, so that a file read again gets the ids it got before
+//
+// `n`: The new value of the internal counter
+func SetIDCounter(n int) {
+	idCounter = n
+}
+
 // Generate a new anonymous id with the given uri base. The counter is global and not per-base.
 // Increments an internal global counter every time it runs.
 //
@@ -448,3 +448,92 @@ final: null
 		t.Errorf("Schema validation did not work for file '%s' with bad schema '%s'", fileName, badSchema)
 	}
 }
+
+// Tests that the query schema requires only a title of disabled queries and checks severities of every query
+func TestQuerySchema(t *testing.T) {
+	tests := []struct {
+		name  string
+		yaml  string
+		valid bool
+	}{
+		{"disabled with severity", `
+- title: Old check
+  disabled: true
+  severity: high
+`, true},
+		{"neither disabled nor severity", `
+- title: Check
+  description: A check
+  is consistency: false
+  file: policies/check.rq
+  mapping message: found
+  maximum violations: 0
+  clearence level: 1
+  groups: [dev]
+`, true},
+		{"enabled without fields", `
+- title: Check
+  severity: high
+`, false},
+		{"disabled with unknown severity", `
+- title: Old check
+  disabled: true
+  severity: urgent
+`, false},
+	}
+
+	fileName := ".test_query_schema/policies.yml"
+	defer util.DeleteFileAndParentPath(fileName)
+	for _, test := range tests {
+		if err := util.CreateFileWithData(fileName, test.yaml); err != nil {
+			t.Fatalf("Could not create file '%s': %s", fileName, err)
+		}
+
+		res, err := schema.ValidateYAMLAgainstSchemaString(fileName, &schema.QUERY_SCHEMA)
+		if err != nil {
+			t.Fatalf("%s: could not validate '%s': %s", test.name, fileName, err)
+		}
+		if res.Valid() != test.valid {
+			t.Errorf("%s: expected valid to be %t, got %t: %v", test.name, test.valid, res.Valid(), res.Errors())
+		}
+	}
+}
+
+// Test for the FindLine function
+func TestFindLine(t *testing.T) {
+	data := []byte(`# a comment
+data types: []
+data flows:
+  - id: :F1
+    from: :A
+    to: :B
+  - id: :F2
+    data:
+    - :X
+    - :Y
+    from: :B
+processes:
+- id: P
+  purposes: []
+`)
+
+	cases := map[string]int{
+		"(root)":               2,
+		"data types":           2,
+		"data flows":           3,
+		"data flows.0":         4,
+		"data flows.0.to":      6,
+		"data flows.1":         7,
+		"data flows.1.data":    8,
+		"data flows.1.data.1":  10,
+		"data flows.1.from":    11,
+		"processes.0.purposes": 14,
+		"data flows.1.missing": 7,
+		"data flows.5":         3,
+	}
+	for field, expected := range cases {
+		if line := schema.FindLine(data, field); line != expected {
+			t.Errorf("Line of '%s' should be %d, got %d", field, expected, line)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package sparql
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A problem found by the static checks
+type Issue struct {
+	Line    int    // The line of the problem, starting at 1
+	Message string // The description of the problem
+	Warning bool   // Whether the query still works as written, but likely not as intended
+}
+
+// The predicates that the loaded data can have, grouped by the namespaces they belong to
+type Vocabulary struct {
+	namespaces map[string]bool // The namespaces whose predicates are all known
+	predicates map[string]bool // The full IRIs of the known predicates
+}
+
+// Creates an empty vocabulary
+//
+// returns: the vocabulary
+func NewVocabulary() *Vocabulary {
+	return &Vocabulary{
+		namespaces: map[string]bool{},
+		predicates: map[string]bool{},
+	}
+}
+
+// Declares a namespace whose predicates are all known, so that any other predicate in it is reported
+//
+// `namespace`: The namespace IRI, e.g. `https://devprivops.com/dfd/`
+func (v *Vocabulary) AddNamespace(namespace string) {
+	v.namespaces[namespace] = true
+}
+
+// Declares a predicate the loaded data can have
+//
+// `iri`: The full IRI of the predicate
+func (v *Vocabulary) AddPredicate(iri string) {
+	v.predicates[iri] = true
+}
+
+// Whether the vocabulary covers the namespace of a predicate
+//
+// `iri`: The full IRI of the predicate
+func (v *Vocabulary) covers(iri string) bool {
+	for namespace := range v.namespaces {
+		if strings.HasPrefix(iri, namespace) {
+			return true
+		}
+	}
+	return false
+}
+
+// Runs the static checks on a parsed query: undeclared prefixes, projected or template variables that are never bound,
+// variables bound but never used and predicates outside the vocabulary.
+//
+// `query`: The parsed query
+//
+// `vocabulary`: The predicates the loaded data can have, or nil to skip the predicate check
+//
+// returns: the problems found, sorted by line
+func Check(query *Query, vocabulary *Vocabulary) []Issue {
+	issues := []Issue{}
+
+	reported := map[string]bool{}
+	for _, name := range query.PrefixedNames {
+		if _, ok := query.Prefixes[name.Prefix]; !ok && !reported[name.Prefix] {
+			reported[name.Prefix] = true
+			issues = append(issues, Issue{Line: name.Line, Message: fmt.Sprintf("prefix '%s:' is not declared", name.Prefix)})
+		}
+	}
+
+	for _, v := range query.Variables {
+		switch {
+		case v.Projected && !v.Bound:
+			issues = append(issues, Issue{Line: v.Line, Message: fmt.Sprintf("variable '?%s' is projected but never bound", v.Name)})
+		case v.Templated && !v.Bound:
+			issues = append(issues, Issue{Line: v.Line, Message: fmt.Sprintf("variable '?%s' is used in a template but never bound", v.Name), Warning: true})
+		case v.Bound && v.Mentions == 1 && !v.Projected && !query.SelectAll && !strings.HasPrefix(v.Name, "_"):
+			issues = append(issues, Issue{Line: v.Line, Message: fmt.Sprintf("variable '?%s' is bound but never used, consider '[]' instead", v.Name), Warning: true})
+		}
+	}
+
+	if vocabulary != nil {
+		for _, p := range query.Predicates {
+			if p.IRI != "" && vocabulary.covers(p.IRI) && !vocabulary.predicates[p.IRI] {
+				issues = append(issues, Issue{Line: p.Line, Message: fmt.Sprintf("predicate '%s' is not produced by any schema, description or rule", p.Text), Warning: true})
+			}
+		}
+	}
+
+	sort.Slice(issues, func(i, j int) bool {
+		if issues[i].Line != issues[j].Line {
+			return issues[i].Line < issues[j].Line
+		}
+		return issues[i].Message < issues[j].Message
+	})
+	return issues
+}
+
+// Parses a query and runs the static checks on it
+//
+// `text`: The query or update
+//
+// `vocabulary`: The predicates the loaded data can have, or nil to skip the predicate check
+//
+// returns: the syntax error, if any, or the problems found by the static checks
+func Lint(text string, vocabulary *Vocabulary) []Issue {
+	query, err := Parse(text)
+	if err != nil {
+		line := 0
+		if syntaxErr, ok := err.(*SyntaxError); ok {
+			line = syntaxErr.Line
+		}
+		return []Issue{{Line: line, Message: fmt.Sprintf("syntax error: %s", err)}}
+	}
+	return Check(query, vocabulary)
+}
@@ -0,0 +1,337 @@
+// Package with a SPARQL 1.1 query and update parser and static checks on the parsed queries
+package sparql
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The kinds of tokens in a SPARQL query
+type tokenKind int
+
+const (
+	tokEOF     tokenKind = iota // The end of the query
+	tokIRI                      // An IRI between angle brackets, e.g. `<https://example.com>`
+	tokPName                    // A prefixed name, e.g. `dfd:process` or `dfd:`
+	tokBNode                    // A blank node label, e.g. `_:b0`
+	tokVar                      // A variable, e.g. `?x` or `$x`
+	tokString                   // A string literal in any of the quote styles
+	tokNumber                   // An integer, decimal or double
+	tokLangTag                  // A language tag, e.g. `@en`
+	tokName                     // A keyword or function name, e.g. `SELECT`, `a` or `REGEX`
+	tokPunct                    // Punctuation and operators, e.g. `{`, `^^` or `&&`
+)
+
+// A token of a SPARQL query
+type token struct {
+	kind   tokenKind // The kind of token
+	text   string    // The token as written, for strings the unescaped contents
+	line   int       // The line the token starts at, starting at 1
+	column int       // The column the token starts at, starting at 1
+	offset int       // The byte offset the token starts at
+}
+
+// An error in the syntax of a query
+type SyntaxError struct {
+	Line    int    // The line of the error, starting at 1
+	Column  int    // The column of the error, starting at 1
+	Message string // The description of the error
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+}
+
+// Punctuation and operators, longest first so that `^^` is preferred over `^`
+var punctuation = []string{"^^", "&&", "||", "!=", "<=", ">=", "{", "}", "(", ")", "[", "]", ";", ",", ".", "*", "/", "|", "^", "?", "+", "-", "!", "=", "<", ">"}
+
+// Splits a query into tokens
+type lexer struct {
+	input  string // The query
+	pos    int    // The byte offset of the next character
+	line   int    // The current line
+	column int    // The current column
+}
+
+// Whether the character can start a prefix or name
+func isNameStart(r rune) bool {
+	return unicode.IsLetter(r)
+}
+
+// Whether the character can continue a prefix, local name or variable
+func isNameChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '.' || r == '·'
+}
+
+// Returns the character at the given offset from the current position, or 0 past the end
+func (l *lexer) peek(offset int) rune {
+	pos := l.pos
+	for i := 0; i < offset && pos < len(l.input); i++ {
+		_, size := utf8.DecodeRuneInString(l.input[pos:])
+		pos += size
+	}
+	if pos >= len(l.input) {
+		return 0
+	}
+	r, _ := utf8.DecodeRuneInString(l.input[pos:])
+	return r
+}
+
+// Consumes a character, keeping track of lines and columns
+func (l *lexer) advance() rune {
+	r, size := utf8.DecodeRuneInString(l.input[l.pos:])
+	l.pos += size
+	if r == '\n' {
+		l.line++
+		l.column = 1
+	} else {
+		l.column++
+	}
+	return r
+}
+
+// Builds a syntax error at the current position
+func (l *lexer) errorf(format string, args ...interface{}) *SyntaxError {
+	return &SyntaxError{Line: l.line, Column: l.column, Message: fmt.Sprintf(format, args...)}
+}
+
+// Skips whitespace and comments
+func (l *lexer) skipIgnored() {
+	for l.pos < len(l.input) {
+		r := l.peek(0)
+		if unicode.IsSpace(r) {
+			l.advance()
+		} else if r == '#' {
+			for l.pos < len(l.input) && l.peek(0) != '\n' {
+				l.advance()
+			}
+		} else {
+			return
+		}
+	}
+}
+
+// Consumes a run of name characters, not ending in `.`
+func (l *lexer) readName(allowColon bool) string {
+	var sb strings.Builder
+	for l.pos < len(l.input) {
+		r := l.peek(0)
+		if r == '\\' && l.peek(1) != 0 {
+			// escaped characters in local names, e.g. `dfd:a\-b`
+			l.advance()
+			sb.WriteRune(l.advance())
+			continue
+		}
+		if r == '%' && l.peek(1) != 0 && l.peek(2) != 0 {
+			sb.WriteRune(l.advance())
+			sb.WriteRune(l.advance())
+			sb.WriteRune(l.advance())
+			continue
+		}
+		if !isNameChar(r) && !(allowColon && r == ':') {
+			break
+		}
+		// a trailing `.` ends the triple
+		if r == '.' && !isNameChar(l.peek(1)) && !(allowColon && l.peek(1) == ':') {
+			break
+		}
+		sb.WriteRune(l.advance())
+	}
+	return sb.String()
+}
+
+// Reads an IRI between angle brackets, if the `<` at the current position starts one
+func (l *lexer) readIRI() (string, bool) {
+	end := l.pos + 1
+	for end < len(l.input) {
+		c := l.input[end]
+		if c == '>' {
+			iri := l.input[l.pos+1 : end]
+			for l.pos <= end {
+				l.advance()
+			}
+			return iri, true
+		}
+		if c <= ' ' || strings.ContainsRune("<\"{}|^`\\", rune(c)) {
+			return "", false
+		}
+		end++
+	}
+	return "", false
+}
+
+// Reads a string literal in any of the four quote styles
+func (l *lexer) readString() (string, error) {
+	quote := l.peek(0)
+	long := l.peek(1) == quote && l.peek(2) == quote
+	if long {
+		l.advance()
+		l.advance()
+	}
+	l.advance()
+
+	var sb strings.Builder
+	for {
+		if l.pos >= len(l.input) {
+			return "", l.errorf("unterminated string")
+		}
+		r := l.peek(0)
+		if r == '\\' {
+			l.advance()
+			escaped := l.advance()
+			switch escaped {
+			case 't':
+				sb.WriteRune('\t')
+			case 'n':
+				sb.WriteRune('\n')
+			case 'r':
+				sb.WriteRune('\r')
+			case 'b':
+				sb.WriteRune('\b')
+			case 'f':
+				sb.WriteRune('\f')
+			case 'u', 'U':
+				sb.WriteRune('\\')
+				sb.WriteRune(escaped)
+			case '"', '\'', '\\':
+				sb.WriteRune(escaped)
+			default:
+				return "", l.errorf("invalid escape sequence '\\%c' in string", escaped)
+			}
+			continue
+		}
+		if long {
+			if r == quote && l.peek(1) == quote && l.peek(2) == quote {
+				l.advance()
+				l.advance()
+				l.advance()
+				return sb.String(), nil
+			}
+		} else {
+			if r == quote {
+				l.advance()
+				return sb.String(), nil
+			}
+			if r == '\n' || r == '\r' {
+				return "", l.errorf("unterminated string")
+			}
+		}
+		sb.WriteRune(l.advance())
+	}
+}
+
+// Reads an integer, decimal or double
+func (l *lexer) readNumber() string {
+	start := l.pos
+	for unicode.IsDigit(l.peek(0)) {
+		l.advance()
+	}
+	if l.peek(0) == '.' && unicode.IsDigit(l.peek(1)) {
+		l.advance()
+		for unicode.IsDigit(l.peek(0)) {
+			l.advance()
+		}
+	}
+	if e := l.peek(0); e == 'e' || e == 'E' {
+		next := l.peek(1)
+		if unicode.IsDigit(next) || ((next == '+' || next == '-') && unicode.IsDigit(l.peek(2))) {
+			l.advance()
+			if next == '+' || next == '-' {
+				l.advance()
+			}
+			for unicode.IsDigit(l.peek(0)) {
+				l.advance()
+			}
+		}
+	}
+	return l.input[start:l.pos]
+}
+
+// Reads the next token
+func (l *lexer) next() (token, error) {
+	l.skipIgnored()
+	tok := token{line: l.line, column: l.column, offset: l.pos}
+	if l.pos >= len(l.input) {
+		tok.kind = tokEOF
+		return tok, nil
+	}
+
+	r := l.peek(0)
+	switch {
+	case r == '<':
+		if iri, ok := l.readIRI(); ok {
+			tok.kind, tok.text = tokIRI, iri
+			return tok, nil
+		}
+	case r == '"' || r == '\'':
+		str, err := l.readString()
+		if err != nil {
+			return tok, err
+		}
+		tok.kind, tok.text = tokString, str
+		return tok, nil
+	case (r == '?' || r == '$') && (isNameStart(l.peek(1)) || unicode.IsDigit(l.peek(1)) || l.peek(1) == '_'):
+		l.advance()
+		start := l.pos
+		for r := l.peek(0); unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'; r = l.peek(0) {
+			l.advance()
+		}
+		tok.kind, tok.text = tokVar, l.input[start:l.pos]
+		return tok, nil
+	case r == '@' && isNameStart(l.peek(1)):
+		l.advance()
+		tok.kind, tok.text = tokLangTag, l.readName(false)
+		return tok, nil
+	case r == '_' && l.peek(1) == ':':
+		l.advance()
+		l.advance()
+		tok.kind, tok.text = tokBNode, l.readName(false)
+		return tok, nil
+	case unicode.IsDigit(r) || (r == '.' && unicode.IsDigit(l.peek(1))):
+		tok.kind, tok.text = tokNumber, l.readNumber()
+		return tok, nil
+	case r == ':' || isNameStart(r):
+		name := l.readName(false)
+		if l.peek(0) == ':' {
+			l.advance()
+			tok.kind, tok.text = tokPName, name+":"+l.readName(true)
+			return tok, nil
+		}
+		tok.kind, tok.text = tokName, name
+		return tok, nil
+	}
+
+	for _, p := range punctuation {
+		if strings.HasPrefix(l.input[l.pos:], p) {
+			for range p {
+				l.advance()
+			}
+			tok.kind, tok.text = tokPunct, p
+			return tok, nil
+		}
+	}
+
+	return tok, l.errorf("unexpected character '%c'", r)
+}
+
+// Splits a query into tokens
+//
+// `input`: The query
+//
+// returns: the tokens, ending with an end of query token, or the first syntax error
+func tokenize(input string) ([]token, error) {
+	l := &lexer{input: input, line: 1, column: 1}
+	tokens := []token{}
+	for {
+		tok, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, tok)
+		if tok.kind == tokEOF {
+			return tokens, nil
+		}
+	}
+}
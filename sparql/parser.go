@@ -0,0 +1,1117 @@
+package sparql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The forms a SPARQL request can take
+type Form string
+
+const (
+	SELECT    Form = "SELECT"    // A query returning variable bindings
+	CONSTRUCT Form = "CONSTRUCT" // A query returning triples built from a template
+	DESCRIBE  Form = "DESCRIBE"  // A query returning a description of resources
+	ASK       Form = "ASK"       // A query returning whether a pattern matches
+	UPDATE    Form = "UPDATE"    // One or more update operations
+)
+
+// The use of a prefixed name, e.g. `dfd:process`
+type PrefixedName struct {
+	Prefix string // The prefix, e.g. `dfd`
+	Local  string // The local part, e.g. `process`
+	Line   int    // The line of the name
+}
+
+// A variable and how it is used in the query
+type Variable struct {
+	Name      string // The name, without the `?` or `$`
+	Line      int    // The line of its first appearance
+	Mentions  int    // The number of times it appears
+	Bound     bool   // Whether it is bound by a pattern, `BIND`, `VALUES` or `AS`
+	Projected bool   // Whether it is explicitly listed in the `SELECT` clause
+	Templated bool   // Whether it is used in a `CONSTRUCT`, `INSERT` or `DELETE` template
+}
+
+// A predicate matched by a triple pattern
+type Predicate struct {
+	IRI  string // The full IRI, or "" if it uses an undeclared prefix
+	Text string // The predicate as written
+	Line int    // The line of the predicate
+}
+
+// The result of parsing a query or update, with the information needed for static checks
+type Query struct {
+	Form          Form                 // The query form
+	Prefixes      map[string]string    // The declared prefixes and their IRIs
+	PrefixedNames []PrefixedName       // Every prefixed name used, in order
+	IRIs          []string             // Every IRI written between angle brackets, in order
+	Variables     map[string]*Variable // Every variable used
+	SelectAll     bool                 // Whether the outermost query projects all its variables, as in `SELECT *`
+	Predicates    []Predicate          // The predicates matched by triple patterns
+	Produced      []string             // The predicate IRIs written by `CONSTRUCT` and `INSERT` templates
+	Where         int                  // The byte offset right after the '{' opening the outermost WHERE clause, or -1 without one
+}
+
+// The names of the built-in functions, aggregates included
+var builtins = map[string]bool{
+	"STR": true, "LANG": true, "LANGMATCHES": true, "DATATYPE": true, "BOUND": true, "IRI": true, "URI": true,
+	"BNODE": true, "RAND": true, "ABS": true, "CEIL": true, "FLOOR": true, "ROUND": true, "CONCAT": true,
+	"STRLEN": true, "UCASE": true, "LCASE": true, "ENCODE_FOR_URI": true, "CONTAINS": true, "STRSTARTS": true,
+	"STRENDS": true, "STRBEFORE": true, "STRAFTER": true, "YEAR": true, "MONTH": true, "DAY": true, "HOURS": true,
+	"MINUTES": true, "SECONDS": true, "TIMEZONE": true, "TZ": true, "NOW": true, "UUID": true, "STRUUID": true,
+	"MD5": true, "SHA1": true, "SHA256": true, "SHA384": true, "SHA512": true, "COALESCE": true, "IF": true,
+	"STRLANG": true, "STRDT": true, "SAMETERM": true, "ISIRI": true, "ISURI": true, "ISBLANK": true,
+	"ISLITERAL": true, "ISNUMERIC": true, "REGEX": true, "SUBSTR": true, "REPLACE": true,
+	"COUNT": true, "SUM": true, "MIN": true, "MAX": true, "AVG": true, "SAMPLE": true, "GROUP_CONCAT": true,
+}
+
+// The keywords that start a graph pattern other than a triple pattern
+var graphPatternKeywords = []string{"OPTIONAL", "MINUS", "GRAPH", "SERVICE", "FILTER", "BIND", "VALUES"}
+
+// Recursive descent parser following the SPARQL 1.1 grammar.
+// Syntax errors are raised as panics and recovered by `Parse`.
+type parser struct {
+	tokens   []token // The tokens of the query
+	pos      int     // The index of the current token
+	query    *Query  // The information gathered so far
+	template bool    // Whether the triples being parsed are a template rather than a pattern
+}
+
+// Parses a SPARQL 1.1 query or update
+//
+// `text`: The query or update
+//
+// returns: the parsed query or a `*SyntaxError` if it is not valid
+func Parse(text string) (query *Query, err error) {
+	tokens, err := tokenize(text)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{
+		tokens: tokens,
+		query: &Query{
+			Prefixes:      map[string]string{},
+			PrefixedNames: []PrefixedName{},
+			IRIs:          []string{},
+			Variables:     map[string]*Variable{},
+			Predicates:    []Predicate{},
+			Produced:      []string{},
+			Where:         -1,
+		},
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			syntaxErr, ok := r.(*SyntaxError)
+			if !ok {
+				panic(r)
+			}
+			query, err = nil, syntaxErr
+		}
+	}()
+
+	p.parseRequest()
+	return p.query, nil
+}
+
+// The current token
+func (p *parser) cur() token {
+	return p.tokens[p.pos]
+}
+
+// Consumes the current token
+func (p *parser) advance() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// Aborts parsing with a syntax error at the current token
+func (p *parser) fail(format string, args ...interface{}) {
+	tok := p.cur()
+	panic(&SyntaxError{Line: tok.line, Column: tok.column, Message: fmt.Sprintf(format, args...)})
+}
+
+// Describes the current token for error messages
+func (p *parser) found() string {
+	tok := p.cur()
+	switch tok.kind {
+	case tokEOF:
+		return "end of query"
+	case tokString:
+		return "string"
+	case tokIRI:
+		return fmt.Sprintf("'<%s>'", tok.text)
+	case tokVar:
+		return fmt.Sprintf("'?%s'", tok.text)
+	default:
+		return fmt.Sprintf("'%s'", tok.text)
+	}
+}
+
+// Whether the current token is the given keyword, ignoring case
+func (p *parser) isKeyword(keyword string) bool {
+	tok := p.cur()
+	return tok.kind == tokName && strings.EqualFold(tok.text, keyword)
+}
+
+// Whether the token after the current one is the given keyword, ignoring case
+func (p *parser) nextIsKeyword(keyword string) bool {
+	if p.pos+1 >= len(p.tokens) {
+		return false
+	}
+	tok := p.tokens[p.pos+1]
+	return tok.kind == tokName && strings.EqualFold(tok.text, keyword)
+}
+
+// Whether the current token is the given punctuation
+func (p *parser) isPunct(punct string) bool {
+	tok := p.cur()
+	return tok.kind == tokPunct && tok.text == punct
+}
+
+// Consumes the current token if it is the given keyword
+func (p *parser) acceptKeyword(keyword string) bool {
+	if p.isKeyword(keyword) {
+		p.advance()
+		return true
+	}
+	return false
+}
+
+// Consumes the current token if it is the given punctuation
+func (p *parser) acceptPunct(punct string) bool {
+	if p.isPunct(punct) {
+		p.advance()
+		return true
+	}
+	return false
+}
+
+// Consumes the given keyword or fails
+func (p *parser) expectKeyword(keyword string) {
+	if !p.acceptKeyword(keyword) {
+		p.fail("expected '%s', found %s", keyword, p.found())
+	}
+}
+
+// Consumes the given punctuation or fails
+func (p *parser) expectPunct(punct string) {
+	if !p.acceptPunct(punct) {
+		p.fail("expected '%s', found %s", punct, p.found())
+	}
+}
+
+// Records the use of a variable
+//
+// `tok`: The variable token
+//
+// `binds`: Whether this use binds the variable
+func (p *parser) useVariable(tok token, binds bool) *Variable {
+	v, ok := p.query.Variables[tok.text]
+	if !ok {
+		v = &Variable{Name: tok.text, Line: tok.line}
+		p.query.Variables[tok.text] = v
+	}
+	v.Mentions++
+	if p.template {
+		v.Templated = true
+	} else if binds {
+		v.Bound = true
+	}
+	return v
+}
+
+// Consumes a variable or fails
+func (p *parser) parseVar(binds bool) *Variable {
+	if p.cur().kind != tokVar {
+		p.fail("expected a variable, found %s", p.found())
+	}
+	return p.useVariable(p.advance(), binds)
+}
+
+// Consumes an IRI or prefixed name or fails
+//
+// returns: the full IRI, or "" if it uses an undeclared prefix, and the IRI as written
+func (p *parser) parseIRI() (string, string) {
+	tok := p.cur()
+	switch tok.kind {
+	case tokIRI:
+		p.advance()
+		p.query.IRIs = append(p.query.IRIs, tok.text)
+		return tok.text, fmt.Sprintf("<%s>", tok.text)
+	case tokPName:
+		p.advance()
+		idx := strings.Index(tok.text, ":")
+		prefix, local := tok.text[:idx], tok.text[idx+1:]
+		p.query.PrefixedNames = append(p.query.PrefixedNames, PrefixedName{Prefix: prefix, Local: local, Line: tok.line})
+		if ns, ok := p.query.Prefixes[prefix]; ok {
+			return ns + local, tok.text
+		}
+		return "", tok.text
+	}
+	p.fail("expected an IRI, found %s", p.found())
+	return "", ""
+}
+
+// Whether the current token is an IRI or prefixed name
+func (p *parser) isIRI() bool {
+	kind := p.cur().kind
+	return kind == tokIRI || kind == tokPName
+}
+
+// Request: Prologue ( Query | Update )
+func (p *parser) parseRequest() {
+	p.parsePrologue()
+	switch {
+	case p.isKeyword("SELECT"):
+		p.query.Form = SELECT
+		p.parseSelect(true)
+	case p.isKeyword("CONSTRUCT"):
+		p.query.Form = CONSTRUCT
+		p.parseConstruct()
+	case p.isKeyword("DESCRIBE"):
+		p.query.Form = DESCRIBE
+		p.parseDescribe()
+	case p.isKeyword("ASK"):
+		p.query.Form = ASK
+		p.advance()
+		p.parseDatasetClauses()
+		p.parseWhereClause()
+		p.parseSolutionModifier()
+	default:
+		p.query.Form = UPDATE
+		p.parseUpdate()
+		return
+	}
+	if p.acceptKeyword("VALUES") {
+		p.parseDataBlock()
+	}
+	if p.cur().kind != tokEOF {
+		p.fail("unexpected %s after the end of the query", p.found())
+	}
+}
+
+// Prologue: ( BASE IRIREF | PREFIX PNAME_NS IRIREF )*
+func (p *parser) parsePrologue() {
+	for {
+		switch {
+		case p.acceptKeyword("BASE"):
+			if p.cur().kind != tokIRI {
+				p.fail("expected an IRI after BASE, found %s", p.found())
+			}
+			p.advance()
+		case p.acceptKeyword("PREFIX"):
+			tok := p.cur()
+			if tok.kind != tokPName || !strings.HasSuffix(tok.text, ":") {
+				p.fail("expected a prefix such as 'ex:' after PREFIX, found %s", p.found())
+			}
+			p.advance()
+			if p.cur().kind != tokIRI {
+				p.fail("expected an IRI after 'PREFIX %s', found %s", tok.text, p.found())
+			}
+			p.query.Prefixes[strings.TrimSuffix(tok.text, ":")] = p.advance().text
+		default:
+			return
+		}
+	}
+}
+
+// SelectQuery or SubSelect: SelectClause DatasetClause* WhereClause SolutionModifier
+//
+// `outermost`: Whether this is the main query rather than a sub query
+func (p *parser) parseSelect(outermost bool) {
+	p.expectKeyword("SELECT")
+	if !p.acceptKeyword("DISTINCT") {
+		p.acceptKeyword("REDUCED")
+	}
+
+	if p.acceptPunct("*") {
+		if outermost {
+			p.query.SelectAll = true
+		}
+	} else {
+		projected := 0
+		for {
+			if p.cur().kind == tokVar {
+				v := p.parseVar(false)
+				if outermost {
+					v.Projected = true
+				}
+			} else if p.acceptPunct("(") {
+				p.parseExpression()
+				p.expectKeyword("AS")
+				v := p.parseVar(true)
+				if outermost {
+					v.Projected = true
+				}
+				p.expectPunct(")")
+			} else {
+				break
+			}
+			projected++
+		}
+		if projected == 0 {
+			p.fail("expected '*' or the variables to select, found %s", p.found())
+		}
+	}
+
+	if outermost {
+		p.parseDatasetClauses()
+	}
+	p.parseWhereClause()
+	p.parseSolutionModifier()
+	if !outermost && p.acceptKeyword("VALUES") {
+		p.parseDataBlock()
+	}
+}
+
+// ConstructQuery: CONSTRUCT ( ConstructTemplate DatasetClause* WhereClause | DatasetClause* WHERE '{' TriplesTemplate? '}' ) SolutionModifier
+func (p *parser) parseConstruct() {
+	p.expectKeyword("CONSTRUCT")
+	if p.isPunct("{") {
+		p.parseTemplate(func() {
+			p.expectPunct("{")
+			p.parseTriplesTemplate()
+			p.expectPunct("}")
+		}, true)
+		p.parseDatasetClauses()
+		p.parseWhereClause()
+	} else {
+		// the short form's pattern is also its template
+		p.parseDatasetClauses()
+		p.expectKeyword("WHERE")
+		p.expectPunct("{")
+		start := len(p.query.Predicates)
+		p.parseTriplesTemplate()
+		for _, pred := range p.query.Predicates[start:] {
+			p.query.Produced = append(p.query.Produced, pred.IRI)
+		}
+		p.expectPunct("}")
+	}
+	p.parseSolutionModifier()
+}
+
+// DescribeQuery: DESCRIBE ( VarOrIri+ | '*' ) DatasetClause* WhereClause? SolutionModifier
+func (p *parser) parseDescribe() {
+	p.expectKeyword("DESCRIBE")
+	if p.acceptPunct("*") {
+		p.query.SelectAll = true
+	} else {
+		described := 0
+		for p.cur().kind == tokVar || p.isIRI() {
+			if p.cur().kind == tokVar {
+				p.parseVar(false).Projected = true
+			} else {
+				p.parseIRI()
+			}
+			described++
+		}
+		if described == 0 {
+			p.fail("expected '*' or the resources to describe, found %s", p.found())
+		}
+	}
+	p.parseDatasetClauses()
+	if p.isKeyword("WHERE") || p.isPunct("{") {
+		p.parseWhereClause()
+	}
+	p.parseSolutionModifier()
+}
+
+// DatasetClause*: ( FROM NAMED? iri )*
+func (p *parser) parseDatasetClauses() {
+	for p.acceptKeyword("FROM") {
+		p.acceptKeyword("NAMED")
+		p.parseIRI()
+	}
+}
+
+// WhereClause: WHERE? GroupGraphPattern
+func (p *parser) parseWhereClause() {
+	p.acceptKeyword("WHERE")
+	if !p.isPunct("{") {
+		p.fail("expected '{' to start the WHERE clause, found %s", p.found())
+	}
+	if p.query.Where < 0 {
+		p.query.Where = p.cur().offset + 1
+	}
+	p.parseGroupGraphPattern()
+}
+
+// SolutionModifier: GroupClause? HavingClause? OrderClause? LimitOffsetClauses?
+func (p *parser) parseSolutionModifier() {
+	if p.acceptKeyword("GROUP") {
+		p.expectKeyword("BY")
+		conditions := 0
+		for {
+			if p.cur().kind == tokVar {
+				p.parseVar(false)
+			} else if p.acceptPunct("(") {
+				p.parseExpression()
+				if p.acceptKeyword("AS") {
+					p.parseVar(true)
+				}
+				p.expectPunct(")")
+			} else if p.isFunctionStart() {
+				p.parsePrimary()
+			} else {
+				break
+			}
+			conditions++
+		}
+		if conditions == 0 {
+			p.fail("expected a grouping condition, found %s", p.found())
+		}
+	}
+	if p.acceptKeyword("HAVING") {
+		p.parseConstraint()
+		for p.isPunct("(") || p.isFunctionStart() {
+			p.parseConstraint()
+		}
+	}
+	if p.acceptKeyword("ORDER") {
+		p.expectKeyword("BY")
+		conditions := 0
+		for {
+			if p.acceptKeyword("ASC") || p.acceptKeyword("DESC") {
+				p.expectPunct("(")
+				p.parseExpression()
+				p.expectPunct(")")
+			} else if p.cur().kind == tokVar {
+				p.parseVar(false)
+			} else if p.isPunct("(") || p.isFunctionStart() {
+				p.parseConstraint()
+			} else {
+				break
+			}
+			conditions++
+		}
+		if conditions == 0 {
+			p.fail("expected an ordering condition, found %s", p.found())
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if p.acceptKeyword("LIMIT") || p.acceptKeyword("OFFSET") {
+			if p.cur().kind != tokNumber {
+				p.fail("expected an integer, found %s", p.found())
+			}
+			p.advance()
+		}
+	}
+}
+
+// GroupGraphPattern: '{' ( SubSelect | GroupGraphPatternSub ) '}'
+func (p *parser) parseGroupGraphPattern() {
+	p.expectPunct("{")
+	if p.isKeyword("SELECT") {
+		p.parseSelect(false)
+		p.expectPunct("}")
+		return
+	}
+
+	for !p.acceptPunct("}") {
+		if p.isGraphPatternStart() {
+			p.parseGraphPatternNotTriples()
+			p.acceptPunct(".")
+			continue
+		}
+		if p.cur().kind == tokEOF {
+			p.fail("expected '}', found end of query")
+		}
+		p.parseTriplesSameSubject()
+		if !p.acceptPunct(".") && !p.isPunct("}") && !p.isGraphPatternStart() {
+			p.fail("expected '.' or '}' after the triple pattern, found %s", p.found())
+		}
+	}
+}
+
+// Whether the current token starts a graph pattern other than a triple pattern
+func (p *parser) isGraphPatternStart() bool {
+	if p.isPunct("{") {
+		return true
+	}
+	for _, keyword := range graphPatternKeywords {
+		if p.isKeyword(keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// GraphPatternNotTriples: GroupOrUnionGraphPattern | OptionalGraphPattern | MinusGraphPattern | GraphGraphPattern | ServiceGraphPattern | Filter | Bind | InlineData
+func (p *parser) parseGraphPatternNotTriples() {
+	switch {
+	case p.isPunct("{"):
+		p.parseGroupGraphPattern()
+		for p.acceptKeyword("UNION") {
+			p.parseGroupGraphPattern()
+		}
+	case p.acceptKeyword("OPTIONAL"), p.acceptKeyword("MINUS"):
+		p.parseGroupGraphPattern()
+	case p.acceptKeyword("GRAPH"):
+		p.parseVarOrIRI(true)
+		p.parseGroupGraphPattern()
+	case p.acceptKeyword("SERVICE"):
+		p.acceptKeyword("SILENT")
+		p.parseVarOrIRI(false)
+		p.parseGroupGraphPattern()
+	case p.acceptKeyword("FILTER"):
+		p.parseConstraint()
+	case p.acceptKeyword("BIND"):
+		p.expectPunct("(")
+		p.parseExpression()
+		p.expectKeyword("AS")
+		p.parseVar(true)
+		p.expectPunct(")")
+	case p.acceptKeyword("VALUES"):
+		p.parseDataBlock()
+	}
+}
+
+// VarOrIri
+//
+// `binds`: Whether a variable here is bound
+func (p *parser) parseVarOrIRI(binds bool) {
+	if p.cur().kind == tokVar {
+		p.parseVar(binds)
+		return
+	}
+	p.parseIRI()
+}
+
+// DataBlock: InlineDataOneVar | InlineDataFull
+func (p *parser) parseDataBlock() {
+	if p.cur().kind == tokVar {
+		p.parseVar(true)
+		p.expectPunct("{")
+		for !p.acceptPunct("}") {
+			p.parseDataBlockValue()
+		}
+		return
+	}
+
+	vars := 0
+	p.expectPunct("(")
+	for p.cur().kind == tokVar {
+		p.parseVar(true)
+		vars++
+	}
+	p.expectPunct(")")
+	p.expectPunct("{")
+	for !p.acceptPunct("}") {
+		p.expectPunct("(")
+		values := 0
+		for !p.acceptPunct(")") {
+			p.parseDataBlockValue()
+			values++
+		}
+		if values != vars {
+			p.fail("expected %d values in the VALUES row, found %d", vars, values)
+		}
+	}
+}
+
+// DataBlockValue: iri | RDFLiteral | NumericLiteral | BooleanLiteral | UNDEF
+func (p *parser) parseDataBlockValue() {
+	switch {
+	case p.isIRI():
+		p.parseIRI()
+	case p.acceptKeyword("UNDEF"), p.acceptKeyword("true"), p.acceptKeyword("false"):
+	case p.cur().kind == tokString:
+		p.parseRDFLiteral()
+	case p.cur().kind == tokNumber, p.isPunct("+"), p.isPunct("-"):
+		p.parseNumericLiteral()
+	default:
+		p.fail("expected a value, found %s", p.found())
+	}
+}
+
+// RDFLiteral: String ( LANGTAG | '^^' iri )?
+func (p *parser) parseRDFLiteral() {
+	p.advance()
+	if p.cur().kind == tokLangTag {
+		p.advance()
+	} else if p.acceptPunct("^^") {
+		p.parseIRI()
+	}
+}
+
+// NumericLiteral, with an optional sign
+func (p *parser) parseNumericLiteral() {
+	if !p.acceptPunct("+") {
+		p.acceptPunct("-")
+	}
+	if p.cur().kind != tokNumber {
+		p.fail("expected a number, found %s", p.found())
+	}
+	p.advance()
+}
+
+// TriplesTemplate: triples separated by '.', ending at '}'
+func (p *parser) parseTriplesTemplate() {
+	for !p.isPunct("}") {
+		if p.cur().kind == tokEOF {
+			p.fail("expected '}', found end of query")
+		}
+		p.parseTriplesSameSubject()
+		if !p.acceptPunct(".") && !p.isPunct("}") {
+			p.fail("expected '.' or '}' after the triple, found %s", p.found())
+		}
+	}
+}
+
+// Parses triples as a template, where variables are filled in rather than bound
+//
+// `parse`: The function that parses the template
+//
+// `produces`: Whether the template writes its triples, as opposed to deleting them
+func (p *parser) parseTemplate(parse func(), produces bool) {
+	start := len(p.query.Predicates)
+	p.template = true
+	parse()
+	p.template = false
+	if produces {
+		for _, pred := range p.query.Predicates[start:] {
+			p.query.Produced = append(p.query.Produced, pred.IRI)
+		}
+	}
+	p.query.Predicates = p.query.Predicates[:start]
+}
+
+// TriplesSameSubjectPath: VarOrTerm PropertyListPathNotEmpty | TriplesNodePath PropertyListPath
+func (p *parser) parseTriplesSameSubject() {
+	if p.isPunct("(") && !p.isNil() || p.isPunct("[") && !p.isAnon() {
+		p.parseTriplesNode()
+		if !p.isPunct(".") && !p.isPunct("}") {
+			p.parsePropertyList()
+		}
+		return
+	}
+	p.parseVarOrTerm()
+	p.parsePropertyList()
+}
+
+// Whether the current tokens are `()`
+func (p *parser) isNil() bool {
+	return p.isPunct("(") && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].kind == tokPunct && p.tokens[p.pos+1].text == ")"
+}
+
+// Whether the current tokens are `[]`
+func (p *parser) isAnon() bool {
+	return p.isPunct("[") && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].kind == tokPunct && p.tokens[p.pos+1].text == "]"
+}
+
+// TriplesNodePath: Collection | BlankNodePropertyList
+func (p *parser) parseTriplesNode() {
+	if p.acceptPunct("(") {
+		p.parseGraphNode()
+		for !p.acceptPunct(")") {
+			p.parseGraphNode()
+		}
+		return
+	}
+	p.expectPunct("[")
+	p.parsePropertyList()
+	p.expectPunct("]")
+}
+
+// GraphNodePath: VarOrTerm | TriplesNodePath
+func (p *parser) parseGraphNode() {
+	if p.isPunct("(") && !p.isNil() || p.isPunct("[") && !p.isAnon() {
+		p.parseTriplesNode()
+		return
+	}
+	p.parseVarOrTerm()
+}
+
+// VarOrTerm: Var | iri | RDFLiteral | NumericLiteral | BooleanLiteral | BlankNode | NIL
+func (p *parser) parseVarOrTerm() {
+	tok := p.cur()
+	switch {
+	case tok.kind == tokVar:
+		p.parseVar(true)
+	case p.isIRI():
+		p.parseIRI()
+	case tok.kind == tokString:
+		p.parseRDFLiteral()
+	case tok.kind == tokNumber, p.isPunct("+"), p.isPunct("-"):
+		p.parseNumericLiteral()
+	case tok.kind == tokBNode:
+		p.advance()
+	case p.isKeyword("true"), p.isKeyword("false"):
+		p.advance()
+	case p.isNil(), p.isAnon():
+		p.advance()
+		p.advance()
+	default:
+		p.fail("expected a variable or RDF term, found %s", p.found())
+	}
+}
+
+// PropertyListPathNotEmpty: ( VerbPath | VerbSimple ) ObjectListPath ( ';' ( ( VerbPath | VerbSimple ) ObjectListPath )? )*
+func (p *parser) parsePropertyList() {
+	p.parseVerb()
+	p.parseObjectList()
+	for p.acceptPunct(";") {
+		if p.isPunct(";") || p.isPunct(".") || p.isPunct("}") || p.isPunct("]") {
+			continue
+		}
+		p.parseVerb()
+		p.parseObjectList()
+	}
+}
+
+// ObjectListPath: ObjectPath ( ',' ObjectPath )*
+func (p *parser) parseObjectList() {
+	p.parseGraphNode()
+	for p.acceptPunct(",") {
+		p.parseGraphNode()
+	}
+}
+
+// VerbPath | VerbSimple
+func (p *parser) parseVerb() {
+	if p.cur().kind == tokVar {
+		p.parseVar(true)
+		return
+	}
+	p.parsePathAlternative()
+}
+
+// PathAlternative: PathSequence ( '|' PathSequence )*
+func (p *parser) parsePathAlternative() {
+	p.parsePathSequence()
+	for p.acceptPunct("|") {
+		p.parsePathSequence()
+	}
+}
+
+// PathSequence: PathEltOrInverse ( '/' PathEltOrInverse )*
+func (p *parser) parsePathSequence() {
+	p.parsePathElt()
+	for p.acceptPunct("/") {
+		p.parsePathElt()
+	}
+}
+
+// PathEltOrInverse: '^'? PathPrimary PathMod?
+func (p *parser) parsePathElt() {
+	p.acceptPunct("^")
+	switch {
+	case p.isIRI():
+		tok := p.cur()
+		iri, text := p.parseIRI()
+		p.query.Predicates = append(p.query.Predicates, Predicate{IRI: iri, Text: text, Line: tok.line})
+	case p.acceptKeyword("a"):
+	case p.acceptPunct("!"):
+		if p.acceptPunct("(") {
+			for !p.acceptPunct(")") {
+				p.parsePathOneInPropertySet()
+				if !p.isPunct(")") {
+					p.expectPunct("|")
+				}
+			}
+		} else {
+			p.parsePathOneInPropertySet()
+		}
+	case p.acceptPunct("("):
+		p.parsePathAlternative()
+		p.expectPunct(")")
+	default:
+		p.fail("expected a predicate or property path, found %s", p.found())
+	}
+	if !p.acceptPunct("?") && !p.acceptPunct("*") {
+		p.acceptPunct("+")
+	}
+}
+
+// PathOneInPropertySet: iri | 'a' | '^' ( iri | 'a' )
+func (p *parser) parsePathOneInPropertySet() {
+	p.acceptPunct("^")
+	if !p.acceptKeyword("a") {
+		p.parseIRI()
+	}
+}
+
+// Update: Update1 ( ';' Prologue Update1 )*
+func (p *parser) parseUpdate() {
+	operations := 0
+	for {
+		if p.cur().kind == tokEOF {
+			break
+		}
+		p.parseUpdateOperation()
+		operations++
+		if !p.acceptPunct(";") {
+			break
+		}
+		p.parsePrologue()
+	}
+	if operations == 0 {
+		p.fail("expected a query or update, found %s", p.found())
+	}
+	if p.cur().kind != tokEOF {
+		p.fail("unexpected %s after the end of the update", p.found())
+	}
+}
+
+// Update1: Load | Clear | Drop | Add | Move | Copy | Create | InsertData | DeleteData | DeleteWhere | Modify
+func (p *parser) parseUpdateOperation() {
+	switch {
+	case p.acceptKeyword("LOAD"):
+		p.acceptKeyword("SILENT")
+		p.parseIRI()
+		if p.acceptKeyword("INTO") {
+			p.expectKeyword("GRAPH")
+			p.parseIRI()
+		}
+	case p.acceptKeyword("CLEAR"), p.acceptKeyword("DROP"):
+		p.acceptKeyword("SILENT")
+		if !p.acceptKeyword("DEFAULT") && !p.acceptKeyword("NAMED") && !p.acceptKeyword("ALL") {
+			p.expectKeyword("GRAPH")
+			p.parseIRI()
+		}
+	case p.acceptKeyword("CREATE"):
+		p.acceptKeyword("SILENT")
+		p.expectKeyword("GRAPH")
+		p.parseIRI()
+	case p.acceptKeyword("ADD"), p.acceptKeyword("MOVE"), p.acceptKeyword("COPY"):
+		p.acceptKeyword("SILENT")
+		p.parseGraphOrDefault()
+		p.expectKeyword("TO")
+		p.parseGraphOrDefault()
+	case p.isKeyword("INSERT") && p.nextIsKeyword("DATA"), p.isKeyword("DELETE") && p.nextIsKeyword("DATA"):
+		insert := p.isKeyword("INSERT")
+		p.advance()
+		p.advance()
+		p.parseTemplate(p.parseQuads, insert)
+	case p.isKeyword("DELETE") && p.nextIsKeyword("WHERE"):
+		p.advance()
+		p.advance()
+		p.parseQuads()
+	case p.isKeyword("WITH"), p.isKeyword("DELETE"), p.isKeyword("INSERT"):
+		p.parseModify()
+	default:
+		p.fail("expected a query or update, found %s", p.found())
+	}
+}
+
+// GraphOrDefault: DEFAULT | GRAPH? iri
+func (p *parser) parseGraphOrDefault() {
+	if p.acceptKeyword("DEFAULT") {
+		return
+	}
+	p.acceptKeyword("GRAPH")
+	p.parseIRI()
+}
+
+// Modify: ( WITH iri )? ( DeleteClause InsertClause? | InsertClause ) UsingClause* WHERE GroupGraphPattern
+func (p *parser) parseModify() {
+	if p.acceptKeyword("WITH") {
+		p.parseIRI()
+	}
+	modified := false
+	if p.acceptKeyword("DELETE") {
+		p.parseTemplate(p.parseQuads, false)
+		modified = true
+	}
+	if p.acceptKeyword("INSERT") {
+		p.parseTemplate(p.parseQuads, true)
+		modified = true
+	}
+	if !modified {
+		p.fail("expected DELETE or INSERT, found %s", p.found())
+	}
+	for p.acceptKeyword("USING") {
+		p.acceptKeyword("NAMED")
+		p.parseIRI()
+	}
+	p.expectKeyword("WHERE")
+	p.parseGroupGraphPattern()
+}
+
+// QuadPattern or QuadData: '{' TriplesTemplate? ( GRAPH VarOrIri '{' TriplesTemplate? '}' '.'? TriplesTemplate? )* '}'
+func (p *parser) parseQuads() {
+	p.expectPunct("{")
+	for !p.acceptPunct("}") {
+		if p.acceptKeyword("GRAPH") {
+			p.parseVarOrIRI(true)
+			p.expectPunct("{")
+			p.parseTriplesTemplate()
+			p.expectPunct("}")
+			p.acceptPunct(".")
+			continue
+		}
+		if p.cur().kind == tokEOF {
+			p.fail("expected '}', found end of query")
+		}
+		p.parseTriplesSameSubject()
+		if !p.acceptPunct(".") && !p.isPunct("}") && !p.isKeyword("GRAPH") {
+			p.fail("expected '.' or '}' after the triple, found %s", p.found())
+		}
+	}
+}
+
+// Constraint: BrackettedExpression | BuiltInCall | FunctionCall
+func (p *parser) parseConstraint() {
+	if p.acceptPunct("(") {
+		p.parseExpression()
+		p.expectPunct(")")
+		return
+	}
+	if !p.isFunctionStart() {
+		p.fail("expected a constraint, found %s", p.found())
+	}
+	p.parsePrimary()
+}
+
+// Whether the current token starts a built-in or function call
+func (p *parser) isFunctionStart() bool {
+	tok := p.cur()
+	if tok.kind == tokName {
+		upper := strings.ToUpper(tok.text)
+		return builtins[upper] || upper == "EXISTS" || upper == "NOT"
+	}
+	return p.isIRI() && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].kind == tokPunct && p.tokens[p.pos+1].text == "("
+}
+
+// Expression: ConditionalAndExpression ( '||' ConditionalAndExpression )*
+func (p *parser) parseExpression() {
+	p.parseAndExpression()
+	for p.acceptPunct("||") {
+		p.parseAndExpression()
+	}
+}
+
+// ConditionalAndExpression: RelationalExpression ( '&&' RelationalExpression )*
+func (p *parser) parseAndExpression() {
+	p.parseRelationalExpression()
+	for p.acceptPunct("&&") {
+		p.parseRelationalExpression()
+	}
+}
+
+// RelationalExpression: NumericExpression ( ( '=' | '!=' | '<' | '>' | '<=' | '>=' ) NumericExpression | NOT? IN ExpressionList )?
+func (p *parser) parseRelationalExpression() {
+	p.parseAdditiveExpression()
+	for _, op := range []string{"=", "!=", "<", ">", "<=", ">="} {
+		if p.acceptPunct(op) {
+			p.parseAdditiveExpression()
+			return
+		}
+	}
+	if p.isKeyword("NOT") && p.nextIsKeyword("IN") {
+		p.advance()
+	}
+	if p.acceptKeyword("IN") {
+		p.parseArgs(false)
+	}
+}
+
+// AdditiveExpression: MultiplicativeExpression ( ( '+' | '-' ) MultiplicativeExpression )*
+func (p *parser) parseAdditiveExpression() {
+	p.parseMultiplicativeExpression()
+	for p.acceptPunct("+") || p.acceptPunct("-") {
+		p.parseMultiplicativeExpression()
+	}
+}
+
+// MultiplicativeExpression: UnaryExpression ( ( '*' | '/' ) UnaryExpression )*
+func (p *parser) parseMultiplicativeExpression() {
+	p.parseUnaryExpression()
+	for p.acceptPunct("*") || p.acceptPunct("/") {
+		p.parseUnaryExpression()
+	}
+}
+
+// UnaryExpression: ( '!' | '+' | '-' )? PrimaryExpression
+func (p *parser) parseUnaryExpression() {
+	if !p.acceptPunct("!") && !p.acceptPunct("+") {
+		p.acceptPunct("-")
+	}
+	p.parsePrimary()
+}
+
+// PrimaryExpression: BrackettedExpression | BuiltInCall | iriOrFunction | RDFLiteral | NumericLiteral | BooleanLiteral | Var
+func (p *parser) parsePrimary() {
+	tok := p.cur()
+	switch {
+	case p.acceptPunct("("):
+		p.parseExpression()
+		p.expectPunct(")")
+	case tok.kind == tokVar:
+		p.parseVar(false)
+	case tok.kind == tokString:
+		p.parseRDFLiteral()
+	case tok.kind == tokNumber:
+		p.advance()
+	case p.isIRI():
+		p.parseIRI()
+		if p.isPunct("(") {
+			p.parseArgs(true)
+		}
+	case p.acceptKeyword("true"), p.acceptKeyword("false"):
+	case p.acceptKeyword("EXISTS"):
+		p.parseGroupGraphPattern()
+	case p.isKeyword("NOT") && p.nextIsKeyword("EXISTS"):
+		p.advance()
+		p.advance()
+		p.parseGroupGraphPattern()
+	case tok.kind == tokName && builtins[strings.ToUpper(tok.text)]:
+		p.parseBuiltin()
+	case tok.kind == tokName:
+		p.fail("unknown function or keyword %s in expression", p.found())
+	default:
+		p.fail("expected an expression, found %s", p.found())
+	}
+}
+
+// BuiltInCall and Aggregate
+func (p *parser) parseBuiltin() {
+	name := strings.ToUpper(p.advance().text)
+	switch name {
+	case "COUNT", "SUM", "MIN", "MAX", "AVG", "SAMPLE", "GROUP_CONCAT":
+		p.expectPunct("(")
+		p.acceptKeyword("DISTINCT")
+		if !(name == "COUNT" && p.acceptPunct("*")) {
+			p.parseExpression()
+		}
+		if name == "GROUP_CONCAT" && p.acceptPunct(";") {
+			p.expectKeyword("SEPARATOR")
+			p.expectPunct("=")
+			if p.cur().kind != tokString {
+				p.fail("expected a string separator, found %s", p.found())
+			}
+			p.advance()
+		}
+		p.expectPunct(")")
+	case "BOUND":
+		p.expectPunct("(")
+		p.parseVar(false)
+		p.expectPunct(")")
+	default:
+		p.parseArgs(false)
+	}
+}
+
+// ArgList or ExpressionList: NIL | '(' DISTINCT? Expression ( ',' Expression )* ')'
+//
+// `distinct`: Whether DISTINCT is allowed, as in custom aggregates
+func (p *parser) parseArgs(distinct bool) {
+	p.expectPunct("(")
+	if p.acceptPunct(")") {
+		return
+	}
+	if distinct {
+		p.acceptKeyword("DISTINCT")
+	}
+	p.parseExpression()
+	for p.acceptPunct(",") {
+		p.parseExpression()
+	}
+	p.expectPunct(")")
+}
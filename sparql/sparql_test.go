@@ -0,0 +1,170 @@
+// Tests for the sparql package
+package sparql_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Joao-Felisberto/devprivops/sparql"
+)
+
+// Test whether valid queries and updates of every form are accepted
+func TestParseValid(t *testing.T) {
+	queries := map[string]string{
+		"select": `
+PREFIX dfd: <https://devprivops.com/dfd/>
+SELECT DISTINCT ?flow (COUNT(DISTINCT ?d) AS ?n)
+WHERE {
+    dfd:ROOT dfd:data_flows ?flow .
+    ?flow dfd:data/dfd:categories* ?d ;
+          dfd:from [ dfd:id "x"@en ] .
+    OPTIONAL { ?flow dfd:encrypted ?e }
+    FILTER (!BOUND(?e) || ?e = "false"^^<http://www.w3.org/2001/XMLSchema#boolean>)
+    FILTER NOT EXISTS { ?flow dfd:to ?flow }
+    VALUES ?d { dfd:a dfd:b }
+}
+GROUP BY ?flow
+HAVING (COUNT(?d) > 1)
+ORDER BY DESC(?n) ?flow
+LIMIT 10`,
+		"subselect and union": `
+PREFIX ex: <https://example.com/>
+SELECT * WHERE {
+    { SELECT ?s WHERE { ?s ex:p 1.5e3 } }
+    { ?s ex:q ?o } UNION { ?s ^ex:r ?o }
+    BIND (CONCAT(STR(?o), "-") AS ?label)
+    FILTER (?o IN (1, -2, ex:c))
+}`,
+		"construct": `
+PREFIX ex: <https://example.com/>
+CONSTRUCT { ?s ex:copy ?o } WHERE { ?s ex:orig ?o }`,
+		"ask": `ASK { ?s a <https://example.com/T> }`,
+		"update": `
+PREFIX ex: <https://example.com/>
+DELETE { ?s ex:old ?o }
+INSERT { ?s ex:new ?o }
+WHERE { ?s ex:old ?o } ;
+INSERT DATA { ex:a ex:b "c" . } ;
+CLEAR SILENT DEFAULT`,
+	}
+
+	for name, query := range queries {
+		if _, err := sparql.Parse(query); err != nil {
+			t.Errorf("Query '%s' should be valid, got '%s'", name, err)
+		}
+	}
+}
+
+// Test whether syntax errors are reported at the line they occur
+func TestParseInvalid(t *testing.T) {
+	queries := map[string]int{
+		"SELECT ?x WHERE {\n ?x ?p ?o \n":                3,
+		"SELECT ?x\nWHERE {\n ?x ?p }":                   3,
+		"SELECT WHERE { ?x ?p ?o }":                      1,
+		"SELECT ?x WHERE {\n FILTER(FOO(?x)) ?x ?p ?o }": 2,
+		"INSERT { ?s ?p ?o }":                            1,
+		"\n\"unterminated":                               2,
+	}
+
+	for query, line := range queries {
+		_, err := sparql.Parse(query)
+		syntaxErr, ok := err.(*sparql.SyntaxError)
+		if !ok {
+			t.Errorf("Query '%s' should have a syntax error, got '%v'", query, err)
+			continue
+		}
+		if syntaxErr.Line != line {
+			t.Errorf("Syntax error of '%s' should be at line %d, got '%s'", query, line, syntaxErr)
+		}
+	}
+}
+
+// Test whether the static checks report the problems of a query
+func TestCheck(t *testing.T) {
+	query := `PREFIX ex: <https://example.com/>
+SELECT ?s ?missing
+WHERE {
+    ?s ex:known ?unused .
+    ?s undeclared:p ex:o .
+    ?s ex:unknown ?_ .
+}`
+	vocabulary := sparql.NewVocabulary()
+	vocabulary.AddNamespace("https://example.com/")
+	vocabulary.AddPredicate("https://example.com/known")
+
+	issues := sparql.Lint(query, vocabulary)
+	messages := []string{}
+	for _, issue := range issues {
+		messages = append(messages, issue.Message)
+	}
+	expected := []string{
+		"variable '?missing' is projected but never bound",
+		"variable '?unused' is bound but never used, consider '[]' instead",
+		"prefix 'undeclared:' is not declared",
+		"predicate 'ex:unknown' is not produced by any schema, description or rule",
+	}
+	if !reflect.DeepEqual(messages, expected) {
+		t.Errorf("Issues should be '%v', got '%v'", expected, messages)
+	}
+	if issues[0].Warning || !issues[1].Warning || issues[2].Warning || !issues[3].Warning {
+		t.Errorf("Only unused variables and unknown predicates should be warnings, got '%#v'", issues)
+	}
+}
+
+// Test whether the predicates written by templates are recorded, and template variables are checked
+func TestProduced(t *testing.T) {
+	query, err := sparql.Parse(`PREFIX ex: <https://example.com/>
+DELETE { ?s ex:removed ?o }
+INSERT { ?s ex:added ?new }
+WHERE { ?s ex:removed ?o }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(query.Produced, []string{"https://example.com/added"}) {
+		t.Errorf("Only 'ex:added' should be produced, got '%v'", query.Produced)
+	}
+
+	issues := sparql.Check(query, nil)
+	if len(issues) != 1 || !strings.Contains(issues[0].Message, "'?new' is used in a template but never bound") {
+		t.Errorf("The unbound template variable should be reported, got '%v'", issues)
+	}
+}
+
+// Test whether the IRIs written in full are recorded, but not the ones of the prologue
+func TestIRIs(t *testing.T) {
+	query, err := sparql.Parse(`PREFIX ex: <https://example.com/>
+SELECT ?s WHERE { ?s a <https://example.com/T> ; ex:p ex:o }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(query.IRIs, []string{"https://example.com/T"}) {
+		t.Errorf("Only '<https://example.com/T>' should be recorded, got '%v'", query.IRIs)
+	}
+}
+
+// Test whether every query in the examples is valid SPARQL
+func TestExamples(t *testing.T) {
+	err := filepath.WalkDir("../examples", func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".rq" {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		for _, issue := range sparql.Lint(string(data), nil) {
+			if !issue.Warning {
+				t.Errorf("%s:%d: %s", path, issue.Line, issue.Message)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
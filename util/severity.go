@@ -0,0 +1,77 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+)
+
+// How serious a policy violation, unmet requirement or possible attack is
+type Severity int
+
+const (
+	INFO     Severity = iota // Worth knowing, never a problem by itself
+	LOW                      // A minor problem
+	MEDIUM                   // A problem to fix soon
+	HIGH                     // A problem to fix before release, the default
+	CRITICAL                 // A problem to fix immediately
+)
+
+// The default severity of items that do not declare one, which keeps them failing the analysis as before severities existed
+const DEFAULT_SEVERITY = HIGH
+
+// The names of the severities, in increasing order
+var severityNames = []string{"info", "low", "medium", "high", "critical"}
+
+// Parses a severity name
+//
+// `name`: The name, case insensitive, or "" for the default severity
+//
+// returns: the severity or an error if the name is not known
+func ParseSeverity(name string) (Severity, error) {
+	if name == "" {
+		return DEFAULT_SEVERITY, nil
+	}
+	for i, n := range severityNames {
+		if strings.EqualFold(name, n) {
+			return Severity(i), nil
+		}
+	}
+	return DEFAULT_SEVERITY, fmt.Errorf("unknown severity '%s', expected one of %s", name, strings.Join(severityNames, ", "))
+}
+
+// The name of the severity
+func (s Severity) String() string {
+	if s < INFO || s > CRITICAL {
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+	return severityNames[s]
+}
+
+// Serializes the severity as its name in JSON reports
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Serializes the severity as its name in YAML reports
+func (s Severity) MarshalYAML() (interface{}, error) {
+	return s.String(), nil
+}
+
+// Reads the severity from its name in JSON reports
+func (s *Severity) UnmarshalText(text []byte) error {
+	severity, err := ParseSeverity(string(text))
+	if err != nil {
+		return err
+	}
+	*s = severity
+	return nil
+}
+
+// Reads the severity from its name in YAML reports
+func (s *Severity) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var name string
+	if err := unmarshal(&name); err != nil {
+		return err
+	}
+	return s.UnmarshalText([]byte(name))
+}
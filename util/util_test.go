@@ -2,11 +2,14 @@
 package util_test
 
 import (
+	"encoding/json"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/Joao-Felisberto/devprivops/util"
+	"gopkg.in/yaml.v2"
 )
 
 // Test for the Map function
@@ -138,3 +141,54 @@ func TestCreateFileWithData(t *testing.T) {
 		}
 	}
 }
+
+// Test for the ParseSeverity function
+func TestParseSeverity(t *testing.T) {
+	for i, name := range []string{"info", "low", "Medium", "HIGH", "critical"} {
+		s, err := util.ParseSeverity(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s != util.Severity(i) || s.String() != strings.ToLower(name) {
+			t.Errorf("'%s' should be severity %d, got %d ('%s')", name, i, s, s)
+		}
+	}
+
+	if s, err := util.ParseSeverity(""); err != nil || s != util.DEFAULT_SEVERITY {
+		t.Errorf("An empty severity should be the default, got '%s' (%v)", s, err)
+	}
+	if _, err := util.ParseSeverity("urgent"); err == nil {
+		t.Errorf("Unknown severities should be an error")
+	}
+	if !(util.INFO < util.LOW && util.LOW < util.MEDIUM && util.MEDIUM < util.HIGH && util.HIGH < util.CRITICAL) {
+		t.Errorf("Severities should be ordered")
+	}
+}
+
+// Test for reading back the severities written in JSON and YAML reports
+func TestSeverityRoundTrip(t *testing.T) {
+	report := map[string]util.Severity{"policy": util.MEDIUM, "tree": util.CRITICAL}
+
+	jsonData, err := json.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromJSON := map[string]util.Severity{}
+	if err := json.Unmarshal(jsonData, &fromJSON); err != nil || !reflect.DeepEqual(fromJSON, report) {
+		t.Errorf("Severities should be read back from %s, got %v (%v)", jsonData, fromJSON, err)
+	}
+
+	yamlData, err := yaml.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromYAML := map[string]util.Severity{}
+	if err := yaml.Unmarshal(yamlData, &fromYAML); err != nil || !reflect.DeepEqual(fromYAML, report) {
+		t.Errorf("Severities should be read back from %s, got %v (%v)", yamlData, fromYAML, err)
+	}
+
+	var s util.Severity
+	if err := json.Unmarshal([]byte(`"urgent"`), &s); err == nil {
+		t.Errorf("Unknown severities should not be read")
+	}
+}
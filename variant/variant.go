@@ -0,0 +1,536 @@
+// Package for the configuration language of `config/*.yml` files, which derives deployment variants from the descriptions
+// by adding, overriding and removing entities before the descriptions are loaded and the reasoner runs
+package variant
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Joao-Felisberto/devprivops/fs"
+	"gopkg.in/yaml.v2"
+)
+
+// Regex for variable references in values, e.g. `${region}`
+var variableRe = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// Another configuration file whose operations are applied first
+type Include struct {
+	File string `yaml:"file"` // The file, relative to the local or global directory
+	When string `yaml:"when"` // The condition under which it is included, or "" to always include it
+}
+
+// Appends an entity to a list of a description
+type Addition struct {
+	Description string      `yaml:"description"` // The file name of the description, e.g. `main.dfd.yml`
+	To          string      `yaml:"to"`          // The top level key of the list, e.g. `data flows`
+	Entity      interface{} `yaml:"entity"`      // The entity to append
+	When        string      `yaml:"when"`        // The condition under which it is added, or "" to always add it
+	matches     int         // How many descriptions it was applied to
+}
+
+// Replaces fields of the entities with an id
+type Override struct {
+	Id          string                      `yaml:"id"`          // The id of the entities, as written in the descriptions
+	Description string                      `yaml:"description"` // The file name of the only description to change, or "" for all
+	Set         map[interface{}]interface{} `yaml:"set"`         // The new value of each field, null to remove the field
+	When        string                      `yaml:"when"`        // The condition under which it is applied, or "" to always apply it
+	matches     int                         // How many entities it was applied to
+}
+
+// Removes the entities with an id
+type Removal struct {
+	Id          string `yaml:"id"`          // The id of the entities, as written in the descriptions
+	Description string `yaml:"description"` // The file name of the only description to change, or "" for all
+	When        string `yaml:"when"`        // The condition under which it is applied, or "" to always apply it
+	matches     int    // How many entities it removed
+}
+
+// The contents of a configuration file
+type File struct {
+	Variables map[string]interface{} `yaml:"variables"` // The values referenced as `${name}` in the file and the files it includes
+	Include   []Include              `yaml:"include"`   // The files whose operations are applied first
+	Add       []*Addition            `yaml:"add"`       // The entities to add
+	Override  []*Override            `yaml:"override"`  // The fields to replace
+	Remove    []*Removal             `yaml:"remove"`    // The entities to remove
+	Config    []interface{}          `yaml:"config"`    // The `cfg:value` substitutions applied in the database
+}
+
+// The operations of a configuration and the files it includes, with variables resolved and conditions evaluated
+type Plan struct {
+	Files     []string               // The files the operations come from, included files first
+	Variables map[string]interface{} // The resolved variables of the configuration file
+	Additions []*Addition            // The additions that apply
+	Overrides []*Override            // The overrides that apply
+	Removals  []*Removal             // The removals that apply
+	Config    []interface{}          // The `cfg:value` substitutions that apply
+}
+
+// Reads a configuration file and the files it includes into a plan
+//
+// `relativePath`: The configuration file, relative to the local or global directory
+//
+// returns: the plan or an error if any file cannot be read or parsed, a variable is undefined or cyclic, a condition is invalid or a file includes itself
+func Load(relativePath string) (*Plan, error) {
+	plan := &Plan{
+		Files:     []string{},
+		Additions: []*Addition{},
+		Overrides: []*Override{},
+		Removals:  []*Removal{},
+		Config:    []interface{}{},
+	}
+	variables, err := plan.load(relativePath, map[string]interface{}{}, []string{})
+	if err != nil {
+		return nil, err
+	}
+	plan.Variables = variables
+	return plan, nil
+}
+
+// Reads a configuration file into the plan, after the files it includes
+//
+// `relativePath`: The configuration file, relative to the local or global directory
+//
+// `inherited`: The variables of the including files, which take precedence over the file's own
+//
+// `including`: The files that include this one, to detect cycles
+//
+// returns: the resolved variables of the file or an error if it cannot be applied
+func (p *Plan) load(relativePath string, inherited map[string]interface{}, including []string) (map[string]interface{}, error) {
+	for _, f := range including {
+		if f == relativePath {
+			return nil, fmt.Errorf("configuration '%s' includes itself: %s -> %s", relativePath, strings.Join(including, " -> "), relativePath)
+		}
+	}
+	path, err := fs.GetFile(relativePath)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading configuration '%s': %s", relativePath, err)
+	}
+	file := File{}
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, fmt.Errorf("error parsing configuration '%s': %s", relativePath, err)
+	}
+
+	raw := map[string]interface{}{}
+	for name, value := range file.Variables {
+		raw[name] = value
+	}
+	for name, value := range inherited {
+		raw[name] = value
+	}
+	variables, err := resolveVariables(raw)
+	if err != nil {
+		return nil, fmt.Errorf("configuration '%s': %s", relativePath, err)
+	}
+
+	holds := func(condition string) (bool, error) {
+		ok, err := evaluate(condition, variables)
+		if err != nil {
+			return false, fmt.Errorf("configuration '%s': %s", relativePath, err)
+		}
+		return ok, nil
+	}
+	expand := func(value interface{}) (interface{}, error) {
+		expanded, err := interpolate(value, variables)
+		if err != nil {
+			return nil, fmt.Errorf("configuration '%s': %s", relativePath, err)
+		}
+		return expanded, nil
+	}
+	expandString := func(value string) (string, error) {
+		expanded, err := expand(value)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(fmt.Sprintf("%v", expanded)), nil
+	}
+
+	for _, inc := range file.Include {
+		ok, err := holds(inc.When)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		incFile, err := expandString(inc.File)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.load(incFile, raw, append(including, relativePath)); err != nil {
+			return nil, fmt.Errorf("configuration '%s': %s", relativePath, err)
+		}
+	}
+
+	for _, add := range file.Add {
+		ok, err := holds(add.When)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		if add.Description == "" || add.To == "" {
+			return nil, fmt.Errorf("configuration '%s': additions need a 'description' and a 'to' list", relativePath)
+		}
+		if add.Entity, err = expand(add.Entity); err != nil {
+			return nil, err
+		}
+		p.Additions = append(p.Additions, add)
+	}
+	for _, override := range file.Override {
+		ok, err := holds(override.When)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		if override.Id, err = expandString(override.Id); err != nil {
+			return nil, err
+		}
+		set, err := expand(override.Set)
+		if err != nil {
+			return nil, err
+		}
+		override.Set, _ = set.(map[interface{}]interface{})
+		p.Overrides = append(p.Overrides, override)
+	}
+	for _, removal := range file.Remove {
+		ok, err := holds(removal.When)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		if removal.Id, err = expandString(removal.Id); err != nil {
+			return nil, err
+		}
+		p.Removals = append(p.Removals, removal)
+	}
+	for _, c := range file.Config {
+		expanded, err := expand(c)
+		if err != nil {
+			return nil, err
+		}
+		p.Config = append(p.Config, expanded)
+	}
+
+	p.Files = append(p.Files, relativePath)
+	return variables, nil
+}
+
+// Resolves the variables that reference other variables
+//
+// `raw`: The variables as written
+//
+// returns: the resolved variables, or an error if a variable references an undefined variable or itself
+func resolveVariables(raw map[string]interface{}) (map[string]interface{}, error) {
+	resolved := map[string]interface{}{}
+	resolving := map[string]bool{}
+
+	var resolve func(name string) (interface{}, error)
+	resolve = func(name string) (interface{}, error) {
+		if value, ok := resolved[name]; ok {
+			return value, nil
+		}
+		value, ok := raw[name]
+		if !ok {
+			return nil, fmt.Errorf("undefined variable '%s'", name)
+		}
+		if resolving[name] {
+			return nil, fmt.Errorf("variable '%s' references itself", name)
+		}
+		resolving[name] = true
+		value, err := interpolateWith(value, resolve)
+		if err != nil {
+			return nil, err
+		}
+		resolving[name] = false
+		resolved[name] = value
+		return value, nil
+	}
+
+	for name := range raw {
+		if _, err := resolve(name); err != nil {
+			return nil, err
+		}
+	}
+	return resolved, nil
+}
+
+// Replaces the variable references in every string of a value.
+// A string that is exactly one reference becomes the variable's value, keeping lists and maps, while references within
+// longer strings are replaced by the variable's text.
+//
+// `value`: The YAML value
+//
+// `variables`: The resolved variables
+//
+// returns: the value with references replaced, or an error if a variable is undefined
+func interpolate(value interface{}, variables map[string]interface{}) (interface{}, error) {
+	return interpolateWith(value, func(name string) (interface{}, error) {
+		v, ok := variables[name]
+		if !ok {
+			return nil, fmt.Errorf("undefined variable '%s'", name)
+		}
+		return v, nil
+	})
+}
+
+// Replaces the variable references in every string of a value, looking variables up with a function
+//
+// `value`: The YAML value
+//
+// `lookup`: Returns the value of a variable
+//
+// returns: the value with references replaced, or an error if a lookup fails
+func interpolateWith(value interface{}, lookup func(string) (interface{}, error)) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		if m := variableRe.FindStringSubmatch(v); m != nil && m[0] == v {
+			return lookup(m[1])
+		}
+		var err error
+		res := variableRe.ReplaceAllStringFunc(v, func(ref string) string {
+			value, lookupErr := lookup(variableRe.FindStringSubmatch(ref)[1])
+			if lookupErr != nil {
+				err = lookupErr
+				return ref
+			}
+			return fmt.Sprintf("%v", value)
+		})
+		return res, err
+	case []interface{}:
+		res := make([]interface{}, len(v))
+		for i, e := range v {
+			expanded, err := interpolateWith(e, lookup)
+			if err != nil {
+				return nil, err
+			}
+			res[i] = expanded
+		}
+		return res, nil
+	case map[interface{}]interface{}:
+		res := make(map[interface{}]interface{}, len(v))
+		for k, e := range v {
+			expanded, err := interpolateWith(e, lookup)
+			if err != nil {
+				return nil, err
+			}
+			res[k] = expanded
+		}
+		return res, nil
+	default:
+		return value, nil
+	}
+}
+
+// Evaluates a condition: comparisons `name == value` or `name != value`, or a variable name alone, which holds
+// if the variable is neither false nor empty, joined by `and` and `or`, where `and` binds tighter
+//
+// `condition`: The condition, or "" which always holds
+//
+// `variables`: The resolved variables
+//
+// returns: whether the condition holds, or an error if it references an undefined variable
+func evaluate(condition string, variables map[string]interface{}) (bool, error) {
+	if strings.TrimSpace(condition) == "" {
+		return true, nil
+	}
+	for _, alternative := range strings.Split(condition, " or ") {
+		holds := true
+		for _, term := range strings.Split(alternative, " and ") {
+			ok, err := evaluateTerm(strings.TrimSpace(term), variables)
+			if err != nil {
+				return false, fmt.Errorf("condition '%s': %s", condition, err)
+			}
+			holds = holds && ok
+		}
+		if holds {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Evaluates a single comparison or variable of a condition
+//
+// `term`: The term
+//
+// `variables`: The resolved variables
+//
+// returns: whether the term holds, or an error if it references an undefined variable
+func evaluateTerm(term string, variables map[string]interface{}) (bool, error) {
+	for _, op := range []string{"!=", "=="} {
+		name, expected, isComparison := strings.Cut(term, op)
+		if !isComparison {
+			continue
+		}
+		name = strings.TrimSpace(name)
+		value, ok := variables[name]
+		if !ok {
+			return false, fmt.Errorf("undefined variable '%s'", name)
+		}
+		equal := fmt.Sprintf("%v", value) == strings.Trim(strings.TrimSpace(expected), `"'`)
+		return equal == (op == "=="), nil
+	}
+
+	value, ok := variables[term]
+	if !ok {
+		return false, fmt.Errorf("undefined variable '%s'", term)
+	}
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case nil:
+		return false, nil
+	default:
+		s := fmt.Sprintf("%v", v)
+		return s != "" && s != "false", nil
+	}
+}
+
+// Applies the plan to a description: additions first, then overrides, then removals
+//
+// `description`: The path of the description, only its file name is matched
+//
+// `data`: The parsed description, which is modified
+//
+// returns: the description or an error if an addition targets a key that is not a list
+func (p *Plan) Apply(description string, data interface{}) (interface{}, error) {
+	name := filepath.Base(description)
+	matches := func(target string) bool { return target == "" || target == name }
+
+	for _, add := range p.Additions {
+		if !matches(add.Description) {
+			continue
+		}
+		root, ok := data.(map[interface{}]interface{})
+		if !ok {
+			return nil, fmt.Errorf("cannot add to '%s' of '%s': the description is not a map", add.To, description)
+		}
+		list, ok := root[add.To].([]interface{})
+		if root[add.To] != nil && !ok {
+			return nil, fmt.Errorf("cannot add to '%s' of '%s': it is not a list", add.To, description)
+		}
+		root[add.To] = append(list, add.Entity)
+		add.matches++
+	}
+
+	for _, override := range p.Overrides {
+		if !matches(override.Description) {
+			continue
+		}
+		walkEntities(data, func(entity map[interface{}]interface{}) {
+			if entityId(entity) != override.Id {
+				return
+			}
+			for key, value := range override.Set {
+				if value == nil {
+					delete(entity, key)
+				} else {
+					entity[key] = value
+				}
+			}
+			override.matches++
+		})
+	}
+
+	for _, removal := range p.Removals {
+		if !matches(removal.Description) {
+			continue
+		}
+		data = removeEntities(data, removal)
+	}
+
+	return data, nil
+}
+
+// Lists the operations that did not change any description, usually due to a misspelled id or file name
+//
+// returns: a description of each operation
+func (p *Plan) Unmatched() []string {
+	unmatched := []string{}
+	for _, add := range p.Additions {
+		if add.matches == 0 {
+			unmatched = append(unmatched, fmt.Sprintf("add to '%s' of '%s'", add.To, add.Description))
+		}
+	}
+	for _, override := range p.Overrides {
+		if override.matches == 0 {
+			unmatched = append(unmatched, fmt.Sprintf("override '%s'", override.Id))
+		}
+	}
+	for _, removal := range p.Removals {
+		if removal.matches == 0 {
+			unmatched = append(unmatched, fmt.Sprintf("remove '%s'", removal.Id))
+		}
+	}
+	return unmatched
+}
+
+// The id of an entity, without surrounding spaces
+//
+// `entity`: The entity
+//
+// returns: the id, or "" if it has none
+func entityId(entity map[interface{}]interface{}) string {
+	id, _ := entity["id"].(string)
+	return strings.TrimSpace(id)
+}
+
+// Calls a function on every map in a YAML value, outermost first
+//
+// `node`: The YAML value
+//
+// `f`: The function
+func walkEntities(node interface{}, f func(map[interface{}]interface{})) {
+	switch n := node.(type) {
+	case map[interface{}]interface{}:
+		f(n)
+		for _, value := range n {
+			walkEntities(value, f)
+		}
+	case []interface{}:
+		for _, value := range n {
+			walkEntities(value, f)
+		}
+	}
+}
+
+// Removes the entities with the removal's id from every list in a YAML value
+//
+// `node`: The YAML value
+//
+// `removal`: The removal
+//
+// returns: the value without the entities
+func removeEntities(node interface{}, removal *Removal) interface{} {
+	switch n := node.(type) {
+	case map[interface{}]interface{}:
+		for key, value := range n {
+			n[key] = removeEntities(value, removal)
+		}
+		return n
+	case []interface{}:
+		kept := []interface{}{}
+		for _, value := range n {
+			if entity, ok := value.(map[interface{}]interface{}); ok && entityId(entity) == removal.Id {
+				removal.matches++
+				continue
+			}
+			kept = append(kept, removeEntities(value, removal))
+		}
+		return kept
+	default:
+		return node
+	}
+}
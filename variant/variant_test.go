@@ -0,0 +1,228 @@
+// Tests for the variant package
+package variant_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Joao-Felisberto/devprivops/fs"
+	"github.com/Joao-Felisberto/devprivops/variant"
+	"gopkg.in/yaml.v2"
+)
+
+// The description the test configurations change
+const description = `data stores:
+  - id: message db
+    location:
+      - Portugal
+    encryption: none
+  - id: analytics db
+    location:
+      - Portugal
+data flows:
+  - id: C store message
+    from: :send message
+    to: :message db
+  - id: C export analytics
+    from: :send message
+    to: :analytics db
+`
+
+// Writes files relative to a fresh local directory, and makes it the only layer
+func writeFiles(t *testing.T, files map[string]string) {
+	local, global, searchPath := fs.LocalDir, fs.GlobalDir, fs.SearchPath
+	t.Cleanup(func() { fs.LocalDir, fs.GlobalDir, fs.SearchPath = local, global, searchPath })
+
+	dir := t.TempDir()
+	fs.LocalDir, fs.GlobalDir, fs.SearchPath = dir, dir, []string{}
+	for name, content := range files {
+		file := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0766); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Loads a configuration and applies it to the test description
+func apply(t *testing.T, config string) (*variant.Plan, map[interface{}]interface{}) {
+	plan, err := variant.Load(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data interface{}
+	if err := yaml.Unmarshal([]byte(description), &data); err != nil {
+		t.Fatal(err)
+	}
+	res, err := plan.Apply("descriptions/main.dfd.yml", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return plan, res.(map[interface{}]interface{})
+}
+
+// The entity with an id in a list of the description
+func entity(data map[interface{}]interface{}, list string, id string) map[interface{}]interface{} {
+	for _, e := range data[list].([]interface{}) {
+		if m := e.(map[interface{}]interface{}); m["id"] == id {
+			return m
+		}
+	}
+	return nil
+}
+
+// Tests additions, overrides and removals, and the order they are applied in
+func TestApply(t *testing.T) {
+	writeFiles(t, map[string]string{"config/cloud.yml": `
+add:
+  - description: main.dfd.yml
+    to: data stores
+    entity:
+      id: backup db
+      location: [Ireland]
+override:
+  - id: backup db
+    set:
+      encryption: AES
+  - id: message db
+    set:
+      encryption: AES
+      location: null
+remove:
+  - id: C export analytics
+  - id: analytics db
+`})
+
+	plan, data := apply(t, "config/cloud.yml")
+
+	if e := entity(data, "data stores", "backup db"); e == nil || e["encryption"] != "AES" {
+		t.Errorf("The added entity should be overridden, got %v", e)
+	}
+	msgDB := entity(data, "data stores", "message db")
+	if msgDB["encryption"] != "AES" {
+		t.Errorf("The encryption should be overridden, got %v", msgDB["encryption"])
+	}
+	if _, ok := msgDB["location"]; ok {
+		t.Errorf("Setting a field to null should remove it, got %v", msgDB)
+	}
+	if entity(data, "data stores", "analytics db") != nil || entity(data, "data flows", "C export analytics") != nil {
+		t.Errorf("The removed entities should be gone, got %v", data)
+	}
+	if len(data["data flows"].([]interface{})) != 1 {
+		t.Errorf("Only the other data flow should remain, got %v", data["data flows"])
+	}
+	if unmatched := plan.Unmatched(); len(unmatched) != 0 {
+		t.Errorf("Every operation should match, got %v", unmatched)
+	}
+}
+
+// Tests variables referencing variables, values that are variables and conditional operations and includes
+func TestVariablesAndIncludes(t *testing.T) {
+	writeFiles(t, map[string]string{
+		"config/eu.yml": `
+variables:
+  region: EU
+  hosting: cloud
+  locations: [Ireland, Germany]
+  store: ${region} store
+  analytics: false
+include:
+  - file: config/variants/${hosting}.yml
+  - file: config/variants/on-prem.yml
+    when: hosting == on-prem
+override:
+  - id: message db
+    set:
+      location: ${locations}
+      name: ${store}
+remove:
+  - id: C export analytics
+    when: region == EU and hosting != on-prem
+  - id: C store message
+    when: region == US or analytics
+`,
+		"config/variants/cloud.yml": `
+variables:
+  region: US
+add:
+  - description: main.dfd.yml
+    to: data stores
+    entity:
+      id: ${region} cache
+`,
+		"config/variants/on-prem.yml": `
+remove:
+  - id: message db
+`,
+	})
+
+	plan, data := apply(t, "config/eu.yml")
+
+	if !reflect.DeepEqual(plan.Files, []string{"config/variants/cloud.yml", "config/eu.yml"}) {
+		t.Errorf("Only the cloud variant should be included, before the configuration, got %v", plan.Files)
+	}
+	if entity(data, "data stores", "EU cache") == nil {
+		t.Errorf("The including file's variables should take precedence, got %v", data["data stores"])
+	}
+	msgDB := entity(data, "data stores", "message db")
+	if !reflect.DeepEqual(msgDB["location"], []interface{}{"Ireland", "Germany"}) {
+		t.Errorf("A value that is only a variable should keep its list, got %v", msgDB["location"])
+	}
+	if msgDB["name"] != "EU store" {
+		t.Errorf("Variables should reference other variables, got %v", msgDB["name"])
+	}
+	if entity(data, "data flows", "C export analytics") != nil || entity(data, "data flows", "C store message") == nil {
+		t.Errorf("Only the removal whose condition holds should apply, got %v", data["data flows"])
+	}
+}
+
+// Tests the errors of invalid configurations
+func TestLoadErrors(t *testing.T) {
+	writeFiles(t, map[string]string{
+		"config/cycle.yml":         "variables:\n  a: ${b}\n  b: ${a}\n",
+		"config/undefined.yml":     "remove:\n  - id: x\n    when: region == EU\n",
+		"config/include.yml":       "include:\n  - file: config/variants/back.yml\n",
+		"config/variants/back.yml": "include:\n  - file: config/include.yml\n",
+		"config/typo.yml":          "overide:\n  - id: x\n",
+	})
+
+	cases := map[string]string{
+		"config/cycle.yml":     "references itself",
+		"config/undefined.yml": "undefined variable 'region'",
+		"config/include.yml":   "includes itself",
+		"config/typo.yml":      "overide",
+	}
+	for config, expected := range cases {
+		_, err := variant.Load(config)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Loading '%s' should fail with '%s', got %v", config, expected, err)
+		}
+	}
+}
+
+// Tests that operations that change nothing are reported
+func TestUnmatched(t *testing.T) {
+	writeFiles(t, map[string]string{"config/typos.yml": `
+add:
+  - description: mian.dfd.yml
+    to: data stores
+    entity: {id: x}
+override:
+  - id: mesage db
+    set: {encryption: AES}
+remove:
+  - id: message db
+    description: other.dfd.yml
+`})
+
+	plan, _ := apply(t, "config/typos.yml")
+	expected := []string{"add to 'data stores' of 'mian.dfd.yml'", "override 'mesage db'", "remove 'message db'"}
+	if unmatched := plan.Unmatched(); !reflect.DeepEqual(unmatched, expected) {
+		t.Errorf("Unmatched operations should be %v, got %v", expected, unmatched)
+	}
+}
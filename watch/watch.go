@@ -0,0 +1,169 @@
+// Package that detects changed files by polling directories
+package watch
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The state of a file when it was last seen
+type fileState struct {
+	modTime time.Time // When the file was last modified
+	size    int64     // The size of the file
+}
+
+// The files under a set of directories and their state at some point in time
+type Snapshot map[string]fileState
+
+// A file that changed, with the directory it is under
+type Change struct {
+	Root string // The watched directory the file is under
+	Path string // The path of the file relative to `Root`, with `/` separators
+}
+
+// Whether a file is a temporary or hidden file, such as the swap and backup files of editors, whose changes do not matter
+//
+// `name`: The name of the file
+//
+// returns: whether its changes are ignored
+func ignored(name string) bool {
+	return strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") || strings.HasSuffix(name, ".swp")
+}
+
+// Takes a snapshot of the files under some directories. Directories that do not exist are skipped.
+//
+// `roots`: The directories
+//
+// returns: the snapshot, with the files by their root and relative path, or an error if reading any directory fails
+func Take(roots []string) (Snapshot, error) {
+	snapshot := Snapshot{}
+	for _, root := range roots {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			if path != root && ignored(d.Name()) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			snapshot[key(root, filepath.ToSlash(rel))] = fileState{info.ModTime(), info.Size()}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return snapshot, nil
+}
+
+// The key of a file in a snapshot
+//
+// `root`: The watched directory
+//
+// `path`: The path relative to `root`
+//
+// returns: the key
+func key(root string, path string) string {
+	return root + "\x00" + path
+}
+
+// The files added, removed or modified between two snapshots
+//
+// `before`: The earlier snapshot
+//
+// `after`: The later snapshot
+//
+// returns: the changed files, sorted by root and path
+func Changes(before Snapshot, after Snapshot) []Change {
+	changed := []string{}
+	for k, state := range after {
+		if old, ok := before[k]; !ok || old != state {
+			changed = append(changed, k)
+		}
+	}
+	for k := range before {
+		if _, ok := after[k]; !ok {
+			changed = append(changed, k)
+		}
+	}
+	sort.Strings(changed)
+
+	changes := []Change{}
+	for _, k := range changed {
+		root, path, _ := strings.Cut(k, "\x00")
+		changes = append(changes, Change{Root: root, Path: path})
+	}
+	return changes
+}
+
+// Polls directories until they change and stay unchanged for a whole interval, so that a save that writes several files is seen at once
+//
+// `roots`: The directories
+//
+// `last`: The snapshot the changes are relative to
+//
+// `interval`: How long to wait between polls
+//
+// `stop`: Closed to stop polling
+//
+// returns: the changes and the snapshot they lead to, no changes if polling was stopped, or an error if reading any directory fails
+func Wait(roots []string, last Snapshot, interval time.Duration, stop <-chan struct{}) ([]Change, Snapshot, error) {
+	current := last
+	for {
+		select {
+		case <-stop:
+			return []Change{}, last, nil
+		case <-time.After(interval):
+		}
+		next, err := Take(roots)
+		if err != nil {
+			return nil, nil, err
+		}
+		settled := len(Changes(current, next)) == 0
+		current = next
+		if settled {
+			if changes := Changes(last, current); len(changes) > 0 {
+				return changes, current, nil
+			}
+		}
+	}
+}
+
+// The path of a changed file, as the layers resolve files
+//
+// returns: the path of the file under its root
+func (c Change) File() string {
+	return filepath.Join(c.Root, filepath.FromSlash(c.Path))
+}
+
+// Whether the changed file still exists
+//
+// returns: false if the file was removed
+func (c Change) Exists() bool {
+	_, err := os.Stat(c.File())
+	return err == nil
+}
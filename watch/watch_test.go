@@ -0,0 +1,94 @@
+// Tests for the watch package
+package watch_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Joao-Felisberto/devprivops/watch"
+)
+
+// Writes a file, creating its directory
+func writeFile(t *testing.T, path string, data string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0666); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Tests finding the files added, removed and modified between snapshots
+func TestChanges(t *testing.T) {
+	local := t.TempDir()
+	global := t.TempDir()
+	writeFile(t, filepath.Join(local, "descriptions", "main.dfd.yml"), "a")
+	writeFile(t, filepath.Join(local, "descriptions", "old.dfd.yml"), "a")
+	writeFile(t, filepath.Join(global, "regulations", "gdpr", "q.rq"), "a")
+
+	roots := []string{local, global, filepath.Join(t.TempDir(), "missing")}
+	before, err := watch.Take(roots)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, filepath.Join(local, "descriptions", "main.dfd.yml"), "ab")
+	writeFile(t, filepath.Join(local, "descriptions", ".main.dfd.yml.swp"), "ignored")
+	writeFile(t, filepath.Join(global, "regulations", "gdpr", "new.rq"), "a")
+	if err := os.Remove(filepath.Join(local, "descriptions", "old.dfd.yml")); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := watch.Take(roots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := watch.Changes(before, after)
+	expected := []watch.Change{
+		{Root: local, Path: "descriptions/main.dfd.yml"},
+		{Root: local, Path: "descriptions/old.dfd.yml"},
+		{Root: global, Path: "regulations/gdpr/new.rq"},
+	}
+	if local > global {
+		expected = append(expected[2:], expected[:2]...)
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Changes should be %v, got %v", expected, changes)
+	}
+	if changes[0].Exists() == changes[1].Exists() {
+		t.Errorf("Only the removed file should not exist")
+	}
+	if len(watch.Changes(after, after)) != 0 {
+		t.Errorf("A snapshot should not differ from itself")
+	}
+}
+
+// Tests waiting for changes and stopping
+func TestWait(t *testing.T) {
+	root := t.TempDir()
+	last, err := watch.Take([]string{root})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		writeFile(t, filepath.Join(root, "uris.yml"), "a")
+	}()
+	changes, next, err := watch.Wait([]string{root}, last, 10*time.Millisecond, make(chan struct{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Path != "uris.yml" {
+		t.Errorf("The new file should be the change, got %v", changes)
+	}
+
+	stop := make(chan struct{})
+	close(stop)
+	if changes, _, err := watch.Wait([]string{root}, next, time.Hour, stop); err != nil || len(changes) != 0 {
+		t.Errorf("Stopping should return no changes, got %v, %v", changes, err)
+	}
+}